@@ -17,8 +17,10 @@ package circuitbreaker
 import (
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
+	"trpc.group/trpc-go/trpc-go/admin"
 	"trpc.group/trpc-go/trpc-go/errs"
 	"trpc.group/trpc-go/trpc-go/naming/circuitbreaker"
 	"trpc.group/trpc-go/trpc-go/naming/registry"
@@ -34,28 +36,93 @@ type Config struct {
 	// ReportTimeout If ReportTimeout is set, when the downstream times out and the time is less than the set value,
 	// the error will be ignored and not reported.
 	ReportTimeout *time.Duration
+	// RetCodes maps the errors to the RetCode reported to polaris, nil for the default mapping.
+	RetCodes *RetCodeMapper
+	// ErrorPolicy classifies the errors by configuration before the functions registered by ShouldCircuitBreak.
+	ErrorPolicy *ErrorPolicy
+	// Levels configures the circuit breaking level by callee service name,
+	// LevelAnyService applies to all services without specific config, default as LevelInstance.
+	// The method levels are checked by Available and recorded by Report of the CircuitBreaker only,
+	// so they take no effect on Selector.Select and Selector.Report.
+	Levels map[string]string
+	// ContinuousErrorThreshold is the threshold of continuous errors to open the method level breakers,
+	// default as 10.
+	ContinuousErrorThreshold int
+	// SleepWindow is the duration from open to half open of the method level breakers, default as 30s.
+	SleepWindow time.Duration
+	// RequestCountAfterHalfOpen is the max number of requests allowed by the half open method level breakers,
+	// default as 10.
+	RequestCountAfterHalfOpen int
+	// SuccessCountAfterHalfOpen is the number of successful requests to close the half open method level breakers,
+	// default as 8.
+	SuccessCountAfterHalfOpen int
+	// Services overrides the thresholds of the method level breakers by callee service name.
+	Services map[string]Thresholds
+	// Classifier classifies the results of the requests, it may be shared with the selector.
+	// If it is nil, a classifier is created by ReportTimeout, ErrorPolicy, RetCodes and OutlierDetection.
+	// The state watcher and the prober are bound to the classifier, so that the callee services reported by
+	// Selector.Report are watched and probed as well.
+	Classifier *Classifier
+	// OutlierDetection configures the latency based outlier detection, nil to disable.
+	OutlierDetection *OutlierConfig
+	// Probe configures the active health probe of the broken instances, nil to disable.
+	Probe *ProbeConfig
+	// StateWatchInterval is the interval of watching the instance level states of the reported callee services
+	// for the state change events, zero or negative to disable, default as disabled.
+	StateWatchInterval time.Duration
 }
 
 const (
-	errRetCode = 10000
 	// DeltaTimeout is the default minimum request cost to trigger circuit breaker.
 	DeltaTimeout = time.Millisecond
 )
 
 // Setup is for setting up.
+// The prober and the state watcher started by Setup stop when Setup is called again with the same SDK context
+// and name, or when the SDK context is destroyed.
 func Setup(sdkCtx api.SDKContext, cfg *Config, setDefault bool) error {
-	minClientTimeout := DeltaTimeout
-	if cfg != nil && cfg.ReportTimeout != nil {
-		minClientTimeout = *cfg.ReportTimeout
-	}
 	name := "polarismesh"
 	if cfg != nil && cfg.Name != "" {
 		name = cfg.Name
 	}
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	classifier := cfg.Classifier
+	if classifier == nil {
+		var err error
+		if classifier, err = NewClassifier(cfg.ReportTimeout, cfg.ErrorPolicy, cfg.RetCodes); err != nil {
+			return err
+		}
+		if err := classifier.DetectOutliers(cfg.OutlierDetection); err != nil {
+			return err
+		}
+	}
+	if err := validLevels(cfg.Levels); err != nil {
+		return err
+	}
+	consumer := api.NewConsumerAPIByContext(sdkCtx)
+	prober, err := newProber(consumer, cfg.Probe)
+	if err != nil {
+		return fmt.Errorf("invalid probe config: %w", err)
+	}
 	cb := &CircuitBreaker{
-		consumer:           api.NewConsumerAPIByContext(sdkCtx),
-		shouldCircuitBreak: newShouldCircuitBreak(minClientTimeout),
+		consumer:   consumer,
+		classifier: classifier,
+		levels:     cfg.Levels,
+		thresholds: cfg.thresholds(),
+		services:   cfg.serviceThresholds(),
+	}
+	stop := restart(sdkCtx, name)
+	if prober != nil {
+		classifier.prober = prober
+		go prober.run(sdkCtx, stop)
 	}
+	if cfg.StateWatchInterval > 0 {
+		classifier.watcher = newStateWatcher(consumer)
+		go classifier.watcher.run(sdkCtx, stop, cfg.StateWatchInterval)
+	}
+	admin.HandleFunc(AdminPattern, HandleForce)
 	circuitbreaker.Register(name, cb)
 	if setDefault {
 		circuitbreaker.SetDefaultCircuitBreaker(cb)
@@ -63,18 +130,144 @@ func Setup(sdkCtx api.SDKContext, cfg *Config, setDefault bool) error {
 	return nil
 }
 
+// runKey identifies the background goroutines started by Setup.
+type runKey struct {
+	sdkCtx api.SDKContext
+	name   string
+}
+
+// runs are the channels to stop the background goroutines started by Setup, so that they are started once
+// per SDK context and name.
+var runs = struct {
+	mu sync.Mutex
+	m  map[runKey]chan struct{}
+}{m: make(map[runKey]chan struct{})}
+
+// restart stops the background goroutines started by Setup for the SDK context and the name before,
+// and returns the channel to stop the ones to be started.
+func restart(sdkCtx api.SDKContext, name string) <-chan struct{} {
+	runs.mu.Lock()
+	defer runs.mu.Unlock()
+	key := runKey{sdkCtx: sdkCtx, name: name}
+	if stop, ok := runs.m[key]; ok {
+		close(stop)
+	}
+	stop := make(chan struct{})
+	runs.m[key] = stop
+	return stop
+}
+
+// runEvery calls f every interval until stop is closed or the SDK context is destroyed.
+func runEvery(sdkCtx api.SDKContext, stop <-chan struct{}, interval time.Duration, f func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if sdkCtx.IsDestroyed() {
+				return
+			}
+			f()
+		}
+	}
+}
+
 // CircuitBreaker is the circuit breaker structure.
 type CircuitBreaker struct {
-	consumer           api.ConsumerAPI
-	shouldCircuitBreak func(error, time.Duration) Should
+	consumer   api.ConsumerAPI
+	classifier *Classifier
+	thresholds thresholds
+	// services are the thresholds by callee service name, which override the global thresholds.
+	services map[string]thresholds
+
+	mu     sync.RWMutex
+	levels map[string]string
+}
+
+func validLevels(levels map[string]string) error {
+	for service, level := range levels {
+		if err := validLevel(level); err != nil {
+			return fmt.Errorf("invalid level of service %s: %w", service, err)
+		}
+	}
+	return nil
+}
+
+// SetLevels replaces the circuit breaking levels by callee service name atomically.
+// The method level breakers already created keep working until they are evicted after being idle for 10m.
+func (cb *CircuitBreaker) SetLevels(levels map[string]string) error {
+	if err := validLevels(levels); err != nil {
+		return err
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.levels = levels
+	return nil
+}
+
+// Thresholds are the thresholds of the method level breakers of a callee service,
+// the zero values fall back to the global thresholds of Config.
+type Thresholds struct {
+	ContinuousErrorThreshold  int
+	SleepWindow               time.Duration
+	RequestCountAfterHalfOpen int
+	SuccessCountAfterHalfOpen int
+}
+
+func (c *Config) thresholds() thresholds {
+	return thresholds{
+		continuousErrorThreshold:  c.ContinuousErrorThreshold,
+		sleepWindow:               c.SleepWindow,
+		requestCountAfterHalfOpen: c.RequestCountAfterHalfOpen,
+		successCountAfterHalfOpen: c.SuccessCountAfterHalfOpen,
+	}.withDefault(thresholds{
+		continuousErrorThreshold:  defaultContinuousErrorThreshold,
+		sleepWindow:               defaultSleepWindow,
+		requestCountAfterHalfOpen: defaultRequestCountAfterHalfOpen,
+		successCountAfterHalfOpen: defaultSuccessCountAfterHalfOpen,
+	})
+}
+
+func (c *Config) serviceThresholds() map[string]thresholds {
+	if len(c.Services) == 0 {
+		return nil
+	}
+	global := c.thresholds()
+	services := make(map[string]thresholds, len(c.Services))
+	for service, t := range c.Services {
+		services[service] = thresholds{
+			continuousErrorThreshold:  t.ContinuousErrorThreshold,
+			sleepWindow:               t.SleepWindow,
+			requestCountAfterHalfOpen: t.RequestCountAfterHalfOpen,
+			successCountAfterHalfOpen: t.SuccessCountAfterHalfOpen,
+		}.withDefault(global)
+	}
+	return services
 }
 
 // Available determines whether the node is available.
+// For the method and instance_method levels, it also reflects the state of the called method.
+// The forced state of the instance by Force takes precedence.
+// Note that trpc-go does not call Available, the broken methods are excluded when the instances are selected
+// by Selector.Select and WRLoadBalancer.Select.
 func (cb *CircuitBreaker) Available(node *registry.Node) bool {
 	inst, ok := node.Metadata["instance"].(model.Instance)
 	if !ok {
 		return false
 	}
+	namespace, _ := node.Metadata["namespace"].(string)
+	switch forcedState(model.ServiceKey{Namespace: namespace, Service: node.ServiceName}, node.Address) {
+	case ForceOpen, ForceIsolate:
+		return false
+	case ForceClose:
+		return true
+	}
+	now := time.Now()
+	if b := cb.methodBreaker(node, now); b != nil && !b.available(now) {
+		return false
+	}
 	if inst.GetCircuitBreakerStatus() == nil {
 		return true
 	}
@@ -82,36 +275,26 @@ func (cb *CircuitBreaker) Available(node *registry.Node) bool {
 }
 
 // Report reports the request status.
+// The result is classified by the Classifier shared with Selector.Report.
 func (cb *CircuitBreaker) Report(node *registry.Node, cost time.Duration, err error) error {
-	retStatus := model.RetSuccess
-	var retCode int32
-	if err != nil {
-		switch cb.shouldCircuitBreak(err, cost) {
-		case True:
-			retStatus = model.RetFail
-			retCode = errRetCode
-		case False:
-		default:
-			// Unknown or Ignore will not be reported, will return directly.
-			return nil
-		}
+	retStatus, retCode, ok := cb.classifier.classify(node.ServiceName, cost, err)
+	reportOutcome(node, retStatus, ok)
+	if !ok {
+		return nil
 	}
 	inst, ok := node.Metadata["instance"].(model.Instance)
 	if !ok {
 		return errors.New("report err: invalid instance")
 	}
-
-	return cb.consumer.UpdateServiceCallResult(&api.ServiceCallResult{
-		ServiceCallResult: model.ServiceCallResult{
-			CalledInstance: inst,
-			RetStatus:      retStatus,
-			Delay:          &cost,
-			RetCode:        &retCode,
-		},
-	})
+	cb.classifier.reported(cb.consumer, node, retStatus, cost)
+	now := time.Now()
+	if b := cb.methodBreaker(node, now); b != nil {
+		b.report(retStatus == model.RetFail, now)
+	}
+	return updateServiceCallResult(cb.consumer, inst, node, retStatus, retCode, cost)
 }
 
-// Report reports the request status.
+// Report reports the request status with the default classification of the Classifier.
 func Report(
 	consumer api.ConsumerAPI,
 	node *registry.Node,
@@ -119,50 +302,11 @@ func Report(
 	cost time.Duration,
 	err error,
 ) error {
-	delta := DeltaTimeout
-	if reportTimeout != nil {
-		delta = *reportTimeout
-	}
-	retStatus := model.RetSuccess
-	var retCode int32
-	if !canIgnoreError(err, delta, cost) {
-		retStatus = model.RetFail
-		retCode = errRetCode
-	}
-	inst, ok := node.Metadata["instance"].(model.Instance)
-	if !ok {
-		return errors.New("report err: invalid instance")
-	}
-	if err := consumer.UpdateServiceCallResult(&api.ServiceCallResult{
-		ServiceCallResult: model.ServiceCallResult{
-			CalledInstance: inst,
-			RetStatus:      retStatus,
-			Delay:          &cost,
-			RetCode:        &retCode,
-		},
-	}); err != nil {
-		return fmt.Errorf("report err: %v", err)
-	}
-	return nil
-}
-
-func canIgnoreError(e error, reportTimeout, cost time.Duration) bool {
-	if e == nil {
-		return true
-	}
-	// when errorCode==101 && cost < reportTimeout && errorType==framework,
-	// the circuit breaker will consider it as a normal situation.
-	err, ok := e.(*errs.Error)
-	if ok &&
-		err.Code == errs.RetClientTimeout &&
-		err.Type == errs.ErrorTypeFramework &&
-		cost < reportTimeout {
-		return true
-	}
-	return false
+	return newClassifier(reportTimeout, nil, nil).Report(consumer, node, cost, err)
 }
 
 // ShouldCircuitBreak judges whether an error should be counted as a circuit breaker by f.
+// The ErrorPolicy configured by yaml takes precedence over f.
 //
 // True indicates that it should be counted as a blown error.
 // False means that it should not be counted as a circuit breaker error, and the circuit breaker will report success.
@@ -41,12 +41,58 @@ func TestSetUp(t *testing.T) {
 	assert.NotNil(t, circuitbreaker.DefaultCircuitBreaker)
 }
 
+func TestRestart(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock_api.NewMockSDKContext(ctrl)
+	m.EXPECT().IsDestroyed().Return(false).AnyTimes()
+	stopped := make(chan struct{})
+	stop := restart(m, "restart")
+	go func() {
+		runEvery(m, stop, time.Millisecond, func() {})
+		close(stopped)
+	}()
+	other := restart(m, "other")
+	select {
+	case <-stopped:
+		t.Fatal("the goroutines of other names are not stopped")
+	case <-time.After(10 * time.Millisecond):
+	}
+	restart(m, "restart")
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("the goroutines are stopped by the next Setup")
+	}
+	select {
+	case <-other:
+		t.Fatal("the goroutines of other names are not stopped")
+	default:
+	}
+
+	destroyed := mock_api.NewMockSDKContext(ctrl)
+	destroyed.EXPECT().IsDestroyed().Return(true)
+	done := make(chan struct{})
+	go func() {
+		runEvery(destroyed, restart(destroyed, "restart"), time.Millisecond, func() {
+			t.Error("not run after the SDK context is destroyed")
+		})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the goroutines are stopped when the SDK context is destroyed")
+	}
+}
+
 func TestAvailable(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	cb := &CircuitBreaker{
-		consumer:           mock_api.NewMockConsumerAPI(ctrl),
-		shouldCircuitBreak: newShouldCircuitBreak(DeltaTimeout),
+		consumer:   mock_api.NewMockConsumerAPI(ctrl),
+		classifier: newClassifier(nil, nil, nil),
 	}
 	inst := mock_model.NewMockInstance(ctrl)
 	status := mock_model.NewMockCircuitBreakerStatus(ctrl)
@@ -70,8 +116,8 @@ func TestReport(t *testing.T) {
 	m := mock_api.NewMockConsumerAPI(ctrl)
 	m.EXPECT().UpdateServiceCallResult(gomock.Any()).Return(nil).AnyTimes()
 	cb := &CircuitBreaker{
-		consumer:           m,
-		shouldCircuitBreak: newShouldCircuitBreak(DeltaTimeout),
+		consumer:   m,
+		classifier: newClassifier(nil, nil, nil),
 	}
 	inst := mock_model.NewMockInstance(ctrl)
 	node := &registry.Node{
@@ -85,78 +131,85 @@ func TestReport(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
-func TestCanIgnoreError(t *testing.T) {
-	type args struct {
-		cost time.Duration
-		err  error
-	}
-	tests := []struct {
-		name string
-		args args
-		want bool
-	}{
-		{
-			name: "normal",
-			args: args{
-				cost: time.Microsecond,
-				err: &errs.Error{
-					Type: errs.ErrorTypeFramework,
-					Code: errs.RetClientTimeout,
-				},
-			},
-			want: true,
-		},
-		{
-			name: "cost > 1ms",
-			args: args{
-				cost: time.Second,
-				err: &errs.Error{
-					Type: errs.ErrorTypeFramework,
-					Code: errs.RetClientTimeout,
-				},
-			},
-			want: false,
-		},
-		{
-			name: "type business",
-			args: args{
-				cost: time.Microsecond,
-				err: &errs.Error{
-					Type: errs.ErrorTypeBusiness,
-					Code: errs.RetClientTimeout,
-				},
-			},
-			want: false,
-		},
-		{
-			name: "nil",
-			args: args{},
-			want: true,
-		},
-		{
-			name: "not errs.Error",
-			args: args{
-				cost: time.Microsecond,
-				err:  errors.New("invalid error"),
-			},
-			want: false,
-		},
-	}
+var (
+	errCustomBreak  = errors.New("custom break")
+	errCustomIgnore = errors.New("custom ignore")
+)
+
+func TestReportPaths(t *testing.T) {
+	oldNew := newShouldCircuitBreak
+	t.Cleanup(func() { newShouldCircuitBreak = oldNew })
+	ShouldCircuitBreak(func(err error) Should {
+		switch err {
+		case errCustomBreak:
+			return True
+		case errCustomIgnore:
+			return Ignore
+		default:
+			return Unknown
+		}
+	})
+	policy := &ErrorPolicy{Rules: []*ErrorRule{{Type: ErrorTypeBusiness, Codes: []int{1}, Action: ActionIgnore}}}
+	reportTimeout := time.Millisecond
+	classifier, err := NewClassifier(&reportTimeout, policy, nil)
+	require.Nil(t, err)
 
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
-	inst := mock_model.NewMockInstance(ctrl)
-	node := &registry.Node{Metadata: map[string]interface{}{"instance": inst}}
+	node := &registry.Node{Metadata: map[string]interface{}{"instance": mock_model.NewMockInstance(ctrl)}}
+	var results []*api.ServiceCallResult
 	consumer := mock_api.NewMockConsumerAPI(ctrl)
-	consumer.EXPECT().UpdateServiceCallResult(gomock.Any()).Return(nil).AnyTimes()
-	reportTimeout := time.Millisecond
+	consumer.EXPECT().UpdateServiceCallResult(gomock.Any()).DoAndReturn(func(r *api.ServiceCallResult) error {
+		results = append(results, r)
+		return nil
+	}).AnyTimes()
+	cb := &CircuitBreaker{consumer: consumer, classifier: classifier}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			assert.Equal(t, canIgnoreError(tt.args.err, DeltaTimeout, tt.args.cost), tt.want)
-			require.Nil(t, Report(consumer, node, &reportTimeout, tt.args.cost, tt.args.err))
-		})
+	for _, path := range []struct {
+		name   string
+		report func(time.Duration, error) error
+	}{
+		{"selector", func(cost time.Duration, err error) error {
+			return classifier.Report(consumer, node, cost, err)
+		}},
+		{"circuit breaker", func(cost time.Duration, err error) error {
+			return cb.Report(node, cost, err)
+		}},
+	} {
+		for _, c := range []struct {
+			name     string
+			cost     time.Duration
+			err      error
+			reported bool
+			status   model.RetStatus
+		}{
+			{"nil", time.Second, nil, true, model.RetSuccess},
+			{"short timeout", time.Microsecond, errs.NewFrameError(errs.RetClientTimeout, ""), true, model.RetSuccess},
+			{"long timeout", time.Second, errs.NewFrameError(errs.RetClientTimeout, ""), true, model.RetFail},
+			{"net error", time.Microsecond, errs.NewFrameError(errs.RetClientNetErr, ""), true, model.RetFail},
+			{"business", time.Second, errs.New(errs.RetClientTimeout, ""), true, model.RetSuccess},
+			{"not errs.Error", time.Second, errors.New("invalid error"), true, model.RetSuccess},
+			{"error policy ignore", time.Second, errs.New(1, ""), false, ""},
+			{"custom break", time.Second, errCustomBreak, true, model.RetFail},
+			{"custom ignore", time.Second, errCustomIgnore, false, ""},
+		} {
+			t.Run(path.name+" "+c.name, func(t *testing.T) {
+				results = nil
+				require.Nil(t, path.report(c.cost, c.err))
+				if !c.reported {
+					require.Empty(t, results)
+					return
+				}
+				require.Len(t, results, 1)
+				assert.Equal(t, c.status, results[0].RetStatus)
+			})
+		}
 	}
+	require.Nil(t, Report(consumer, node, &reportTimeout, time.Second, errCustomIgnore))
+	assert.Empty(t, results, "package level Report uses the same classification")
+
+	_, err = NewClassifier(nil, &ErrorPolicy{Rules: []*ErrorRule{{Action: "unknown"}}}, nil)
+	assert.NotNil(t, err)
 }
 
 func TestShouldCircuitBreak(t *testing.T) {
@@ -168,8 +221,8 @@ func TestShouldCircuitBreak(t *testing.T) {
 	newCB := func() (*CircuitBreaker, *mock_api.MockConsumerAPI) {
 		consumer := mock_api.NewMockConsumerAPI(ctrl)
 		cb := CircuitBreaker{
-			consumer:           consumer,
-			shouldCircuitBreak: newShouldCircuitBreak(DeltaTimeout)}
+			consumer:   consumer,
+			classifier: newClassifier(nil, nil, nil)}
 		return &cb, consumer
 	}
 
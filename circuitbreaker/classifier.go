@@ -0,0 +1,152 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package circuitbreaker
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/naming/registry"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/metrics"
+
+	"github.com/polarismesh/polaris-go/api"
+	"github.com/polarismesh/polaris-go/pkg/model"
+)
+
+// Classifier classifies the results of the requests for circuit breaking.
+// It is shared by Selector.Report and CircuitBreaker.Report, so that both report the same results to polaris.
+//
+// An error is classified by the ErrorPolicy first, then by the functions registered by ShouldCircuitBreak,
+// and finally by the default strategy, which only counts the connection failures, the network errors and
+// the timeouts not shorter than the report timeout as circuit breaker errors.
+type Classifier struct {
+	shouldCircuitBreak func(error, time.Duration) Should
+	errorPolicy        *ErrorPolicy
+	retCodes           *RetCodeMapper
+	outliers           *outlierDetector
+	// watcher and prober are bound by Setup of the circuit breaker, they are nil if disabled.
+	watcher *stateWatcher
+	prober  *prober
+}
+
+// NewClassifier creates a classifier.
+// reportTimeout is the minimum cost of the timeouts to be counted, default as DeltaTimeout.
+// errorPolicy and retCodes may be nil for the default classification and RetCode mapping.
+func NewClassifier(
+	reportTimeout *time.Duration,
+	errorPolicy *ErrorPolicy,
+	retCodes *RetCodeMapper,
+) (*Classifier, error) {
+	if err := errorPolicy.compile(); err != nil {
+		return nil, err
+	}
+	return newClassifier(reportTimeout, errorPolicy, retCodes), nil
+}
+
+// newClassifier creates a classifier with the compiled errorPolicy.
+func newClassifier(reportTimeout *time.Duration, errorPolicy *ErrorPolicy, retCodes *RetCodeMapper) *Classifier {
+	minClientTimeout := DeltaTimeout
+	if reportTimeout != nil {
+		minClientTimeout = *reportTimeout
+	}
+	return &Classifier{
+		shouldCircuitBreak: newShouldCircuitBreak(minClientTimeout),
+		errorPolicy:        errorPolicy,
+		retCodes:           retCodes,
+	}
+}
+
+// classify returns the status and the RetCode reported to polaris of the request to the callee service.
+// It returns false if the result should not be reported.
+func (c *Classifier) classify(service string, cost time.Duration, err error) (model.RetStatus, int32, bool) {
+	if err == nil {
+		return model.RetSuccess, 0, true
+	}
+	should := c.errorPolicy.should(service, err)
+	if should == Unknown {
+		should = c.shouldCircuitBreak(err, cost)
+	}
+	switch should {
+	case True:
+		return model.RetFail, c.retCodes.RetCode(err), true
+	case False:
+		return model.RetSuccess, c.retCodes.RetCode(err), true
+	default:
+		// Unknown or Ignore will not be reported.
+		return model.RetSuccess, 0, false
+	}
+}
+
+// Report classifies the result of the request to the node and reports it to polaris by consumer.
+func (c *Classifier) Report(consumer api.ConsumerAPI, node *registry.Node, cost time.Duration, err error) error {
+	retStatus, retCode, ok := c.classify(node.ServiceName, cost, err)
+	reportOutcome(node, retStatus, ok)
+	if !ok {
+		return nil
+	}
+	inst, ok := node.Metadata["instance"].(model.Instance)
+	if !ok {
+		return errors.New("report err: invalid instance")
+	}
+	c.reported(consumer, node, retStatus, cost)
+	if err := updateServiceCallResult(consumer, inst, node, retStatus, retCode, cost); err != nil {
+		return fmt.Errorf("report err: %v", err)
+	}
+	return nil
+}
+
+// reported watches the callee service of the result reported to polaris for the state changes and the probes,
+// and observes the latency of the successful request for the outlier detection.
+func (c *Classifier) reported(
+	consumer api.ConsumerAPI, node *registry.Node, retStatus model.RetStatus, cost time.Duration) {
+	namespace, _ := node.Metadata["namespace"].(string)
+	c.watcher.watch(namespace, node.ServiceName)
+	c.prober.watch(namespace, node.ServiceName)
+	if retStatus == model.RetSuccess {
+		c.outliers.observe(consumer, node, cost, time.Now())
+	}
+}
+
+// outcomeIgnore is the outcome of the results which are not reported to polaris.
+const outcomeIgnore = "ignore"
+
+// reportOutcome reports the outcome of the request classified for circuit breaking by metrics.
+func reportOutcome(node *registry.Node, retStatus model.RetStatus, reported bool) {
+	outcome := string(retStatus)
+	if !reported {
+		outcome = outcomeIgnore
+	}
+	namespace, _ := node.Metadata["namespace"].(string)
+	metrics.ReportCircuitBreakerResult(namespace, node.ServiceName, outcome)
+}
+
+func updateServiceCallResult(
+	consumer api.ConsumerAPI,
+	inst model.Instance,
+	node *registry.Node,
+	retStatus model.RetStatus,
+	retCode int32,
+	cost time.Duration,
+) error {
+	return consumer.UpdateServiceCallResult(&api.ServiceCallResult{
+		ServiceCallResult: model.ServiceCallResult{
+			CalledInstance: inst,
+			Method:         nodeMethod(node),
+			RetStatus:      retStatus,
+			Delay:          &cost,
+			RetCode:        &retCode,
+		},
+	})
+}
@@ -0,0 +1,127 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package circuitbreaker
+
+import (
+	"fmt"
+
+	"trpc.group/trpc-go/trpc-go/errs"
+)
+
+// Actions of the error rules.
+const (
+	// ActionBreak counts the error as a circuit breaker error.
+	ActionBreak = "break"
+	// ActionSuccess reports the error as success.
+	ActionSuccess = "success"
+	// ActionIgnore ignores the error without any reporting.
+	ActionIgnore = "ignore"
+)
+
+// ErrorRule classifies the trpc errors of the type and codes.
+type ErrorRule struct {
+	// Type is the trpc error type, one of framework, callee_framework and business, empty for any type.
+	Type string `yaml:"type"`
+	// Codes are the trpc error codes, empty for any code.
+	Codes []int `yaml:"codes"`
+	// Action is one of break, success and ignore.
+	Action string `yaml:"action"`
+
+	errType int
+	should  Should
+}
+
+func (r *ErrorRule) compile() error {
+	errType, err := parseErrorType(r.Type)
+	if err != nil {
+		return err
+	}
+	r.errType = errType
+	switch r.Action {
+	case ActionBreak:
+		r.should = True
+	case ActionSuccess:
+		r.should = False
+	case ActionIgnore:
+		r.should = Ignore
+	default:
+		return fmt.Errorf("unknown action %s", r.Action)
+	}
+	return nil
+}
+
+func (r *ErrorRule) match(e *errs.Error) bool {
+	if r.errType != 0 && r.errType != e.Type {
+		return false
+	}
+	if len(r.Codes) == 0 {
+		return true
+	}
+	for _, code := range r.Codes {
+		if code == int(e.Code) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorPolicy classifies the trpc errors for circuit breaking by configuration.
+//
+// The rules of the callee service are matched first, and then the global rules, the first matched one wins.
+// If no rule matches, the error is judged by the functions registered by ShouldCircuitBreak
+// and the default strategy.
+type ErrorPolicy struct {
+	// Rules are the global rules.
+	Rules []*ErrorRule `yaml:"rules"`
+	// Services are the rules by callee service name.
+	Services map[string][]*ErrorRule `yaml:"services"`
+}
+
+func (p *ErrorPolicy) compile() error {
+	if p == nil {
+		return nil
+	}
+	for _, r := range p.Rules {
+		if err := r.compile(); err != nil {
+			return fmt.Errorf("invalid error policy rule: %w", err)
+		}
+	}
+	for service, rules := range p.Services {
+		for _, r := range rules {
+			if err := r.compile(); err != nil {
+				return fmt.Errorf("invalid error policy rule of service %s: %w", service, err)
+			}
+		}
+	}
+	return nil
+}
+
+// should classifies the error of the callee service, it returns Unknown if no rule matches.
+func (p *ErrorPolicy) should(service string, err error) Should {
+	if p == nil {
+		return Unknown
+	}
+	e, ok := err.(*errs.Error)
+	if !ok {
+		return Unknown
+	}
+	for _, rules := range [][]*ErrorRule{p.Services[service], p.Rules} {
+		for _, r := range rules {
+			if r.match(e) {
+				return r.should
+			}
+		}
+	}
+	return Unknown
+}
@@ -0,0 +1,121 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package circuitbreaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/errs"
+	"trpc.group/trpc-go/trpc-go/naming/registry"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_api"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_model"
+
+	"github.com/golang/mock/gomock"
+	"github.com/polarismesh/polaris-go/api"
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestErrorPolicy(t *testing.T) {
+	var p ErrorPolicy
+	require.Nil(t, yaml.Unmarshal([]byte(`
+rules:
+  - type: framework
+    codes: [101]
+    action: ignore
+  - type: business
+    codes: [1, 2]
+    action: break
+services:
+  trpc.app.server.service:
+    - type: framework
+      action: success
+`), &p))
+	require.Nil(t, p.compile())
+
+	for _, c := range []struct {
+		name    string
+		service string
+		err     error
+		want    Should
+	}{
+		{"global code", "", errs.NewFrameError(errs.RetClientTimeout, ""), Ignore},
+		{"global business codes", "", errs.New(2, ""), True},
+		{"global not matched", "", errs.New(3, ""), Unknown},
+		{"not trpc error", "", errors.New("err"), Unknown},
+		{"service override", "trpc.app.server.service", errs.NewFrameError(errs.RetClientTimeout, ""), False},
+		{"service falls back to global", "trpc.app.server.service", errs.New(1, ""), True},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.want, p.should(c.service, c.err))
+		})
+	}
+
+	var nilPolicy *ErrorPolicy
+	assert.Nil(t, nilPolicy.compile())
+	assert.Equal(t, Unknown, nilPolicy.should("", errs.New(1, "")))
+
+	for _, invalid := range []*ErrorPolicy{
+		{Rules: []*ErrorRule{{Action: "unknown"}}},
+		{Rules: []*ErrorRule{{Type: "unknown", Action: ActionBreak}}},
+		{Services: map[string][]*ErrorRule{"svc": {{Action: "unknown"}}}},
+	} {
+		assert.NotNil(t, invalid.compile())
+	}
+}
+
+func TestReportWithErrorPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	p := &ErrorPolicy{
+		Rules: []*ErrorRule{{Type: ErrorTypeBusiness, Codes: []int{1}, Action: ActionBreak}},
+		Services: map[string][]*ErrorRule{
+			"svc": {{Type: ErrorTypeFramework, Codes: []int{int(errs.RetClientNetErr)}, Action: ActionIgnore}},
+		},
+	}
+	require.Nil(t, p.compile())
+	consumer := mock_api.NewMockConsumerAPI(ctrl)
+	cb := &CircuitBreaker{
+		consumer:   consumer,
+		classifier: newClassifier(nil, p, nil),
+	}
+	inst := mock_model.NewMockInstance(ctrl)
+
+	var statuses []model.RetStatus
+	consumer.EXPECT().UpdateServiceCallResult(gomock.Any()).DoAndReturn(func(r *api.ServiceCallResult) error {
+		statuses = append(statuses, r.RetStatus)
+		return nil
+	}).Times(3)
+	node := &registry.Node{ServiceName: "svc", Metadata: map[string]interface{}{"instance": inst}}
+	require.Nil(t, cb.Report(node, time.Second, errs.New(1, "")))
+	require.Nil(t, cb.Report(node, time.Second, errs.NewFrameError(errs.RetClientNetErr, "")))
+	require.Nil(t, cb.Report(node, time.Second, errs.New(2, "")))
+	node = &registry.Node{ServiceName: "other", Metadata: map[string]interface{}{"instance": inst}}
+	require.Nil(t, cb.Report(node, time.Second, errs.NewFrameError(errs.RetClientNetErr, "")))
+	assert.Equal(t, []model.RetStatus{model.RetFail, model.RetSuccess, model.RetFail}, statuses)
+}
+
+func TestSetupWithInvalidErrorPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := mock_api.NewMockSDKContext(ctrl)
+	assert.NotNil(t, Setup(m, &Config{
+		ErrorPolicy: &ErrorPolicy{Rules: []*ErrorRule{{Action: "unknown"}}},
+	}, false))
+}
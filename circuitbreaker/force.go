@@ -0,0 +1,334 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package circuitbreaker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/admin"
+	"trpc.group/trpc-go/trpc-go/log"
+
+	"github.com/polarismesh/polaris-go/pkg/model"
+)
+
+// Forced states of instances.
+const (
+	// ForceOpen forces the instance to be broken, it is only selected if no other instance is available.
+	ForceOpen = "open"
+	// ForceIsolate isolates the instance, it is never selected.
+	ForceIsolate = "isolate"
+	// ForceClose forces the instance to be available regardless of the circuit breakers.
+	ForceClose = "close"
+)
+
+// AdminPattern is the pattern of the admin handler of the forced states.
+const AdminPattern = "/cmds/polarismesh/circuitbreaker/force"
+
+// Forced is the forced state of an instance.
+type Forced struct {
+	Namespace string    `json:"namespace"`
+	Service   string    `json:"service"`
+	Address   string    `json:"address"`
+	State     string    `json:"state"`
+	ExpireAt  time.Time `json:"expire_at"`
+}
+
+var forces = struct {
+	mu sync.RWMutex
+	m  map[model.ServiceKey]map[string]Forced // service -> address -> forced state.
+}{m: make(map[model.ServiceKey]map[string]Forced)}
+
+// Force forces the instance of the service of the namespace at the address into the state for ttl.
+// The state is one of ForceOpen, ForceIsolate and ForceClose.
+// It is honored by Selector.Select, WRLoadBalancer.Select and CircuitBreaker.Available.
+func Force(namespace, service, address, state string, ttl time.Duration) error {
+	switch state {
+	case ForceOpen, ForceIsolate, ForceClose:
+	default:
+		return fmt.Errorf("unknown forced state %s", state)
+	}
+	if namespace == "" || service == "" || address == "" {
+		return errors.New("namespace, service and address must not be empty")
+	}
+	if ttl <= 0 {
+		return fmt.Errorf("ttl %s must be positive", ttl)
+	}
+	now := time.Now()
+	forces.mu.Lock()
+	defer forces.mu.Unlock()
+	removeExpired(now)
+	key := model.ServiceKey{Namespace: namespace, Service: service}
+	if forces.m[key] == nil {
+		forces.m[key] = make(map[string]Forced)
+	}
+	forces.m[key][address] = Forced{
+		Namespace: namespace,
+		Service:   service,
+		Address:   address,
+		State:     state,
+		ExpireAt:  now.Add(ttl),
+	}
+	log.Warnf("[NAMING-POLARISMESH] instance %s of service %s of namespace %s is forced %s for %s",
+		address, service, namespace, state, ttl)
+	return nil
+}
+
+// Unforce removes the forced state of the instance of the service of the namespace at the address.
+func Unforce(namespace, service, address string) {
+	key := model.ServiceKey{Namespace: namespace, Service: service}
+	forces.mu.Lock()
+	defer forces.mu.Unlock()
+	if _, ok := forces.m[key][address]; !ok {
+		return
+	}
+	delete(forces.m[key], address)
+	if len(forces.m[key]) == 0 {
+		delete(forces.m, key)
+	}
+	log.Warnf("[NAMING-POLARISMESH] forced state of instance %s of service %s of namespace %s is removed",
+		address, service, namespace)
+}
+
+// ListForced lists the forced states which have not expired, sorted by namespace, service and address.
+func ListForced() []Forced {
+	now := time.Now()
+	forces.mu.Lock()
+	defer forces.mu.Unlock()
+	removeExpired(now)
+	list := make([]Forced, 0)
+	for _, instances := range forces.m {
+		for _, f := range instances {
+			list = append(list, f)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Namespace != list[j].Namespace {
+			return list[i].Namespace < list[j].Namespace
+		}
+		if list[i].Service != list[j].Service {
+			return list[i].Service < list[j].Service
+		}
+		return list[i].Address < list[j].Address
+	})
+	return list
+}
+
+// HasForced reports whether any instance of the service of the namespace is forced,
+// or ejected by the outlier detection, or held by the prober.
+func HasForced(namespace, service string) bool {
+	key := model.ServiceKey{Namespace: namespace, Service: service}
+	now := time.Now()
+	forces.mu.RLock()
+	for _, f := range forces.m[key] {
+		if now.Before(f.ExpireAt) {
+			forces.mu.RUnlock()
+			return true
+		}
+	}
+	forces.mu.RUnlock()
+	return hasEjected(key) || hasHeld(key)
+}
+
+// forcedState returns the forced state of the instance, or empty if it is not forced.
+// The instances ejected by the outlier detection or held by the prober are forced open
+// unless they are forced manually.
+func forcedState(key model.ServiceKey, address string) string {
+	forces.mu.RLock()
+	f, ok := forces.m[key][address]
+	forces.mu.RUnlock()
+	if ok && time.Now().Before(f.ExpireAt) {
+		return f.State
+	}
+	if ejected(key, address) || held(key, address) {
+		return ForceOpen
+	}
+	return ""
+}
+
+// removeExpired removes the expired forced states, forces.mu must be locked.
+func removeExpired(now time.Time) {
+	for key, instances := range forces.m {
+		for address, f := range instances {
+			if !now.Before(f.ExpireAt) {
+				delete(instances, address)
+			}
+		}
+		if len(instances) == 0 {
+			delete(forces.m, key)
+		}
+	}
+}
+
+// HasExcluded reports whether any instance of the service of the namespace may be excluded from the selection
+// of the method, that is HasForced, or a method or instance_method level breaker of the method is not closed.
+func HasExcluded(namespace, service, method string) bool {
+	return HasForced(namespace, service) || method != "" && methodBreakers.hasBroken(namespace, service, method)
+}
+
+// FilterInstances returns the instances of the service of the namespace for the load balancer to choose from
+// for the method, honoring the forced states and the method level breakers. The isolated instances and the ones
+// not allowed by the method or instance_method level breakers are removed, unless they are forced closed.
+// The forced open instances, including the ones ejected by the outlier detection and held by the prober,
+// are removed unless all the others are broken.
+// It returns false if the load balancer can not honor the forced states, that is a forced closed instance
+// is broken by polaris, and SelectInstance should be used instead with the instances returned.
+func FilterInstances(namespace, service, method string, instances []model.Instance) ([]model.Instance, bool) {
+	key := model.ServiceKey{Namespace: namespace, Service: service}
+	now := time.Now()
+	selectable := make([]model.Instance, 0, len(instances))
+	available := make([]model.Instance, 0, len(instances))
+	var (
+		open     []model.Instance
+		healthy  int
+		balanced = true
+	)
+	for _, inst := range instances {
+		addr := net.JoinHostPort(inst.GetHost(), strconv.Itoa(int(inst.GetPort())))
+		forced := forcedState(key, addr)
+		if forced == ForceIsolate ||
+			forced != ForceClose && method != "" && !methodBreakers.allows(namespace, service, method, addr, now) {
+			continue
+		}
+		selectable = append(selectable, inst)
+		state := instanceState(inst)
+		switch forced {
+		case ForceOpen:
+			open = append(open, inst)
+			continue
+		case ForceClose:
+			if state == StateOpen {
+				balanced = false
+			}
+		}
+		if state != StateOpen {
+			healthy++
+		}
+		available = append(available, inst)
+	}
+	if !balanced {
+		return selectable, false
+	}
+	if healthy == 0 {
+		available = append(available, open...)
+	}
+	return available, true
+}
+
+// SelectInstance selects an instance of the service of the namespace from the instances honoring the forced states.
+// The isolated instances are never selected. The forced open and the broken instances are only selected
+// if no other instance is available. The forced closed instances are available regardless of their states.
+// It selects by weighted random, or by the hash of hashKey if it is not empty, and returns nil if no instance.
+func SelectInstance(namespace, service string, instances []model.Instance, hashKey []byte) model.Instance {
+	key := model.ServiceKey{Namespace: namespace, Service: service}
+	available := make([]model.Instance, 0, len(instances))
+	var broken []model.Instance
+	for _, inst := range instances {
+		addr := net.JoinHostPort(inst.GetHost(), strconv.Itoa(int(inst.GetPort())))
+		switch forcedState(key, addr) {
+		case ForceIsolate:
+		case ForceOpen:
+			broken = append(broken, inst)
+		case ForceClose:
+			available = append(available, inst)
+		default:
+			if instanceState(inst) == StateOpen {
+				broken = append(broken, inst)
+			} else {
+				available = append(available, inst)
+			}
+		}
+	}
+	if len(available) == 0 {
+		available = broken
+	}
+	return chooseByWeight(available, hashKey)
+}
+
+func chooseByWeight(instances []model.Instance, hashKey []byte) model.Instance {
+	if len(instances) == 0 {
+		return nil
+	}
+	var total int
+	for _, inst := range instances {
+		total += inst.GetWeight()
+	}
+	if total <= 0 {
+		return instances[pick(len(instances), hashKey)]
+	}
+	n := pick(total, hashKey)
+	for _, inst := range instances {
+		if n -= inst.GetWeight(); n < 0 {
+			return inst
+		}
+	}
+	return instances[len(instances)-1]
+}
+
+// pick picks a number in [0, n) by the hash of hashKey, or randomly if hashKey is empty.
+func pick(n int, hashKey []byte) int {
+	if len(hashKey) == 0 {
+		return rand.Intn(n)
+	}
+	h := fnv.New32a()
+	_, _ = h.Write(hashKey)
+	return int(h.Sum32() % uint32(n))
+}
+
+// HandleForce is the admin handler of the forced states, registered at AdminPattern by Setup.
+//
+//	GET lists the forced states.
+//	POST forces the instance by the form values namespace, service, address, state and ttl, such as 10m.
+//	DELETE removes the forced state of the instance by the form values namespace, service and address.
+func HandleForce(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := r.ParseForm(); err != nil {
+		admin.ErrorOutput(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	namespace, service, address := r.Form.Get("namespace"), r.Form.Get("service"), r.Form.Get("address")
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodPut:
+		ttl, err := time.ParseDuration(r.Form.Get("ttl"))
+		if err != nil {
+			admin.ErrorOutput(w, fmt.Sprintf("invalid ttl: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := Force(namespace, service, address, r.Form.Get("state"), ttl); err != nil {
+			admin.ErrorOutput(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		Unforce(namespace, service, address)
+	default:
+		admin.ErrorOutput(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"errorcode": 0,
+		"message":   "",
+		"forced":    ListForced(),
+	})
+}
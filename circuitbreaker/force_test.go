@@ -0,0 +1,244 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package circuitbreaker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/naming/registry"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_api"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_model"
+
+	"github.com/golang/mock/gomock"
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForce(t *testing.T) {
+	assert.NotNil(t, Force("Test", "force", "127.0.0.1:1", "unknown", time.Minute))
+	assert.NotNil(t, Force("", "force", "127.0.0.1:1", ForceOpen, time.Minute))
+	assert.NotNil(t, Force("Test", "force", "127.0.0.1:1", ForceOpen, 0))
+	assert.False(t, HasForced("Test", "force"))
+
+	require.Nil(t, Force("Test", "force", "127.0.0.1:1", ForceOpen, time.Minute))
+	require.Nil(t, Force("Test", "force", "127.0.0.1:2", ForceIsolate, time.Millisecond))
+	assert.True(t, HasForced("Test", "force"))
+	assert.Equal(t, ForceOpen, forcedState(model.ServiceKey{Namespace: "Test", Service: "force"}, "127.0.0.1:1"))
+	assert.Equal(t, ForceIsolate, forcedState(model.ServiceKey{Namespace: "Test", Service: "force"}, "127.0.0.1:2"))
+
+	time.Sleep(time.Millisecond)
+	assert.Equal(t, "", forcedState(model.ServiceKey{Namespace: "Test", Service: "force"}, "127.0.0.1:2"), "expired")
+	var addrs []string
+	for _, f := range ListForced() {
+		if f.Service == "force" {
+			addrs = append(addrs, f.Address)
+		}
+	}
+	assert.Equal(t, []string{"127.0.0.1:1"}, addrs)
+
+	assert.False(t, HasForced("Development", "force"), "namespaces are separated")
+	Unforce("Test", "force", "127.0.0.1:1")
+	Unforce("Test", "force", "127.0.0.1:1")
+	assert.False(t, HasForced("Test", "force"))
+}
+
+func TestFilterInstances(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	newInstance := func(port uint32, status model.Status) model.Instance {
+		inst := mock_model.NewMockInstance(ctrl)
+		inst.EXPECT().GetHost().Return("127.0.0.1").AnyTimes()
+		inst.EXPECT().GetPort().Return(port).AnyTimes()
+		cbStatus := mock_model.NewMockCircuitBreakerStatus(ctrl)
+		cbStatus.EXPECT().GetStatus().Return(status).AnyTimes()
+		inst.EXPECT().GetCircuitBreakerStatus().Return(cbStatus).AnyTimes()
+		return inst
+	}
+	const service = "filter.instances"
+	a, b, broken := newInstance(1, model.Close), newInstance(2, model.Close), newInstance(3, model.Open)
+	instances := []model.Instance{a, b, broken}
+	defer Unforce("Test", service, "127.0.0.1:1")
+	defer Unforce("Test", service, "127.0.0.1:2")
+	defer Unforce("Test", service, "127.0.0.1:3")
+
+	filtered, ok := FilterInstances("Test", service, "", instances)
+	assert.True(t, ok)
+	assert.Equal(t, instances, filtered, "the broken instances are left to the load balancer")
+
+	t0 := thresholds{continuousErrorThreshold: 1, sleepWindow: time.Hour}
+	methodBreakers.get(StateChange{Namespace: "Test", Service: service, Method: "/instance", Address: "127.0.0.1:1"},
+		t0, time.Now()).report(true, time.Now())
+	methodBreakers.get(StateChange{Namespace: "Test", Service: service, Method: "/all"},
+		t0, time.Now()).report(true, time.Now())
+	assert.True(t, HasExcluded("Test", service, "/instance"))
+	filtered, ok = FilterInstances("Test", service, "/instance", instances)
+	assert.True(t, ok)
+	assert.Equal(t, []model.Instance{b, broken}, filtered, "the method is broken on the instance")
+	filtered, ok = FilterInstances("Test", service, "/all", instances)
+	assert.True(t, ok)
+	assert.Empty(t, filtered, "the method is broken on all instances")
+	filtered, ok = FilterInstances("Test", service, "/other", instances)
+	assert.True(t, ok)
+	assert.Equal(t, instances, filtered)
+
+	require.Nil(t, Force("Test", service, "127.0.0.1:1", ForceIsolate, time.Minute))
+	require.Nil(t, Force("Test", service, "127.0.0.1:2", ForceOpen, time.Minute))
+	filtered, ok = FilterInstances("Test", service, "", instances)
+	assert.True(t, ok)
+	assert.Equal(t, []model.Instance{broken, b}, filtered, "forced open is kept if all the others are broken")
+
+	require.Nil(t, Force("Test", service, "127.0.0.1:1", ForceOpen, time.Minute))
+	Unforce("Test", service, "127.0.0.1:2")
+	filtered, ok = FilterInstances("Test", service, "", instances)
+	assert.True(t, ok)
+	assert.Equal(t, []model.Instance{b, broken}, filtered)
+	filtered, ok = FilterInstances("Development", service, "", instances)
+	assert.True(t, ok)
+	assert.Equal(t, instances, filtered, "namespaces are separated")
+
+	require.Nil(t, Force("Test", service, "127.0.0.1:3", ForceClose, time.Minute))
+	_, ok = FilterInstances("Test", service, "", instances)
+	assert.False(t, ok, "the load balancer can not select the forced closed broken instance")
+}
+
+func TestSelectInstance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	newInstance := func(port uint32, status model.Status) model.Instance {
+		inst := mock_model.NewMockInstance(ctrl)
+		inst.EXPECT().GetHost().Return("127.0.0.1").AnyTimes()
+		inst.EXPECT().GetPort().Return(port).AnyTimes()
+		inst.EXPECT().GetWeight().Return(100).AnyTimes()
+		cbStatus := mock_model.NewMockCircuitBreakerStatus(ctrl)
+		cbStatus.EXPECT().GetStatus().Return(status).AnyTimes()
+		inst.EXPECT().GetCircuitBreakerStatus().Return(cbStatus).AnyTimes()
+		return inst
+	}
+	const service = "select.instance"
+	available := newInstance(1, model.Close)
+	broken := newInstance(2, model.Open)
+	instances := []model.Instance{available, broken}
+	defer Unforce("Test", service, "127.0.0.1:1")
+	defer Unforce("Test", service, "127.0.0.1:2")
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, available, SelectInstance("Test", service, instances, nil), "broken instance is not selected")
+	}
+
+	require.Nil(t, Force("Test", service, "127.0.0.1:1", ForceOpen, time.Minute))
+	assert.Equal(t, broken, SelectInstance("Test", service, instances, nil), "all are broken, select from them")
+
+	require.Nil(t, Force("Test", service, "127.0.0.1:2", ForceClose, time.Minute))
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, broken, SelectInstance("Test", service, instances, nil), "forced closed instance is available")
+	}
+
+	require.Nil(t, Force("Test", service, "127.0.0.1:2", ForceIsolate, time.Minute))
+	assert.Equal(t, available, SelectInstance("Test", service, instances, nil), "forced open is the last resort")
+
+	require.Nil(t, Force("Test", service, "127.0.0.1:1", ForceIsolate, time.Minute))
+	assert.Nil(t, SelectInstance("Test", service, instances, nil), "isolated instances are never selected")
+
+	Unforce("Test", service, "127.0.0.1:1")
+	Unforce("Test", service, "127.0.0.1:2")
+	healthy := []model.Instance{available, newInstance(3, model.Close), newInstance(4, model.HalfOpen)}
+	inst := SelectInstance("Test", service, healthy, []byte("key"))
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, inst, SelectInstance("Test", service, healthy, []byte("key")), "same hash key selects the same")
+	}
+}
+
+func TestAvailableForced(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	inst := mock_model.NewMockInstance(ctrl)
+	status := mock_model.NewMockCircuitBreakerStatus(ctrl)
+	inst.EXPECT().GetCircuitBreakerStatus().Return(status).AnyTimes()
+	status.EXPECT().IsAvailable().Return(false).AnyTimes()
+	cb := &CircuitBreaker{
+		consumer:   mock_api.NewMockConsumerAPI(ctrl),
+		classifier: newClassifier(nil, nil, nil),
+	}
+	const service = "available.forced"
+	defer Unforce("Test", service, "127.0.0.1:1")
+	node := &registry.Node{
+		ServiceName: service,
+		Address:     "127.0.0.1:1",
+		Metadata:    map[string]interface{}{"instance": inst, "namespace": "Test"},
+	}
+	assert.False(t, cb.Available(node))
+	require.Nil(t, Force("Test", service, "127.0.0.1:1", ForceClose, time.Minute))
+	assert.True(t, cb.Available(node))
+	require.Nil(t, Force("Test", service, "127.0.0.1:1", ForceIsolate, time.Minute))
+	assert.False(t, cb.Available(node))
+}
+
+func TestHandleForce(t *testing.T) {
+	const service = "handle.force"
+	defer Unforce("Test", service, "127.0.0.1:1")
+	do := func(method string, values url.Values) map[string]interface{} {
+		r := httptest.NewRequest(method, AdminPattern+"?"+values.Encode(), nil)
+		if method == http.MethodPost {
+			r = httptest.NewRequest(method, AdminPattern, strings.NewReader(values.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		w := httptest.NewRecorder()
+		HandleForce(w, r)
+		rsp := make(map[string]interface{})
+		require.Nil(t, json.Unmarshal(w.Body.Bytes(), &rsp))
+		return rsp
+	}
+
+	rsp := do(http.MethodPost, url.Values{
+		"namespace": {"Test"},
+		"service":   {service},
+		"address":   {"127.0.0.1:1"},
+		"state":     {ForceIsolate},
+		"ttl":       {"10m"},
+	})
+	assert.Equal(t, float64(0), rsp["errorcode"])
+	assert.Equal(t, ForceIsolate, forcedState(model.ServiceKey{Namespace: "Test", Service: service}, "127.0.0.1:1"))
+
+	rsp = do(http.MethodGet, nil)
+	var states []interface{}
+	for _, f := range rsp["forced"].([]interface{}) {
+		if f := f.(map[string]interface{}); f["namespace"] == "Test" && f["service"] == service {
+			states = append(states, f["state"])
+		}
+	}
+	assert.Equal(t, []interface{}{ForceIsolate}, states)
+
+	rsp = do(http.MethodPost, url.Values{"service": {service}, "address": {"127.0.0.1:1"}, "state": {ForceOpen}})
+	assert.NotEqual(t, float64(0), rsp["errorcode"], "invalid ttl")
+	rsp = do(http.MethodPost, url.Values{
+		"service": {service}, "address": {"127.0.0.1:1"}, "state": {ForceOpen}, "ttl": {"1m"}})
+	assert.NotEqual(t, float64(0), rsp["errorcode"], "empty namespace")
+	rsp = do(http.MethodPost, url.Values{"service": {service}, "state": {ForceOpen}, "ttl": {"1m"}})
+	assert.NotEqual(t, float64(0), rsp["errorcode"], "empty address")
+	rsp = do(http.MethodPatch, nil)
+	assert.NotEqual(t, float64(0), rsp["errorcode"])
+
+	do(http.MethodDelete, url.Values{"namespace": {"Test"}, "service": {service}, "address": {"127.0.0.1:1"}})
+	assert.Equal(t, "", forcedState(model.ServiceKey{Namespace: "Test", Service: service}, "127.0.0.1:1"))
+}
@@ -0,0 +1,398 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package circuitbreaker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/naming/registry"
+
+	"github.com/polarismesh/polaris-go/pkg/model"
+)
+
+// Levels of circuit breaking.
+const (
+	// LevelInstance breaks the instance by polaris, it is the default level.
+	LevelInstance = "instance"
+	// LevelMethod breaks the method of the callee service on all instances.
+	LevelMethod = "method"
+	// LevelInstanceMethod breaks the method on the instance.
+	LevelInstanceMethod = "instance_method"
+	// LevelAnyService is the key of Config.Levels which applies to all callee services without specific config.
+	LevelAnyService = "*"
+)
+
+// MethodKey is the key of node metadata of the called method.
+const MethodKey = "method"
+
+// Default thresholds of the method level breakers, which are the same as polaris.
+const (
+	defaultContinuousErrorThreshold  = 10
+	defaultSleepWindow               = 30 * time.Second
+	defaultRequestCountAfterHalfOpen = 10
+	defaultSuccessCountAfterHalfOpen = 8
+)
+
+// breakerIdleTimeout is the duration after which the method level breakers not used are evicted.
+const breakerIdleTimeout = 10 * time.Minute
+
+// CalleeMethod returns the called method read from the trpc message of ctx, or empty if ctx is nil.
+func CalleeMethod(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	return codec.Message(ctx).CalleeMethod()
+}
+
+// WithMethod sets the called method read from the trpc message of ctx into the node metadata.
+func WithMethod(ctx context.Context, node *registry.Node) {
+	method := CalleeMethod(ctx)
+	if method == "" {
+		return
+	}
+	if node.Metadata == nil {
+		node.Metadata = make(map[string]interface{})
+	}
+	node.Metadata[MethodKey] = method
+}
+
+// nodeMethod returns the called method of the node.
+func nodeMethod(node *registry.Node) string {
+	method, _ := node.Metadata[MethodKey].(string)
+	return method
+}
+
+func validLevel(level string) error {
+	switch level {
+	case LevelInstance, LevelMethod, LevelInstanceMethod:
+		return nil
+	default:
+		return fmt.Errorf("unknown circuit breaking level %s", level)
+	}
+}
+
+// thresholds are the thresholds of local breakers.
+type thresholds struct {
+	continuousErrorThreshold  int
+	sleepWindow               time.Duration
+	requestCountAfterHalfOpen int
+	successCountAfterHalfOpen int
+}
+
+// withDefault returns the thresholds with the non-positive values replaced by those of d.
+func (t thresholds) withDefault(d thresholds) thresholds {
+	if t.continuousErrorThreshold <= 0 {
+		t.continuousErrorThreshold = d.continuousErrorThreshold
+	}
+	if t.sleepWindow <= 0 {
+		t.sleepWindow = d.sleepWindow
+	}
+	if t.requestCountAfterHalfOpen <= 0 {
+		t.requestCountAfterHalfOpen = d.requestCountAfterHalfOpen
+	}
+	if t.successCountAfterHalfOpen <= 0 {
+		t.successCountAfterHalfOpen = d.successCountAfterHalfOpen
+	}
+	return t
+}
+
+// breaker is a local circuit breaker by continuous errors, it is safe for concurrent use.
+type breaker struct {
+	thresholds
+	// id identifies the breaker in the state change events.
+	id StateChange
+	// bs counts the breaker if it is not closed, nil if it is not got from breakers.
+	bs *breakers
+
+	mu                sync.Mutex
+	state             State
+	continuousErrors  int
+	openedAt          time.Time
+	halfOpenRequests  int
+	halfOpenSuccesses int
+	usedAt            time.Time
+}
+
+// allows reports whether a request is allowed like available, without counting the request.
+func (b *breaker) allows(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateOpen:
+		return now.Sub(b.openedAt) >= b.sleepWindow
+	case StateHalfOpen:
+		return b.halfOpenRequests < b.requestCountAfterHalfOpen
+	default:
+		return true
+	}
+}
+
+// available reports whether a request is allowed.
+// An open breaker turns half open after the sleep window, and allows limited requests.
+func (b *breaker) available(now time.Time) bool {
+	from := b.lock()
+	defer b.unlock(from)
+	b.usedAt = now
+	if b.state == StateOpen {
+		if now.Sub(b.openedAt) < b.sleepWindow {
+			return false
+		}
+		b.state = StateHalfOpen
+		b.halfOpenRequests, b.halfOpenSuccesses = 0, 0
+	}
+	if b.state == StateHalfOpen {
+		if b.halfOpenRequests >= b.requestCountAfterHalfOpen {
+			return false
+		}
+		b.halfOpenRequests++
+	}
+	return true
+}
+
+// report reports the result of a request.
+func (b *breaker) report(fail bool, now time.Time) {
+	from := b.lock()
+	defer b.unlock(from)
+	b.usedAt = now
+	switch b.state {
+	case StateClosed:
+		if !fail {
+			b.continuousErrors = 0
+			return
+		}
+		if b.continuousErrors++; b.continuousErrors >= b.continuousErrorThreshold {
+			b.open(now)
+		}
+	case StateHalfOpen:
+		if fail {
+			b.open(now)
+			return
+		}
+		if b.halfOpenSuccesses++; b.halfOpenSuccesses >= b.successCountAfterHalfOpen {
+			b.state = StateClosed
+			b.continuousErrors = 0
+		}
+	}
+}
+
+// lock locks the breaker and returns the state before.
+func (b *breaker) lock() State {
+	b.mu.Lock()
+	return b.state
+}
+
+// unlock unlocks the breaker, and notifies the state change from the state before outside the lock.
+func (b *breaker) unlock(from State) {
+	to := b.state
+	b.mu.Unlock()
+	if from != to {
+		b.bs.changed(b, from, to)
+		e := b.id
+		e.From, e.To = from, to
+		notifyStateChange(e)
+	}
+}
+
+// idle reports whether the breaker has not been used for timeout, and is not open in the sleep window,
+// so that evicting it loses no state.
+func (b *breaker) idle(now time.Time, timeout time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.Sub(b.usedAt) < timeout {
+		return false
+	}
+	return b.state != StateOpen || now.Sub(b.openedAt) >= b.sleepWindow
+}
+
+func (b *breaker) open(now time.Time) {
+	b.state = StateOpen
+	b.openedAt = now
+	b.continuousErrors = 0
+}
+
+// breakers are the local breakers by their ids, it is safe for concurrent use.
+// The idle breakers are evicted every idleTimeout when a breaker is got.
+type breakers struct {
+	idleTimeout time.Duration
+
+	mu sync.RWMutex
+	m  map[StateChange]*breaker
+	// broken counts the breakers not closed by the method, which is the id without the address.
+	broken  map[StateChange]int
+	sweptAt time.Time
+}
+
+// methodBreakers are the method level breakers, they are checked when the instances are selected
+// by Selector.Select and WRLoadBalancer.Select, like the forced states.
+var methodBreakers = newBreakers()
+
+func newBreakers() *breakers {
+	return &breakers{
+		idleTimeout: breakerIdleTimeout,
+		m:           make(map[StateChange]*breaker),
+		broken:      make(map[StateChange]int),
+		sweptAt:     time.Now(),
+	}
+}
+
+// methodOf returns the id of the method of the breaker.
+func methodOf(id StateChange) StateChange {
+	return StateChange{Namespace: id.Namespace, Service: id.Service, Method: id.Method}
+}
+
+// changed counts the breaker b by its state change.
+func (bs *breakers) changed(b *breaker, from, to State) {
+	if bs == nil || from != StateClosed && to != StateClosed {
+		return
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if bs.m[b.id] != b {
+		// The breaker has been evicted.
+		return
+	}
+	if from == StateClosed {
+		bs.broken[methodOf(b.id)]++
+		return
+	}
+	bs.uncount(b.id)
+}
+
+// uncount uncounts the broken breaker identified by id, bs.mu must be locked.
+func (bs *breakers) uncount(id StateChange) {
+	key := methodOf(id)
+	if bs.broken[key]--; bs.broken[key] <= 0 {
+		delete(bs.broken, key)
+	}
+}
+
+// hasBroken reports whether any breaker of the method is not closed.
+func (bs *breakers) hasBroken(namespace, service, method string) bool {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+	return bs.broken[StateChange{Namespace: namespace, Service: service, Method: method}] > 0
+}
+
+// lookup returns the breaker identified by id, or nil if it does not exist.
+func (bs *breakers) lookup(id StateChange) *breaker {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+	return bs.m[id]
+}
+
+// allows reports whether the method of the service of the namespace on the instance at the address
+// is allowed by the method and the instance_method level breakers, without counting the request.
+func (bs *breakers) allows(namespace, service, method, address string, now time.Time) bool {
+	id := StateChange{Namespace: namespace, Service: service, Method: method}
+	if b := bs.lookup(id); b != nil && !b.allows(now) {
+		return false
+	}
+	id.Address = address
+	b := bs.lookup(id)
+	return b == nil || b.allows(now)
+}
+
+// get returns the breaker identified by id, and creates one by t if it does not exist.
+func (bs *breakers) get(id StateChange, t thresholds, now time.Time) *breaker {
+	bs.mu.RLock()
+	b, ok := bs.m[id]
+	sweep := now.Sub(bs.sweptAt) >= bs.idleTimeout
+	bs.mu.RUnlock()
+	if ok && !sweep {
+		return b
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if now.Sub(bs.sweptAt) >= bs.idleTimeout {
+		bs.sweptAt = now
+		for key, b := range bs.m {
+			if b.idle(now, bs.idleTimeout) {
+				delete(bs.m, key)
+				if b.lock() != StateClosed {
+					bs.uncount(key)
+				}
+				b.mu.Unlock()
+			}
+		}
+	}
+	if b, ok = bs.m[id]; !ok {
+		b = &breaker{thresholds: t, id: id, bs: bs, usedAt: now}
+		bs.m[id] = b
+	}
+	return b
+}
+
+// level returns the circuit breaking level of the callee service.
+func (cb *CircuitBreaker) level(service string) string {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+	if level, ok := cb.levels[service]; ok {
+		return level
+	}
+	if level, ok := cb.levels[LevelAnyService]; ok {
+		return level
+	}
+	return LevelInstance
+}
+
+// thresholdsOf returns the thresholds of the method level breakers of the callee service.
+func (cb *CircuitBreaker) thresholdsOf(service string) thresholds {
+	if t, ok := cb.services[service]; ok {
+		return t
+	}
+	return cb.thresholds
+}
+
+// methodBreaker returns the local breaker of the node, or nil if the node is broken at instance level.
+func (cb *CircuitBreaker) methodBreaker(node *registry.Node, now time.Time) *breaker {
+	method := nodeMethod(node)
+	if method == "" {
+		return nil
+	}
+	namespace, _ := node.Metadata["namespace"].(string)
+	id := StateChange{Namespace: namespace, Service: node.ServiceName, Method: method}
+	switch cb.level(node.ServiceName) {
+	case LevelMethod:
+	case LevelInstanceMethod:
+		id.Address = node.Address
+	default:
+		return nil
+	}
+	return methodBreakers.get(id, cb.thresholdsOf(node.ServiceName), now)
+}
+
+// Selected counts the request of the method of the service of the namespace to the selected instance
+// by the method and the instance_method level breakers, which limit the requests when they are half open.
+// It is called by Selector.Select and WRLoadBalancer.Select after the instance is selected from FilterInstances.
+func Selected(namespace, service, method string, inst model.Instance) {
+	if method == "" {
+		return
+	}
+	address := net.JoinHostPort(inst.GetHost(), strconv.Itoa(int(inst.GetPort())))
+	now := time.Now()
+	id := StateChange{Namespace: namespace, Service: service, Method: method}
+	if b := methodBreakers.lookup(id); b != nil {
+		b.available(now)
+	}
+	id.Address = address
+	if b := methodBreakers.lookup(id); b != nil {
+		b.available(now)
+	}
+}
@@ -0,0 +1,185 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package circuitbreaker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/errs"
+	"trpc.group/trpc-go/trpc-go/naming/registry"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_api"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_model"
+
+	"github.com/golang/mock/gomock"
+	"github.com/polarismesh/polaris-go/api"
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	b := &breaker{thresholds: thresholds{
+		continuousErrorThreshold:  2,
+		sleepWindow:               time.Second,
+		requestCountAfterHalfOpen: 2,
+		successCountAfterHalfOpen: 2,
+	}}
+	now := time.Now()
+	b.report(true, now)
+	b.report(false, now)
+	b.report(true, now)
+	assert.True(t, b.available(now), "success resets continuous errors")
+	b.report(true, now)
+	assert.False(t, b.available(now))
+
+	now = now.Add(time.Second)
+	assert.True(t, b.available(now))
+	assert.True(t, b.available(now))
+	assert.False(t, b.available(now), "half open allows limited requests")
+	b.report(true, now)
+	assert.False(t, b.available(now), "failure reopens the half open breaker")
+
+	now = now.Add(time.Second)
+	assert.True(t, b.available(now))
+	b.report(false, now)
+	b.report(false, now)
+	assert.Equal(t, StateClosed, b.state)
+	assert.True(t, b.available(now))
+}
+
+func TestMethodLevel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var results []*api.ServiceCallResult
+	consumer := mock_api.NewMockConsumerAPI(ctrl)
+	consumer.EXPECT().UpdateServiceCallResult(gomock.Any()).DoAndReturn(func(r *api.ServiceCallResult) error {
+		results = append(results, r)
+		return nil
+	}).AnyTimes()
+	inst := mock_model.NewMockInstance(ctrl)
+	inst.EXPECT().GetCircuitBreakerStatus().Return(nil).AnyTimes()
+
+	cfg := &Config{
+		Levels: map[string]string{
+			"method.level":   LevelMethod,
+			LevelAnyService:  LevelInstanceMethod,
+			"instance.level": LevelInstance,
+		},
+		ContinuousErrorThreshold: 1,
+	}
+	cb := &CircuitBreaker{
+		consumer:   consumer,
+		classifier: newClassifier(nil, nil, nil),
+		levels:     cfg.Levels,
+		thresholds: cfg.thresholds(),
+		services:   cfg.serviceThresholds(),
+	}
+	newNode := func(service, addr, method string) *registry.Node {
+		ctx, msg := codec.WithNewMessage(context.Background())
+		msg.WithCalleeMethod(method)
+		node := &registry.Node{
+			ServiceName: service,
+			Address:     addr,
+			Metadata:    map[string]interface{}{"instance": inst},
+		}
+		WithMethod(ctx, node)
+		return node
+	}
+	fail := errs.NewFrameError(errs.RetClientNetErr, "")
+
+	require.Nil(t, cb.Report(newNode("method.level", "a", "/slow"), time.Second, fail))
+	require.Equal(t, "/slow", results[0].Method)
+	assert.Equal(t, model.RetFail, results[0].RetStatus, "the failures are reported to polaris as well")
+	assert.False(t, cb.Available(newNode("method.level", "b", "/slow")), "method is broken on all instances")
+	assert.True(t, HasExcluded("", "method.level", "/slow"))
+	assert.False(t, HasExcluded("", "method.level", "/fast"))
+	assert.True(t, cb.Available(newNode("method.level", "a", "/fast")))
+
+	require.Nil(t, cb.Report(newNode("other.level", "a", "/slow"), time.Second, fail))
+	assert.False(t, cb.Available(newNode("other.level", "a", "/slow")))
+	assert.True(t, cb.Available(newNode("other.level", "b", "/slow")), "method is broken on the instance only")
+	assert.True(t, HasExcluded("", "other.level", "/slow"))
+
+	require.Nil(t, cb.Report(newNode("instance.level", "a", "/slow"), time.Second, fail))
+	assert.Equal(t, model.RetFail, results[2].RetStatus)
+	assert.True(t, cb.Available(newNode("instance.level", "a", "/slow")), "instance is broken by polaris")
+
+	nsNode := newNode("method.level", "b", "/slow")
+	nsNode.Metadata["namespace"] = "Development"
+	assert.True(t, cb.Available(nsNode), "breakers of different namespaces are separated")
+
+	node := &registry.Node{ServiceName: "method.level", Metadata: map[string]interface{}{"instance": inst}}
+	WithMethod(nil, node)
+	assert.True(t, cb.Available(node), "no method falls back to instance level")
+}
+
+func TestBreakersEvict(t *testing.T) {
+	bs := newBreakers()
+	t0 := thresholds{continuousErrorThreshold: 1, sleepWindow: time.Hour}
+	now := time.Now()
+	idle := bs.get(StateChange{Service: "svc", Method: "/idle"}, t0, now)
+	open := bs.get(StateChange{Service: "svc", Method: "/open"}, t0, now)
+	open.report(true, now)
+	assert.True(t, bs.hasBroken("", "svc", "/open"))
+	used := bs.get(StateChange{Service: "svc", Method: "/used"}, t0, now)
+
+	now = now.Add(bs.idleTimeout / 2)
+	used.report(false, now)
+	assert.Same(t, idle, bs.get(StateChange{Service: "svc", Method: "/idle"}, t0, now), "not swept before idleTimeout")
+
+	now = now.Add(bs.idleTimeout/2 + time.Second)
+	bs.get(StateChange{Service: "svc", Method: "/new"}, t0, now)
+	assert.Len(t, bs.m, 3, "the idle breaker is evicted")
+	assert.Same(t, open, bs.get(StateChange{Service: "svc", Method: "/open"}, t0, now), "open in sleep window is kept")
+	assert.Same(t, used, bs.get(StateChange{Service: "svc", Method: "/used"}, t0, now))
+	assert.NotSame(t, idle, bs.get(StateChange{Service: "svc", Method: "/idle"}, t0, now))
+
+	now = now.Add(time.Hour + bs.idleTimeout)
+	bs.get(StateChange{Service: "svc", Method: "/new"}, t0, now)
+	assert.False(t, bs.hasBroken("", "svc", "/open"), "the evicted open breaker is not counted")
+}
+
+func TestServiceThresholds(t *testing.T) {
+	cfg := &Config{
+		ContinuousErrorThreshold: 5,
+		Services: map[string]Thresholds{
+			"flaky": {ContinuousErrorThreshold: 50, SleepWindow: time.Second},
+		},
+	}
+	cb := &CircuitBreaker{thresholds: cfg.thresholds(), services: cfg.serviceThresholds()}
+	assert.Equal(t, thresholds{
+		continuousErrorThreshold:  50,
+		sleepWindow:               time.Second,
+		requestCountAfterHalfOpen: defaultRequestCountAfterHalfOpen,
+		successCountAfterHalfOpen: defaultSuccessCountAfterHalfOpen,
+	}, cb.thresholdsOf("flaky"))
+	assert.Equal(t, thresholds{
+		continuousErrorThreshold:  5,
+		sleepWindow:               defaultSleepWindow,
+		requestCountAfterHalfOpen: defaultRequestCountAfterHalfOpen,
+		successCountAfterHalfOpen: defaultSuccessCountAfterHalfOpen,
+	}, cb.thresholdsOf("critical"))
+	assert.Nil(t, (&Config{}).serviceThresholds())
+}
+
+func TestSetupWithInvalidLevel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := mock_api.NewMockSDKContext(ctrl)
+	assert.NotNil(t, Setup(m, &Config{Levels: map[string]string{"svc": "unknown"}}, false))
+}
@@ -0,0 +1,320 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package circuitbreaker
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/log"
+	"trpc.group/trpc-go/trpc-go/naming/registry"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/metrics"
+
+	"github.com/polarismesh/polaris-go/api"
+	"github.com/polarismesh/polaris-go/pkg/model"
+)
+
+// Latency statistics of the outlier detection.
+const (
+	// StatMean compares the mean latency of the instances.
+	StatMean = "mean"
+	// StatP99 compares the p99 latency of the instances.
+	StatP99 = "p99"
+)
+
+// Defaults of the outlier detection.
+const (
+	defaultOutlierRatio              = 3
+	defaultOutlierWindow             = time.Minute
+	defaultOutlierMinRequests        = 10
+	defaultOutlierEjectDuration      = 30 * time.Second
+	defaultOutlierMaxEjectionPercent = 10
+	// minOutlierInstances is the minimum number of instances with enough requests to detect outliers.
+	minOutlierInstances = 3
+	// maxLatencySamples is the maximum number of latency samples kept for each instance in a window.
+	maxLatencySamples = 1000
+)
+
+// OutlierConfig is the configuration of the latency based outlier detection.
+//
+// In every window, the instances whose latency statistic is more than Ratio times the median of the service
+// are ejected for EjectDuration, which are only selected if no other instance is available.
+// At most MaxEjectionPercent of the instances of the service are ejected, but at least one.
+type OutlierConfig struct {
+	// Enable enables the outlier detection.
+	Enable bool `yaml:"enable"`
+	// Stat is the latency statistic to compare, one of mean and p99, default as mean.
+	Stat string `yaml:"stat"`
+	// Ratio is the ratio to the median of the service to be an outlier, default as 3.
+	Ratio float64 `yaml:"ratio"`
+	// Window is the window of the latency statistics, default as 1m.
+	Window time.Duration `yaml:"window"`
+	// MinRequests is the minimum number of successful requests of an instance in the window
+	// to be judged, default as 10.
+	MinRequests int `yaml:"min_requests"`
+	// EjectDuration is the duration of the ejection, default as 30s.
+	EjectDuration time.Duration `yaml:"eject_duration"`
+	// MaxEjectionPercent is the max percent of the ejected instances of the service, default as 10.
+	MaxEjectionPercent int `yaml:"max_ejection_percent"`
+}
+
+// Validate checks the config without changing it.
+func (c OutlierConfig) Validate() error {
+	return c.normalize()
+}
+
+func (c *OutlierConfig) normalize() error {
+	switch c.Stat {
+	case "":
+		c.Stat = StatMean
+	case StatMean, StatP99:
+	default:
+		return fmt.Errorf("unknown outlier detection stat %s", c.Stat)
+	}
+	if c.Ratio <= 0 {
+		c.Ratio = defaultOutlierRatio
+	}
+	if c.Window <= 0 {
+		c.Window = defaultOutlierWindow
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = defaultOutlierMinRequests
+	}
+	if c.EjectDuration <= 0 {
+		c.EjectDuration = defaultOutlierEjectDuration
+	}
+	if c.MaxEjectionPercent <= 0 {
+		c.MaxEjectionPercent = defaultOutlierMaxEjectionPercent
+	}
+	return nil
+}
+
+// DetectOutliers enables the latency based outlier detection of the results reported by the classifier.
+// It does nothing if cfg is nil or not enabled.
+func (c *Classifier) DetectOutliers(cfg *OutlierConfig) error {
+	if cfg == nil || !cfg.Enable {
+		return nil
+	}
+	normalized := *cfg
+	if err := normalized.normalize(); err != nil {
+		return err
+	}
+	c.outliers = newOutlierDetector(normalized)
+	return nil
+}
+
+// latencies are the latency samples of an instance in a window.
+type latencies struct {
+	count   int
+	sum     time.Duration
+	samples []time.Duration
+}
+
+func (l *latencies) add(cost time.Duration) {
+	l.count++
+	l.sum += cost
+	if len(l.samples) < maxLatencySamples {
+		l.samples = append(l.samples, cost)
+		return
+	}
+	// Reservoir sampling.
+	if i := rand.Intn(l.count); i < maxLatencySamples {
+		l.samples[i] = cost
+	}
+}
+
+func (l *latencies) stat(stat string) time.Duration {
+	if stat == StatP99 {
+		sort.Slice(l.samples, func(i, j int) bool { return l.samples[i] < l.samples[j] })
+		return l.samples[int(math.Ceil(float64(len(l.samples))*0.99))-1]
+	}
+	return l.sum / time.Duration(l.count)
+}
+
+// serviceLatencies are the latencies of the instances of a service in a window.
+type serviceLatencies struct {
+	start     time.Time
+	instances map[string]*latencies
+}
+
+// outlierDetector detects the latency outliers, it is safe for concurrent use.
+type outlierDetector struct {
+	cfg OutlierConfig
+
+	mu       sync.Mutex
+	services map[model.ServiceKey]*serviceLatencies
+}
+
+func newOutlierDetector(cfg OutlierConfig) *outlierDetector {
+	return &outlierDetector{cfg: cfg, services: make(map[model.ServiceKey]*serviceLatencies)}
+}
+
+// observe records the latency of a successful request to the node,
+// and detects the outliers of the service at the end of the window.
+// consumer gets the instances of the service to limit the ejections.
+func (d *outlierDetector) observe(consumer api.ConsumerAPI, node *registry.Node, cost time.Duration, now time.Time) {
+	if d == nil || node.ServiceName == "" || node.Address == "" {
+		return
+	}
+	namespace, _ := node.Metadata["namespace"].(string)
+	key := model.ServiceKey{Namespace: namespace, Service: node.ServiceName}
+	d.mu.Lock()
+	s, ok := d.services[key]
+	if !ok {
+		s = &serviceLatencies{start: now, instances: make(map[string]*latencies)}
+		d.services[key] = s
+	}
+	var ended *serviceLatencies
+	if now.Sub(s.start) >= d.cfg.Window {
+		ended = s
+		s = &serviceLatencies{start: now, instances: make(map[string]*latencies)}
+		d.services[key] = s
+	}
+	l, ok := s.instances[node.Address]
+	if !ok {
+		l = &latencies{}
+		s.instances[node.Address] = l
+	}
+	l.add(cost)
+	d.mu.Unlock()
+
+	if ended != nil {
+		d.detect(consumer, key, ended.instances, now)
+	}
+}
+
+// detect ejects the outliers of the service by the latencies of the ended window.
+func (d *outlierDetector) detect(
+	consumer api.ConsumerAPI, key model.ServiceKey, instances map[string]*latencies, now time.Time) {
+	type instanceStat struct {
+		address string
+		stat    time.Duration
+	}
+	stats := make([]instanceStat, 0, len(instances))
+	for address, l := range instances {
+		if l.count >= d.cfg.MinRequests {
+			stats = append(stats, instanceStat{address: address, stat: l.stat(d.cfg.Stat)})
+		}
+	}
+	if len(stats) < minOutlierInstances {
+		return
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].stat > stats[j].stat })
+	median := stats[len(stats)/2].stat
+	if len(stats)%2 == 0 {
+		median = (stats[len(stats)/2-1].stat + median) / 2
+	}
+	limit := instanceCount(consumer, key, len(instances)) * d.cfg.MaxEjectionPercent / 100
+	if limit < 1 {
+		limit = 1
+	}
+	limit -= ejectedCount(key, now)
+	threshold := time.Duration(float64(median) * d.cfg.Ratio)
+	for _, s := range stats {
+		if limit <= 0 || s.stat <= threshold {
+			return
+		}
+		if eject(key, s.address, now, now.Add(d.cfg.EjectDuration)) {
+			log.Warnf("[NAMING-POLARISMESH] instance %s of service %s of namespace %s is ejected for %s, "+
+				"its %s latency %s is more than %v times the median %s",
+				s.address, key.Service, key.Namespace, d.cfg.EjectDuration, d.cfg.Stat, s.stat, d.cfg.Ratio, median)
+			metrics.ReportOutlierEjection(key.Namespace, key.Service, s.address)
+			limit--
+		}
+	}
+}
+
+// instanceCount returns the number of all instances of the service,
+// or n if it fails to get the instances or there are fewer.
+func instanceCount(consumer api.ConsumerAPI, key model.ServiceKey, n int) int {
+	if consumer == nil {
+		return n
+	}
+	resp, err := consumer.GetAllInstances(&api.GetAllInstancesRequest{
+		GetAllInstancesRequest: model.GetAllInstancesRequest{
+			Service:   key.Service,
+			Namespace: key.Namespace,
+		},
+	})
+	if err != nil {
+		log.Warnf("[NAMING-POLARISMESH] get instances of service %s of namespace %s to limit ejections err: %v",
+			key.Service, key.Namespace, err)
+		return n
+	}
+	if total := len(resp.GetInstances()); total > n {
+		return total
+	}
+	return n
+}
+
+var ejections = struct {
+	mu sync.RWMutex
+	m  map[model.ServiceKey]map[string]time.Time // service -> address -> expiration.
+}{m: make(map[model.ServiceKey]map[string]time.Time)}
+
+// eject ejects the instance until expireAt, it returns false if the instance has been ejected.
+func eject(key model.ServiceKey, address string, now, expireAt time.Time) bool {
+	ejections.mu.Lock()
+	defer ejections.mu.Unlock()
+	instances := ejections.m[key]
+	if instances == nil {
+		instances = make(map[string]time.Time)
+		ejections.m[key] = instances
+	}
+	if now.Before(instances[address]) {
+		return false
+	}
+	instances[address] = expireAt
+	return true
+}
+
+// ejected reports whether the instance is ejected.
+func ejected(key model.ServiceKey, address string) bool {
+	ejections.mu.RLock()
+	defer ejections.mu.RUnlock()
+	return time.Now().Before(ejections.m[key][address])
+}
+
+// hasEjected reports whether any instance of the service is ejected.
+func hasEjected(key model.ServiceKey) bool {
+	now := time.Now()
+	ejections.mu.RLock()
+	defer ejections.mu.RUnlock()
+	for _, expireAt := range ejections.m[key] {
+		if now.Before(expireAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// ejectedCount returns the number of the ejected instances of the service, and removes the expired ones.
+func ejectedCount(key model.ServiceKey, now time.Time) int {
+	ejections.mu.Lock()
+	defer ejections.mu.Unlock()
+	instances := ejections.m[key]
+	for address, expireAt := range instances {
+		if !now.Before(expireAt) {
+			delete(instances, address)
+		}
+	}
+	if len(instances) == 0 {
+		delete(ejections.m, key)
+	}
+	return len(instances)
+}
@@ -0,0 +1,203 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package circuitbreaker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/errs"
+	"trpc.group/trpc-go/trpc-go/naming/registry"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_api"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_model"
+
+	"github.com/golang/mock/gomock"
+	"github.com/polarismesh/polaris-go/api"
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestOutlierConfig(t *testing.T) {
+	var cfg OutlierConfig
+	require.Nil(t, yaml.Unmarshal([]byte(`
+enable: true
+stat: p99
+ratio: 5
+window: 10s
+min_requests: 20
+eject_duration: 1m
+max_ejection_percent: 30
+`), &cfg))
+	assert.Equal(t, OutlierConfig{
+		Enable:             true,
+		Stat:               StatP99,
+		Ratio:              5,
+		Window:             10 * time.Second,
+		MinRequests:        20,
+		EjectDuration:      time.Minute,
+		MaxEjectionPercent: 30,
+	}, cfg)
+
+	cfg = OutlierConfig{}
+	require.Nil(t, cfg.normalize())
+	assert.Equal(t, OutlierConfig{
+		Stat:               StatMean,
+		Ratio:              defaultOutlierRatio,
+		Window:             defaultOutlierWindow,
+		MinRequests:        defaultOutlierMinRequests,
+		EjectDuration:      defaultOutlierEjectDuration,
+		MaxEjectionPercent: defaultOutlierMaxEjectionPercent,
+	}, cfg)
+
+	c := newClassifier(nil, nil, nil)
+	require.Nil(t, c.DetectOutliers(nil))
+	require.Nil(t, c.DetectOutliers(&OutlierConfig{Stat: "unknown"}), "disabled")
+	assert.Nil(t, c.outliers)
+	assert.NotNil(t, c.DetectOutliers(&OutlierConfig{Enable: true, Stat: "unknown"}))
+}
+
+func TestLatencies(t *testing.T) {
+	var l latencies
+	for i := 1; i <= 2*maxLatencySamples; i++ {
+		l.add(time.Duration(i) * time.Millisecond)
+	}
+	assert.Len(t, l.samples, maxLatencySamples)
+	assert.Equal(t, time.Duration(2*maxLatencySamples+1)*time.Millisecond/2, l.stat(StatMean))
+
+	l = latencies{}
+	for i := 1; i <= 100; i++ {
+		l.add(time.Duration(i) * time.Millisecond)
+	}
+	assert.Equal(t, 99*time.Millisecond, l.stat(StatP99))
+}
+
+func TestOutlierDetection(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	key := model.ServiceKey{Namespace: "Test", Service: "outlier.detection"}
+	d := newOutlierDetector(OutlierConfig{
+		Stat:               StatMean,
+		Ratio:              3,
+		Window:             time.Minute,
+		MinRequests:        2,
+		EjectDuration:      time.Minute,
+		MaxEjectionPercent: 20,
+	})
+	costs := map[string]time.Duration{
+		"127.0.0.1:1": 10 * time.Millisecond,
+		"127.0.0.1:2": 12 * time.Millisecond,
+		"127.0.0.1:3": 11 * time.Millisecond,
+		"127.0.0.1:4": 50 * time.Millisecond,
+		"127.0.0.1:5": 100 * time.Millisecond,
+		"127.0.0.1:6": 100 * time.Second, // Not enough requests to be judged.
+	}
+	newNode := func(addr string) *registry.Node {
+		return &registry.Node{
+			ServiceName: key.Service,
+			Address:     addr,
+			Metadata:    map[string]interface{}{"namespace": key.Namespace},
+		}
+	}
+	now := time.Now()
+	for addr, cost := range costs {
+		n := 2
+		if addr == "127.0.0.1:6" {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			d.observe(nil, newNode(addr), cost, now)
+		}
+	}
+	assert.False(t, HasForced(key.Namespace, key.Service), "window is not ended")
+
+	consumer := mock_api.NewMockConsumerAPI(ctrl)
+	consumer.EXPECT().GetAllInstances(gomock.Any()).Return(nil, errors.New("not found"))
+	d.observe(consumer, newNode("127.0.0.1:1"), time.Millisecond, now.Add(time.Minute))
+	assert.True(t, HasForced(key.Namespace, key.Service))
+	assert.False(t, HasForced("Development", key.Service), "namespaces are separated")
+	assert.Equal(t, ForceOpen, forcedState(key, "127.0.0.1:5"), "the worst one is ejected")
+	assert.Equal(t, "", forcedState(key, "127.0.0.1:4"), "at most 20% are ejected")
+	assert.Equal(t, "", forcedState(key, "127.0.0.1:6"))
+
+	require.Nil(t, Force(key.Namespace, key.Service, "127.0.0.1:5", ForceClose, time.Minute))
+	assert.Equal(t, ForceClose, forcedState(key, "127.0.0.1:5"), "forced manually takes precedence")
+	Unforce(key.Namespace, key.Service, "127.0.0.1:5")
+
+	// The ejected instance is counted in the max ejection percent.
+	windows := map[string]*latencies{
+		"127.0.0.1:1": {count: 2, sum: 20 * time.Millisecond},
+		"127.0.0.1:2": {count: 2, sum: 20 * time.Millisecond},
+		"127.0.0.1:3": {count: 2, sum: 20 * time.Millisecond},
+		"127.0.0.1:4": {count: 2, sum: 200 * time.Millisecond},
+	}
+	d.detect(nil, key, windows, now.Add(time.Minute))
+	assert.Equal(t, "", forcedState(key, "127.0.0.1:4"))
+
+	// The max ejection percent is of all instances of the service, not only those with requests.
+	consumer.EXPECT().GetAllInstances(gomock.Any()).DoAndReturn(
+		func(req *api.GetAllInstancesRequest) (*model.InstancesResponse, error) {
+			assert.Equal(t, key.Namespace, req.Namespace)
+			assert.Equal(t, key.Service, req.Service)
+			return &model.InstancesResponse{Instances: make([]model.Instance, 10)}, nil
+		})
+	d.detect(consumer, key, windows, now.Add(time.Minute))
+	assert.Equal(t, ForceOpen, forcedState(key, "127.0.0.1:4"))
+
+	ejections.mu.Lock()
+	delete(ejections.m, key)
+	ejections.mu.Unlock()
+	assert.False(t, HasForced(key.Namespace, key.Service))
+}
+
+func TestReportWithOutlierDetection(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	consumer := mock_api.NewMockConsumerAPI(ctrl)
+	consumer.EXPECT().UpdateServiceCallResult(gomock.Any()).Return(nil).AnyTimes()
+	c := newClassifier(nil, nil, nil)
+	require.Nil(t, c.DetectOutliers(&OutlierConfig{Enable: true, MinRequests: 1}))
+	cb := &CircuitBreaker{consumer: consumer, classifier: c}
+
+	inst := mock_model.NewMockInstance(ctrl)
+	for i, report := range []func(*registry.Node, time.Duration, error) error{
+		func(node *registry.Node, cost time.Duration, err error) error {
+			return c.Report(consumer, node, cost, err)
+		},
+		cb.Report,
+	} {
+		service := fmt.Sprintf("report.outlier.%d", i)
+		for port := 1; port <= 3; port++ {
+			node := &registry.Node{
+				ServiceName: service,
+				Address:     fmt.Sprintf("127.0.0.1:%d", port),
+				Metadata:    map[string]interface{}{"instance": inst},
+			}
+			require.Nil(t, report(node, time.Duration(port)*time.Millisecond, nil))
+			require.Nil(t, report(node, time.Second, errs.NewFrameError(errs.RetClientNetErr, "")))
+		}
+		d := c.outliers
+		d.mu.Lock()
+		l := d.services[model.ServiceKey{Service: service}].instances
+		d.mu.Unlock()
+		for port := 1; port <= 3; port++ {
+			assert.Equal(t, 1, l[fmt.Sprintf("127.0.0.1:%d", port)].count, "only successes are observed")
+		}
+	}
+}
@@ -0,0 +1,368 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/client"
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/errs"
+	"trpc.group/trpc-go/trpc-go/log"
+	"trpc.group/trpc-go/trpc-go/naming/registry"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/metrics"
+
+	"github.com/polarismesh/polaris-go/api"
+	"github.com/polarismesh/polaris-go/pkg/model"
+)
+
+// Types of the active health probe.
+const (
+	// ProbeTCP probes the instance by TCP connect.
+	ProbeTCP = "tcp"
+	// ProbeTRPC probes the instance by calling a trpc method.
+	ProbeTRPC = "trpc"
+)
+
+// Defaults of the active health probe.
+const (
+	defaultProbeInterval         = 3 * time.Second
+	defaultProbeTimeout          = time.Second
+	defaultProbeSuccessThreshold = 3
+	defaultProbeConcurrency      = 8
+)
+
+// ProbeConfig is the configuration of the active health probe.
+//
+// The instances open or half open by polaris of the reported callee services are probed every Interval.
+// They are held out of the user requests like the forced open instances until SuccessThreshold probes
+// succeed in a row since they turn half open, so that the half open breakers are not recovered at the cost of
+// the user requests.
+// The probes of a half open instance are reported to polaris, so that a failed one opens the breaker again,
+// and the successful ones close the breaker without the user requests.
+type ProbeConfig struct {
+	// Enable enables the active health probe.
+	Enable bool `yaml:"enable"`
+	// Type is the type of the probe, one of tcp and trpc, default as tcp.
+	Type string `yaml:"type"`
+	// Method is the rpc name called by the trpc probe, such as /trpc.app.server.Health/Check.
+	// The probe succeeds if the instance responds, even with a business error or an unknown method error.
+	Method string `yaml:"method"`
+	// Interval is the interval of the probes, default as 3s.
+	Interval time.Duration `yaml:"interval"`
+	// Timeout is the timeout of each probe, default as 1s.
+	Timeout time.Duration `yaml:"timeout"`
+	// SuccessThreshold is the number of successful probes in a row to release the instance, default as 3.
+	SuccessThreshold int `yaml:"success_threshold"`
+	// Concurrency is the max number of the concurrent probes, default as 8.
+	Concurrency int `yaml:"concurrency"`
+}
+
+// Validate checks the config without changing it.
+func (c ProbeConfig) Validate() error {
+	return c.normalize()
+}
+
+func (c *ProbeConfig) normalize() error {
+	switch c.Type {
+	case "":
+		c.Type = ProbeTCP
+	case ProbeTCP:
+	case ProbeTRPC:
+		if c.Method == "" {
+			return errors.New("method of trpc probe is empty")
+		}
+	default:
+		return fmt.Errorf("unknown probe type %s", c.Type)
+	}
+	if c.Interval <= 0 {
+		c.Interval = defaultProbeInterval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultProbeTimeout
+	}
+	if c.SuccessThreshold <= 0 {
+		c.SuccessThreshold = defaultProbeSuccessThreshold
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = defaultProbeConcurrency
+	}
+	return nil
+}
+
+// Probe checks the health of the instance at the address.
+type Probe func(ctx context.Context, address string) error
+
+// TCPProbe probes the instance by TCP connect.
+func TCPProbe(ctx context.Context, address string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// TRPCProbe returns a probe which calls the trpc method with an empty body.
+// The probe fails only if the call fails with a client side framework error, such as connect fail or timeout.
+func TRPCProbe(method string) Probe {
+	return func(ctx context.Context, address string) error {
+		ctx, msg := codec.WithNewMessage(ctx)
+		msg.WithClientRPCName(method)
+		msg.WithCalleeMethod(method)
+		err := client.DefaultClient.Invoke(ctx, &codec.Body{}, &codec.Body{},
+			client.WithTarget("ip://"+address),
+			client.WithProtocol("trpc"),
+			client.WithSerializationType(codec.SerializationTypeNoop),
+			client.WithDisableFilter(),
+		)
+		if e, ok := err.(*errs.Error); ok && e.Type != errs.ErrorTypeFramework {
+			// The instance has responded.
+			return nil
+		}
+		return err
+	}
+}
+
+// probeState is the probe state of an instance.
+type probeState struct {
+	state     State
+	successes int
+	// released is true if the instance has passed the probes since it is half open.
+	released bool
+}
+
+// prober probes the broken instances of the reported callee services.
+type prober struct {
+	consumer api.ConsumerAPI
+	cfg      ProbeConfig
+	probe    Probe
+
+	mu        sync.Mutex
+	services  map[model.ServiceKey]struct{}
+	instances map[model.ServiceKey]map[string]*probeState
+}
+
+// newProber creates a prober, it returns nil if cfg is nil or not enabled.
+func newProber(consumer api.ConsumerAPI, cfg *ProbeConfig) (*prober, error) {
+	if cfg == nil || !cfg.Enable {
+		return nil, nil
+	}
+	normalized := *cfg
+	if err := normalized.normalize(); err != nil {
+		return nil, err
+	}
+	probe := TCPProbe
+	if normalized.Type == ProbeTRPC {
+		probe = TRPCProbe(normalized.Method)
+	}
+	return &prober{
+		consumer:  consumer,
+		cfg:       normalized,
+		probe:     probe,
+		services:  make(map[model.ServiceKey]struct{}),
+		instances: make(map[model.ServiceKey]map[string]*probeState),
+	}, nil
+}
+
+// run probes every interval until stop is closed or the SDK context is destroyed.
+func (p *prober) run(sdkCtx api.SDKContext, stop <-chan struct{}) {
+	runEvery(sdkCtx, stop, p.cfg.Interval, p.round)
+}
+
+// watch adds the callee service to be probed.
+func (p *prober) watch(namespace, service string) {
+	if p == nil || namespace == "" || service == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.services[model.ServiceKey{Namespace: namespace, Service: service}] = struct{}{}
+}
+
+// round probes the broken instances of all watched services, and waits for the results.
+func (p *prober) round() {
+	p.mu.Lock()
+	keys := make([]model.ServiceKey, 0, len(p.services))
+	for key := range p.services {
+		keys = append(keys, key)
+	}
+	p.mu.Unlock()
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, p.cfg.Concurrency)
+	)
+	for _, key := range keys {
+		resp, err := p.consumer.GetAllInstances(&api.GetAllInstancesRequest{
+			GetAllInstancesRequest: model.GetAllInstancesRequest{
+				Service:   key.Service,
+				Namespace: key.Namespace,
+			},
+		})
+		if err != nil {
+			log.Errorf("[NAMING-POLARISMESH] get instances of service %s of namespace %s to probe err: %v",
+				key.Service, key.Namespace, err)
+			continue
+		}
+		for inst, addr := range p.update(key, resp.GetInstances()) {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(key model.ServiceKey, inst model.Instance, addr string) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				p.check(key, inst, addr)
+			}(key, inst, addr)
+		}
+	}
+	wg.Wait()
+}
+
+// update updates the probe states of the service by the instances, and returns the instances to probe.
+// The broken instances are held until they pass the probes, and the others are released.
+func (p *prober) update(key model.ServiceKey, instances []model.Instance) map[model.Instance]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	last := p.instances[key]
+	states := make(map[string]*probeState)
+	toProbe := make(map[model.Instance]string)
+	for _, inst := range instances {
+		state := instanceState(inst)
+		if state == StateClosed {
+			continue
+		}
+		addr := net.JoinHostPort(inst.GetHost(), strconv.Itoa(int(inst.GetPort())))
+		s, ok := last[addr]
+		if !ok || s.state != state {
+			// Half open or broken again, the instance has to pass the probes since it is half open.
+			s = &probeState{}
+		}
+		s.state = state
+		states[addr] = s
+		if !s.released {
+			hold(key, addr)
+			toProbe[inst] = addr
+		}
+	}
+	for addr := range last {
+		if _, ok := states[addr]; !ok {
+			unhold(key, addr)
+		}
+	}
+	p.instances[key] = states
+	return toProbe
+}
+
+// check probes the instance, and releases it after enough successful probes in a row while it is half open.
+// The result is reported to polaris if the instance is half open.
+func (p *prober) check(key model.ServiceKey, inst model.Instance, addr string) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.Timeout)
+	start := time.Now()
+	err := p.probe(ctx, addr)
+	cost := time.Since(start)
+	cancel()
+	metrics.ReportProbe(key.Namespace, key.Service, addr, cost, err)
+
+	p.mu.Lock()
+	s, ok := p.instances[key][addr]
+	if !ok {
+		p.mu.Unlock()
+		return
+	}
+	halfOpen := s.state == StateHalfOpen
+	if err != nil {
+		s.successes = 0
+		p.mu.Unlock()
+		log.Debugf("[NAMING-POLARISMESH] probe instance %s of service %s err: %v", addr, key.Service, err)
+		if halfOpen {
+			p.report(key, inst, addr, model.RetFail, int32(errs.Code(err)), cost)
+		}
+		return
+	}
+	if !halfOpen {
+		// The open instance is released only after it turns half open.
+		p.mu.Unlock()
+		return
+	}
+	s.successes++
+	released := s.successes >= p.cfg.SuccessThreshold
+	s.released = released
+	p.mu.Unlock()
+	p.report(key, inst, addr, model.RetSuccess, 0, cost)
+	if !released {
+		return
+	}
+	unhold(key, addr)
+	log.Infof("[NAMING-POLARISMESH] instance %s of service %s is released after %d successful probes",
+		addr, key.Service, p.cfg.SuccessThreshold)
+}
+
+// report reports the result of the probe to polaris.
+func (p *prober) report(
+	key model.ServiceKey, inst model.Instance, addr string, retStatus model.RetStatus, retCode int32, cost time.Duration) {
+	node := &registry.Node{ServiceName: key.Service, Address: addr}
+	if err := updateServiceCallResult(p.consumer, inst, node, retStatus, retCode, cost); err != nil {
+		log.Errorf("[NAMING-POLARISMESH] report probe %s of instance %s of service %s err: %v",
+			retStatus, addr, key.Service, err)
+	}
+}
+
+var holds = struct {
+	mu sync.RWMutex
+	m  map[model.ServiceKey]map[string]struct{} // service -> address.
+}{m: make(map[model.ServiceKey]map[string]struct{})}
+
+// hold holds the instance out of the user requests until it is released.
+func hold(key model.ServiceKey, address string) {
+	holds.mu.Lock()
+	defer holds.mu.Unlock()
+	instances := holds.m[key]
+	if instances == nil {
+		instances = make(map[string]struct{})
+		holds.m[key] = instances
+	}
+	instances[address] = struct{}{}
+}
+
+// unhold releases the held instance.
+func unhold(key model.ServiceKey, address string) {
+	holds.mu.Lock()
+	defer holds.mu.Unlock()
+	delete(holds.m[key], address)
+	if len(holds.m[key]) == 0 {
+		delete(holds.m, key)
+	}
+}
+
+// held reports whether the instance is held by the prober.
+func held(key model.ServiceKey, address string) bool {
+	holds.mu.RLock()
+	defer holds.mu.RUnlock()
+	_, ok := holds.m[key][address]
+	return ok
+}
+
+// hasHeld reports whether any instance of the service is held by the prober.
+func hasHeld(key model.ServiceKey) bool {
+	holds.mu.RLock()
+	defer holds.mu.RUnlock()
+	return len(holds.m[key]) > 0
+}
@@ -0,0 +1,210 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_api"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_model"
+
+	"github.com/golang/mock/gomock"
+	"github.com/polarismesh/polaris-go/api"
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeConfig(t *testing.T) {
+	cfg := ProbeConfig{}
+	require.Nil(t, cfg.normalize())
+	assert.Equal(t, ProbeConfig{
+		Type:             ProbeTCP,
+		Interval:         defaultProbeInterval,
+		Timeout:          defaultProbeTimeout,
+		SuccessThreshold: defaultProbeSuccessThreshold,
+		Concurrency:      defaultProbeConcurrency,
+	}, cfg)
+	assert.NotNil(t, (&ProbeConfig{Type: "unknown"}).normalize())
+	assert.NotNil(t, (&ProbeConfig{Type: ProbeTRPC}).normalize(), "empty method")
+	assert.Nil(t, (&ProbeConfig{Type: ProbeTRPC, Method: "/trpc.app.server.Health/Check"}).normalize())
+
+	p, err := newProber(nil, &ProbeConfig{Type: "unknown"})
+	require.Nil(t, err, "disabled")
+	assert.Nil(t, p)
+	p.watch("Test", "probe.config")
+	_, err = newProber(nil, &ProbeConfig{Enable: true, Type: "unknown"})
+	assert.NotNil(t, err)
+}
+
+func TestProbes(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	addr := l.Addr().String()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, TCPProbe(ctx, addr))
+	require.Nil(t, l.Close())
+	assert.NotNil(t, TCPProbe(ctx, addr))
+	assert.NotNil(t, TRPCProbe("/trpc.app.server.Health/Check")(ctx, addr))
+}
+
+func TestProber(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const service = "prober"
+	key := model.ServiceKey{Namespace: "Test", Service: service}
+	newInstance := func(port uint32, status model.Status) model.Instance {
+		inst := mock_model.NewMockInstance(ctrl)
+		inst.EXPECT().GetHost().Return("127.0.0.1").AnyTimes()
+		inst.EXPECT().GetPort().Return(port).AnyTimes()
+		cbStatus := mock_model.NewMockCircuitBreakerStatus(ctrl)
+		cbStatus.EXPECT().GetStatus().Return(status).AnyTimes()
+		inst.EXPECT().GetCircuitBreakerStatus().Return(cbStatus).AnyTimes()
+		return inst
+	}
+	var instances []model.Instance
+	consumer := mock_api.NewMockConsumerAPI(ctrl)
+	consumer.EXPECT().GetAllInstances(gomock.Any()).DoAndReturn(
+		func(*api.GetAllInstancesRequest) (*model.InstancesResponse, error) {
+			return &model.InstancesResponse{Instances: instances}, nil
+		}).AnyTimes()
+	var (
+		mu        sync.Mutex
+		failures  []model.Instance
+		successes []model.Instance
+		healthy   = map[string]bool{"127.0.0.1:3": true}
+	)
+	consumer.EXPECT().UpdateServiceCallResult(gomock.Any()).DoAndReturn(func(r *api.ServiceCallResult) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.RetStatus == model.RetFail {
+			failures = append(failures, r.CalledInstance)
+		} else {
+			assert.Equal(t, model.RetSuccess, r.RetStatus)
+			successes = append(successes, r.CalledInstance)
+		}
+		return nil
+	}).AnyTimes()
+
+	p, err := newProber(consumer, &ProbeConfig{Enable: true, SuccessThreshold: 2})
+	require.Nil(t, err)
+	p.probe = func(_ context.Context, address string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if healthy[address] {
+			return nil
+		}
+		return errors.New("unhealthy")
+	}
+	p.watch("", service)
+	p.watch("Test", service)
+
+	halfOpen := newInstance(2, model.HalfOpen)
+	instances = []model.Instance{newInstance(1, model.Close), halfOpen, newInstance(3, model.Open)}
+	p.round()
+	assert.True(t, HasForced("Test", service))
+	assert.False(t, HasForced("Development", service), "namespaces are separated")
+	assert.Equal(t, "", forcedState(key, "127.0.0.1:1"))
+	assert.Equal(t, ForceOpen, forcedState(key, "127.0.0.1:2"))
+	assert.Equal(t, ForceOpen, forcedState(key, "127.0.0.1:3"))
+	assert.Equal(t, []model.Instance{halfOpen}, failures, "failed probe of half open instance is reported")
+	assert.Empty(t, successes, "probes of open instances are not reported")
+
+	mu.Lock()
+	healthy["127.0.0.1:2"] = true
+	mu.Unlock()
+	p.round()
+	assert.Equal(t, ForceOpen, forcedState(key, "127.0.0.1:2"), "not enough successes in a row")
+	assert.Equal(t, []model.Instance{halfOpen}, successes, "successful probe of half open instance is reported")
+
+	p.round()
+	assert.Equal(t, "", forcedState(key, "127.0.0.1:2"), "released")
+	assert.Equal(t, ForceOpen, forcedState(key, "127.0.0.1:3"), "open instance is not released")
+	assert.Len(t, failures, 1)
+	assert.Len(t, successes, 2)
+
+	// Broken again after released.
+	instances = []model.Instance{newInstance(2, model.Open), newInstance(3, model.Open)}
+	p.round()
+	assert.Equal(t, ForceOpen, forcedState(key, "127.0.0.1:2"))
+	assert.Equal(t, ForceOpen, forcedState(key, "127.0.0.1:3"))
+
+	instances = []model.Instance{newInstance(1, model.Close), newInstance(2, model.Close)}
+	p.round()
+	assert.False(t, HasForced("Test", service), "recovered instances are released")
+}
+
+func TestProberOpenToHalfOpen(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const service = "prober.half.open"
+	key := model.ServiceKey{Namespace: "Test", Service: service}
+	newInstance := func(status model.Status) model.Instance {
+		inst := mock_model.NewMockInstance(ctrl)
+		inst.EXPECT().GetHost().Return("127.0.0.1").AnyTimes()
+		inst.EXPECT().GetPort().Return(uint32(1)).AnyTimes()
+		cbStatus := mock_model.NewMockCircuitBreakerStatus(ctrl)
+		cbStatus.EXPECT().GetStatus().Return(status).AnyTimes()
+		inst.EXPECT().GetCircuitBreakerStatus().Return(cbStatus).AnyTimes()
+		return inst
+	}
+	var instances []model.Instance
+	consumer := mock_api.NewMockConsumerAPI(ctrl)
+	consumer.EXPECT().GetAllInstances(gomock.Any()).DoAndReturn(
+		func(*api.GetAllInstancesRequest) (*model.InstancesResponse, error) {
+			return &model.InstancesResponse{Instances: instances}, nil
+		}).AnyTimes()
+	var reported []model.RetStatus
+	consumer.EXPECT().UpdateServiceCallResult(gomock.Any()).DoAndReturn(func(r *api.ServiceCallResult) error {
+		reported = append(reported, r.RetStatus)
+		return nil
+	}).AnyTimes()
+	p, err := newProber(consumer, &ProbeConfig{Enable: true, SuccessThreshold: 2})
+	require.Nil(t, err)
+	p.probe = func(context.Context, string) error { return nil }
+	p.watch("Test", service)
+
+	instances = []model.Instance{newInstance(model.Open)}
+	for i := 0; i < 3; i++ {
+		p.round()
+		assert.Equal(t, ForceOpen, forcedState(key, "127.0.0.1:1"), "successes in open are not counted")
+	}
+	assert.Empty(t, reported)
+
+	instances = []model.Instance{newInstance(model.HalfOpen)}
+	p.round()
+	assert.Equal(t, ForceOpen, forcedState(key, "127.0.0.1:1"), "the probes start over when half open")
+	p.round()
+	assert.Equal(t, "", forcedState(key, "127.0.0.1:1"), "released by the successes in half open")
+	assert.Equal(t, []model.RetStatus{model.RetSuccess, model.RetSuccess}, reported)
+
+	instances = []model.Instance{newInstance(model.Open)}
+	p.round()
+	assert.Equal(t, ForceOpen, forcedState(key, "127.0.0.1:1"), "held again when broken again")
+	instances = []model.Instance{newInstance(model.HalfOpen)}
+	p.round()
+	assert.Equal(t, ForceOpen, forcedState(key, "127.0.0.1:1"))
+
+	instances = nil
+	p.round()
+	assert.False(t, HasForced("Test", service))
+}
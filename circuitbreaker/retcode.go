@@ -0,0 +1,100 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package circuitbreaker
+
+import (
+	"fmt"
+
+	"trpc.group/trpc-go/trpc-go/errs"
+)
+
+// Names of the trpc error types used in the configuration.
+const (
+	ErrorTypeFramework       = "framework"
+	ErrorTypeCalleeFramework = "callee_framework"
+	ErrorTypeBusiness        = "business"
+)
+
+// parseErrorType parses the name of the trpc error type, empty name matches any type and returns 0.
+func parseErrorType(name string) (int, error) {
+	switch name {
+	case "":
+		return 0, nil
+	case ErrorTypeFramework:
+		return errs.ErrorTypeFramework, nil
+	case ErrorTypeCalleeFramework:
+		return errs.ErrorTypeCalleeFramework, nil
+	case ErrorTypeBusiness:
+		return errs.ErrorTypeBusiness, nil
+	default:
+		return 0, fmt.Errorf("unknown error type %s", name)
+	}
+}
+
+// RetCodeRule maps the trpc errors of the type and code to the RetCode reported to polaris.
+type RetCodeRule struct {
+	// Type is the trpc error type, one of framework, callee_framework and business, empty for any type.
+	Type string `yaml:"type"`
+	// Code is the trpc error code, nil for any code.
+	Code *int `yaml:"code"`
+	// RetCode is the RetCode reported to polaris.
+	RetCode int32 `yaml:"ret_code"`
+
+	errType int
+}
+
+func (r *RetCodeRule) match(e *errs.Error) bool {
+	return (r.errType == 0 || r.errType == e.Type) && (r.Code == nil || *r.Code == int(e.Code))
+}
+
+// RetCodeMapper maps the errors to the RetCode reported to polaris.
+//
+// By default, the code of the trpc error is reported, and errs.RetUnknown is reported for other errors.
+// The rules are matched in order and the first matched one wins.
+// The RetCode can be used by the error code based circuit breaking rules of polaris.
+type RetCodeMapper struct {
+	rules []*RetCodeRule
+}
+
+// NewRetCodeMapper creates a RetCodeMapper by rules.
+func NewRetCodeMapper(rules []*RetCodeRule) (*RetCodeMapper, error) {
+	for _, r := range rules {
+		errType, err := parseErrorType(r.Type)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ret code rule: %w", err)
+		}
+		r.errType = errType
+	}
+	return &RetCodeMapper{rules: rules}, nil
+}
+
+// RetCode returns the RetCode of the error, 0 for nil error.
+// A nil *RetCodeMapper uses the default mapping.
+func (m *RetCodeMapper) RetCode(err error) int32 {
+	if err == nil {
+		return 0
+	}
+	e, ok := err.(*errs.Error)
+	if !ok {
+		return int32(errs.RetUnknown)
+	}
+	if m != nil {
+		for _, r := range m.rules {
+			if r.match(e) {
+				return r.RetCode
+			}
+		}
+	}
+	return int32(e.Code)
+}
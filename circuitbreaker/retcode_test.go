@@ -0,0 +1,93 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package circuitbreaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/errs"
+	"trpc.group/trpc-go/trpc-go/naming/registry"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_api"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_model"
+
+	"github.com/golang/mock/gomock"
+	"github.com/polarismesh/polaris-go/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetCodeMapper(t *testing.T) {
+	timeout := int(errs.RetClientTimeout)
+	m, err := NewRetCodeMapper([]*RetCodeRule{
+		{Type: ErrorTypeFramework, Code: &timeout, RetCode: 1101},
+		{Type: ErrorTypeBusiness, RetCode: 20000},
+	})
+	require.Nil(t, err)
+
+	for _, c := range []struct {
+		name string
+		err  error
+		want int32
+	}{
+		{"success", nil, 0},
+		{"not trpc error", errors.New("err"), int32(errs.RetUnknown)},
+		{"mapped code", errs.NewFrameError(errs.RetClientTimeout, ""), 1101},
+		{"mapped type", errs.New(1, ""), 20000},
+		{"not mapped", errs.NewFrameError(errs.RetClientConnectFail, ""), int32(errs.RetClientConnectFail)},
+		{"callee framework", &errs.Error{
+			Type: errs.ErrorTypeCalleeFramework,
+			Code: errs.RetClientTimeout,
+		}, int32(errs.RetClientTimeout)},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.want, m.RetCode(c.err))
+		})
+	}
+
+	var nilMapper *RetCodeMapper
+	assert.Equal(t, int32(errs.RetClientTimeout), nilMapper.RetCode(errs.NewFrameError(errs.RetClientTimeout, "")))
+
+	_, err = NewRetCodeMapper([]*RetCodeRule{{Type: "unknown"}})
+	assert.NotNil(t, err)
+}
+
+func TestReportRetCode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var retCodes []int32
+	consumer := mock_api.NewMockConsumerAPI(ctrl)
+	consumer.EXPECT().UpdateServiceCallResult(gomock.Any()).DoAndReturn(func(r *api.ServiceCallResult) error {
+		retCodes = append(retCodes, *r.RetCode)
+		return nil
+	}).AnyTimes()
+	m, err := NewRetCodeMapper([]*RetCodeRule{{Type: ErrorTypeFramework, RetCode: 1000}})
+	require.Nil(t, err)
+	node := &registry.Node{Metadata: map[string]interface{}{"instance": mock_model.NewMockInstance(ctrl)}}
+
+	cb := &CircuitBreaker{
+		consumer:   consumer,
+		classifier: newClassifier(nil, nil, nil),
+	}
+	require.Nil(t, cb.Report(node, time.Second, errs.NewFrameError(errs.RetClientNetErr, "")))
+	require.Nil(t, cb.Report(node, time.Second, errs.New(1, "")))
+	cb.classifier.retCodes = m
+	require.Nil(t, cb.Report(node, time.Second, errs.NewFrameError(errs.RetClientNetErr, "")))
+	require.Nil(t, Report(consumer, node, nil, time.Second, errs.NewFrameError(errs.RetClientTimeout, "")))
+	require.Nil(t, newClassifier(nil, nil, m).Report(consumer, node, time.Second, errs.NewFrameError(errs.RetClientNetErr, "")))
+	assert.Equal(t, []int32{int32(errs.RetClientNetErr), 1, 1000, int32(errs.RetClientTimeout), 1000}, retCodes,
+		"the successful errors are reported with their codes as well")
+}
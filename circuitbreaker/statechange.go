@@ -0,0 +1,217 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package circuitbreaker
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/log"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/metrics"
+
+	"github.com/polarismesh/polaris-go/api"
+	"github.com/polarismesh/polaris-go/pkg/model"
+)
+
+// State is the state of a circuit breaker.
+type State int
+
+// States of circuit breakers.
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// StateChange is the event of a state change of a circuit breaker.
+type StateChange struct {
+	// Namespace is the namespace of the callee service, it may be empty for the method level breakers.
+	Namespace string
+	// Service is the name of the callee service.
+	Service string
+	// Address is the address of the instance, it is empty for the method level.
+	Address string
+	// Method is the called method, it is empty for the instance level.
+	Method string
+	// From is the previous state.
+	From State
+	// To is the current state.
+	To State
+	// Open is the number of the open instances of the service after the change, only set for the instance level.
+	Open int
+	// Total is the number of the instances of the service, only set for the instance level.
+	Total int
+}
+
+var stateListeners struct {
+	mu sync.RWMutex
+	fs []func(StateChange)
+}
+
+// OnStateChange registers f which is called on every state change of the circuit breakers.
+// It is called synchronously, so f must not block.
+//
+// The state changes of the method level breakers are reported as they happen. The state changes of the instance
+// level breakers of polaris are observed periodically for the callee services which have been reported.
+func OnStateChange(f func(StateChange)) {
+	stateListeners.mu.Lock()
+	defer stateListeners.mu.Unlock()
+	stateListeners.fs = append(stateListeners.fs, f)
+}
+
+// notifyStateChange logs and reports the state change, and then calls the listeners.
+func notifyStateChange(e StateChange) {
+	logf := log.Infof
+	if e.To == StateOpen {
+		logf = log.Warnf
+	}
+	logf("[NAMING-POLARISMESH] circuit breaker of service %s of namespace %s address %q method %q "+
+		"changes from %s to %s, %d/%d instances are open",
+		e.Service, e.Namespace, e.Address, e.Method, e.From, e.To, e.Open, e.Total)
+	metrics.ReportCircuitBreakerStateChange(
+		e.Namespace, e.Service, e.Address, e.Method, e.From.String(), e.To.String())
+	stateListeners.mu.RLock()
+	fs := stateListeners.fs
+	stateListeners.mu.RUnlock()
+	for _, f := range fs {
+		f(e)
+	}
+}
+
+// instanceState returns the state of the instance level breaker of polaris.
+func instanceState(inst model.Instance) State {
+	status := inst.GetCircuitBreakerStatus()
+	if status == nil {
+		return StateClosed
+	}
+	switch status.GetStatus() {
+	case model.Open:
+		return StateOpen
+	case model.HalfOpen:
+		return StateHalfOpen
+	default:
+		return StateClosed
+	}
+}
+
+// stateWatcher watches the instance level states of the reported callee services, it is safe for concurrent use.
+type stateWatcher struct {
+	consumer api.ConsumerAPI
+
+	mu       sync.Mutex
+	services map[model.ServiceKey]map[string]State
+}
+
+func newStateWatcher(consumer api.ConsumerAPI) *stateWatcher {
+	return &stateWatcher{
+		consumer: consumer,
+		services: make(map[model.ServiceKey]map[string]State),
+	}
+}
+
+// run checks the states every interval until stop is closed or the SDK context is destroyed.
+func (w *stateWatcher) run(sdkCtx api.SDKContext, stop <-chan struct{}, interval time.Duration) {
+	runEvery(sdkCtx, stop, interval, w.check)
+}
+
+// watch adds the callee service to be watched.
+func (w *stateWatcher) watch(namespace, service string) {
+	if w == nil || namespace == "" || service == "" {
+		return
+	}
+	key := model.ServiceKey{Namespace: namespace, Service: service}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.services[key]; !ok {
+		w.services[key] = make(map[string]State)
+	}
+}
+
+// check compares the states of all instances of the watched services with the last check, and notifies the changes.
+func (w *stateWatcher) check() {
+	w.mu.Lock()
+	keys := make([]model.ServiceKey, 0, len(w.services))
+	for key := range w.services {
+		keys = append(keys, key)
+	}
+	w.mu.Unlock()
+	for _, key := range keys {
+		resp, err := w.consumer.GetAllInstances(&api.GetAllInstancesRequest{
+			GetAllInstancesRequest: model.GetAllInstancesRequest{
+				Service:   key.Service,
+				Namespace: key.Namespace,
+			},
+		})
+		if err != nil {
+			log.Errorf("[NAMING-POLARISMESH] watch circuit breaker states of service %s of namespace %s err: %v",
+				key.Service, key.Namespace, err)
+			continue
+		}
+		w.update(key, resp.GetInstances())
+	}
+}
+
+// update updates the states of the service by the instances, and notifies the changes.
+func (w *stateWatcher) update(key model.ServiceKey, instances []model.Instance) {
+	states := make(map[string]State, len(instances))
+	var open int
+	for _, inst := range instances {
+		addr := net.JoinHostPort(inst.GetHost(), strconv.Itoa(int(inst.GetPort())))
+		states[addr] = instanceState(inst)
+		if states[addr] == StateOpen {
+			open++
+		}
+	}
+	w.mu.Lock()
+	last := w.services[key]
+	w.services[key] = states
+	w.mu.Unlock()
+
+	var changes []StateChange
+	for addr, to := range states {
+		// The instances seen the first time are closed before.
+		if from := last[addr]; from != to {
+			changes = append(changes, StateChange{
+				Namespace: key.Namespace,
+				Service:   key.Service,
+				Address:   addr,
+				From:      from,
+				To:        to,
+				Open:      open,
+				Total:     len(instances),
+			})
+		}
+	}
+	if len(changes) == 0 {
+		return
+	}
+	metrics.ReportCircuitBreakerOpenInstances(key.Namespace, key.Service, open, len(instances))
+	for _, e := range changes {
+		notifyStateChange(e)
+	}
+}
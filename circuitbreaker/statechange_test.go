@@ -0,0 +1,199 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package circuitbreaker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/errs"
+	"trpc.group/trpc-go/trpc-go/naming/registry"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_api"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_model"
+
+	"github.com/golang/mock/gomock"
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordStateChanges records the state changes of the service.
+func recordStateChanges(service string) func() []StateChange {
+	var (
+		mu      sync.Mutex
+		changes []StateChange
+	)
+	OnStateChange(func(e StateChange) {
+		if e.Service != service {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, e)
+	})
+	return func() []StateChange {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]StateChange(nil), changes...)
+	}
+}
+
+func TestStateString(t *testing.T) {
+	assert.Equal(t, "closed", StateClosed.String())
+	assert.Equal(t, "open", StateOpen.String())
+	assert.Equal(t, "half_open", StateHalfOpen.String())
+}
+
+func TestMethodStateChange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	changes := recordStateChanges("method.state.change")
+	consumer := mock_api.NewMockConsumerAPI(ctrl)
+	consumer.EXPECT().UpdateServiceCallResult(gomock.Any()).Return(nil).AnyTimes()
+	cb := &CircuitBreaker{
+		consumer:   consumer,
+		classifier: newClassifier(nil, nil, nil),
+		levels:     map[string]string{LevelAnyService: LevelInstanceMethod},
+		thresholds: thresholds{
+			continuousErrorThreshold:  1,
+			sleepWindow:               time.Millisecond,
+			requestCountAfterHalfOpen: 1,
+			successCountAfterHalfOpen: 1,
+		},
+	}
+	ctx, msg := codec.WithNewMessage(context.Background())
+	msg.WithCalleeMethod("/method")
+	node := &registry.Node{
+		ServiceName: "method.state.change",
+		Address:     "127.0.0.1:8080",
+		Metadata: map[string]interface{}{
+			"instance":  mock_model.NewMockInstance(ctrl),
+			"namespace": "Test",
+		},
+	}
+	WithMethod(ctx, node)
+
+	require.Nil(t, cb.Report(node, time.Second, errs.NewFrameError(errs.RetClientNetErr, "")))
+	time.Sleep(time.Millisecond)
+	id := StateChange{Namespace: "Test", Service: "method.state.change", Address: "127.0.0.1:8080", Method: "/method"}
+	require.True(t, methodBreakers.get(id, cb.thresholds, time.Now()).available(time.Now()))
+	require.Nil(t, cb.Report(node, time.Second, nil))
+
+	want := []StateChange{id, id, id}
+	want[0].From, want[0].To = StateClosed, StateOpen
+	want[1].From, want[1].To = StateOpen, StateHalfOpen
+	want[2].From, want[2].To = StateHalfOpen, StateClosed
+	assert.Equal(t, want, changes())
+}
+
+func TestStateWatcher(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	changes := recordStateChanges("watcher.state.change")
+	newInstance := func(port uint32, status model.Status) model.Instance {
+		inst := mock_model.NewMockInstance(ctrl)
+		inst.EXPECT().GetHost().Return("127.0.0.1").AnyTimes()
+		inst.EXPECT().GetPort().Return(port).AnyTimes()
+		cbStatus := mock_model.NewMockCircuitBreakerStatus(ctrl)
+		cbStatus.EXPECT().GetStatus().Return(status).AnyTimes()
+		inst.EXPECT().GetCircuitBreakerStatus().Return(cbStatus).AnyTimes()
+		return inst
+	}
+	consumer := mock_api.NewMockConsumerAPI(ctrl)
+	gomock.InOrder(
+		consumer.EXPECT().GetAllInstances(gomock.Any()).Return(&model.InstancesResponse{
+			Instances: []model.Instance{newInstance(1, model.Close), newInstance(2, model.Open)},
+		}, nil),
+		consumer.EXPECT().GetAllInstances(gomock.Any()).Return(&model.InstancesResponse{
+			Instances: []model.Instance{newInstance(1, model.Open), newInstance(2, model.HalfOpen)},
+		}, nil),
+	)
+
+	w := newStateWatcher(consumer)
+	w.check()
+	w.watch("", "watcher.state.change")
+	w.watch("Test", "watcher.state.change")
+	w.check()
+	assert.Equal(t, []StateChange{{
+		Namespace: "Test",
+		Service:   "watcher.state.change",
+		Address:   "127.0.0.1:2",
+		From:      StateClosed,
+		To:        StateOpen,
+		Open:      1,
+		Total:     2,
+	}}, changes())
+
+	w.check()
+	got := changes()[1:]
+	require.Len(t, got, 2)
+	if got[0].Address != "127.0.0.1:1" {
+		got[0], got[1] = got[1], got[0]
+	}
+	assert.Equal(t, StateChange{
+		Namespace: "Test",
+		Service:   "watcher.state.change",
+		Address:   "127.0.0.1:1",
+		From:      StateClosed,
+		To:        StateOpen,
+		Open:      1,
+		Total:     2,
+	}, got[0])
+	assert.Equal(t, StateOpen, got[1].From)
+	assert.Equal(t, StateHalfOpen, got[1].To)
+
+	var nilWatcher *stateWatcher
+	nilWatcher.watch("Test", "watcher.state.change")
+}
+
+func TestReportWatches(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sdkCtx := mock_api.NewMockSDKContext(ctrl)
+	classifier := newClassifier(nil, nil, nil)
+	require.Nil(t, Setup(sdkCtx, &Config{Name: "report.watches", Classifier: classifier}, false))
+	assert.Nil(t, classifier.watcher, "the state watcher is disabled by default")
+	assert.Nil(t, classifier.prober)
+
+	classifier = newClassifier(nil, nil, nil)
+	require.Nil(t, Setup(sdkCtx, &Config{
+		Name:               "report.watches",
+		Classifier:         classifier,
+		StateWatchInterval: time.Hour,
+		Probe:              &ProbeConfig{Enable: true, Interval: time.Hour},
+	}, false))
+	require.NotNil(t, classifier.watcher)
+	require.NotNil(t, classifier.prober)
+
+	consumer := mock_api.NewMockConsumerAPI(ctrl)
+	consumer.EXPECT().UpdateServiceCallResult(gomock.Any()).Return(nil)
+	node := &registry.Node{
+		ServiceName: "report.watches",
+		Metadata:    map[string]interface{}{"instance": mock_model.NewMockInstance(ctrl), "namespace": "Test"},
+	}
+	require.Nil(t, classifier.Report(consumer, node, time.Second, nil))
+	key := model.ServiceKey{Namespace: "Test", Service: "report.watches"}
+	classifier.watcher.mu.Lock()
+	assert.Contains(t, classifier.watcher.services, key, "watched by Selector.Report")
+	classifier.watcher.mu.Unlock()
+	classifier.prober.mu.Lock()
+	assert.Contains(t, classifier.prober.services, key, "probed by Selector.Report")
+	classifier.prober.mu.Unlock()
+}
@@ -0,0 +1,113 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package naming
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"trpc.group/trpc-go/trpc-naming-polarismesh/cluster"
+
+	"github.com/polarismesh/polaris-go/api"
+	"github.com/polarismesh/polaris-go/pkg/config"
+)
+
+// ClusterConfig is the config of a polaris mesh cluster, the fields not set are inherited from the top level config.
+type ClusterConfig struct {
+	// Name is the name of the cluster referred by the cluster routes.
+	Name string `yaml:"name"`
+	// AddressList is the addresses of the polaris mesh servers of the cluster.
+	AddressList    string         `yaml:"address_list"`
+	Protocol       string         `yaml:"protocol"`
+	ConnectTimeout int            `yaml:"connect_timeout"`
+	MessageTimeout *time.Duration `yaml:"message_timeout"`
+	// PersistDir is the directory to persist the cached services,
+	// default as the sub directory named by the cluster under the top level persistDir.
+	PersistDir *string `yaml:"persistDir"`
+}
+
+// setupClusters sets up the components of each cluster named by cluster.ComponentName,
+// and the components named conf.Name routing the requests to them.
+func setupClusters(sdkCtx api.SDKContext, conf *Config) error {
+	if err := setupComponents(sdkCtx, conf.clusterConfig(&ClusterConfig{Name: cluster.Default})); err != nil {
+		return fmt.Errorf("setup cluster %s err: %w", cluster.Default, err)
+	}
+	clusters := []string{cluster.Default}
+	for i := range conf.Clusters {
+		cl := &conf.Clusters[i]
+		cc := conf.clusterConfig(cl)
+		ctx, err := newSDKContext(cc)
+		if err != nil {
+			return fmt.Errorf("new sdk ctx of cluster %s err: %w", cl.Name, err)
+		}
+		if err := setupComponents(ctx, cc); err != nil {
+			return fmt.Errorf("setup cluster %s err: %w", cl.Name, err)
+		}
+		clusters = append(clusters, cl.Name)
+	}
+	// The load balancers only use the plugins of the SDK context,
+	// so those of the default cluster are registered at last to serve all clusters.
+	setDefault := conf.getSetDefault()
+	if err := setupLoadbalance(sdkCtx, conf, setDefault); err != nil {
+		return err
+	}
+	return cluster.Setup(selectorName(conf), clusters, cluster.NewRouter(conf.ClusterRoutes), setDefault)
+}
+
+// clusterConfig returns the config of the cluster cl, which is never set as default.
+func (c *Config) clusterConfig(cl *ClusterConfig) *Config {
+	cc := *c
+	setDefault := false
+	cc.Name = cluster.ComponentName(selectorName(c), cl.Name)
+	cc.Default = &setDefault
+	cc.Clusters, cc.ClusterRoutes = nil, nil
+	if cl.Name == cluster.Default {
+		return &cc
+	}
+	cc.PolarisConfig = nil
+	cc.AddressList = cl.AddressList
+	if cl.Protocol != "" {
+		cc.Protocol = cl.Protocol
+	}
+	if cl.ConnectTimeout != 0 {
+		cc.ConnectTimeout = cl.ConnectTimeout
+	}
+	if cl.MessageTimeout != nil {
+		cc.MessageTimeout = cl.MessageTimeout
+	}
+	persistDir := config.DefaultCachePersistDir
+	if c.PersistDir != nil {
+		persistDir = *c.PersistDir
+	}
+	persistDir = filepath.Join(persistDir, cl.Name)
+	if cl.PersistDir != nil {
+		persistDir = *cl.PersistDir
+	}
+	cc.PersistDir = &persistDir
+	return &cc
+}
+
+// componentNames returns the names of the components applied by Reload.
+func (c *Config) componentNames() []string {
+	name := selectorName(c)
+	if len(c.Clusters) == 0 {
+		return []string{name}
+	}
+	names := []string{cluster.ComponentName(name, cluster.Default)}
+	for _, cl := range c.Clusters {
+		names = append(names, cluster.ComponentName(name, cl.Name))
+	}
+	return names
+}
@@ -0,0 +1,109 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+// Package cluster routes the requests of callee services to multiple polaris mesh clusters,
+// which is useful when migrating services between the clusters.
+package cluster
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Default is the name of the cluster configured by the top level of the selector config.
+const Default = "default"
+
+// MetadataKey is the key of registry.Node.Metadata to record the cluster which the node is selected from.
+const MetadataKey = "polaris_cluster"
+
+// ComponentName returns the name of the selector, discovery, service router and circuit breaker of the cluster.
+func ComponentName(name, cluster string) string {
+	return fmt.Sprintf("%s.%s", name, cluster)
+}
+
+// Route maps the callee services to the clusters.
+type Route struct {
+	// Namespaces are the namespaces of the callee services, empty to match all namespaces.
+	Namespaces []string `yaml:"namespaces"`
+	// Services are the names of the callee services, empty to match all services.
+	Services []string `yaml:"services"`
+	// Clusters are the names of the clusters in the fallback order.
+	Clusters []string `yaml:"clusters"`
+}
+
+func (r *Route) match(namespace, service string) bool {
+	return matchAny(r.Namespaces, namespace) && matchAny(r.Services, service)
+}
+
+func matchAny(values []string, v string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Router resolves the clusters of the callee services by the routes.
+// It also remembers the cluster which serves each callee service at last.
+type Router struct {
+	routes []Route
+
+	mu     sync.RWMutex
+	active map[string]string // namespace/service -> cluster.
+}
+
+// NewRouter creates a router by the routes, the first matched route takes effect.
+// The services not matched by any route are routed to the Default cluster.
+func NewRouter(routes []Route) *Router {
+	return &Router{routes: routes, active: make(map[string]string)}
+}
+
+// Clusters returns the clusters of the callee service in the fallback order.
+func (r *Router) Clusters(namespace, service string) []string {
+	for i := range r.routes {
+		if r.routes[i].match(namespace, service) {
+			return r.routes[i].Clusters
+		}
+	}
+	return []string{Default}
+}
+
+// setActive records the cluster serving the callee service.
+func (r *Router) setActive(namespace, service, cluster string) {
+	key := namespace + "/" + service
+	r.mu.RLock()
+	old := r.active[key]
+	r.mu.RUnlock()
+	if old == cluster {
+		return
+	}
+	r.mu.Lock()
+	r.active[key] = cluster
+	r.mu.Unlock()
+}
+
+// Active returns the cluster serving the callee service at last,
+// or the first cluster routed to if the service has not been served.
+func (r *Router) Active(namespace, service string) string {
+	r.mu.RLock()
+	cluster, ok := r.active[namespace+"/"+service]
+	r.mu.RUnlock()
+	if ok {
+		return cluster
+	}
+	return r.Clusters(namespace, service)[0]
+}
@@ -0,0 +1,147 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package cluster
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/naming/circuitbreaker"
+	"trpc.group/trpc-go/trpc-go/naming/discovery"
+	"trpc.group/trpc-go/trpc-go/naming/registry"
+	"trpc.group/trpc-go/trpc-go/naming/selector"
+	"trpc.group/trpc-go/trpc-go/naming/servicerouter"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter(t *testing.T) {
+	r := NewRouter([]Route{
+		{Namespaces: []string{"Production"}, Services: []string{"a"}, Clusters: []string{"new", Default}},
+		{Namespaces: []string{"Production"}, Clusters: []string{Default, "new"}},
+		{Services: []string{"b"}, Clusters: []string{"new"}},
+	})
+	assert.Equal(t, []string{"new", Default}, r.Clusters("Production", "a"))
+	assert.Equal(t, []string{Default, "new"}, r.Clusters("Production", "b"))
+	assert.Equal(t, []string{"new"}, r.Clusters("Development", "b"))
+	assert.Equal(t, []string{Default}, r.Clusters("Development", "a"))
+
+	assert.Equal(t, "new", r.Active("Production", "a"))
+	r.setActive("Production", "a", Default)
+	assert.Equal(t, Default, r.Active("Production", "a"))
+	assert.Equal(t, "polarismesh.new", ComponentName("polarismesh", "new"))
+}
+
+// fakeComponent implements the selector, discovery, service router and circuit breaker of a cluster.
+type fakeComponent struct {
+	cluster  string
+	err      error
+	reported []*registry.Node
+}
+
+func (f *fakeComponent) Select(serviceName string, _ ...selector.Option) (*registry.Node, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &registry.Node{ServiceName: serviceName, Address: f.cluster}, nil
+}
+
+func (f *fakeComponent) List(serviceName string, _ ...discovery.Option) ([]*registry.Node, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []*registry.Node{{ServiceName: serviceName, Address: f.cluster}}, nil
+}
+
+func (f *fakeComponent) Filter(_ string, nodes []*registry.Node, _ ...servicerouter.Option) ([]*registry.Node, error) {
+	return []*registry.Node{{Address: f.cluster}}, nil
+}
+
+func (f *fakeComponent) Available(*registry.Node) bool {
+	return f.err == nil
+}
+
+func (f *fakeComponent) Report(node *registry.Node, _ time.Duration, _ error) error {
+	f.reported = append(f.reported, node)
+	return nil
+}
+
+func setupFakes(name string, clusters ...string) map[string]*fakeComponent {
+	fakes := make(map[string]*fakeComponent)
+	for _, cluster := range clusters {
+		f := &fakeComponent{cluster: cluster}
+		component := ComponentName(name, cluster)
+		selector.Register(component, f)
+		discovery.Register(component, f)
+		servicerouter.Register(component, f)
+		circuitbreaker.Register(component, f)
+		fakes[cluster] = f
+	}
+	return fakes
+}
+
+func TestSetup(t *testing.T) {
+	const name = "polarismesh-cluster"
+	router := NewRouter([]Route{{Services: []string{"svc"}, Clusters: []string{"new", Default}}})
+	require.NotNil(t, Setup(name, []string{Default, "new"}, router, false))
+
+	fakes := setupFakes(name, Default, "new")
+	require.Nil(t, Setup(name, []string{Default, "new"}, router, false))
+
+	s := selector.Get(name)
+	node, err := s.Select("svc", selector.WithNamespace("Production"))
+	require.Nil(t, err)
+	assert.Equal(t, "new", node.Address)
+	assert.Equal(t, "new", node.Metadata[MetadataKey])
+	require.Nil(t, s.Report(node, time.Second, nil))
+	assert.Len(t, fakes["new"].reported, 1)
+
+	fakes["new"].err = errors.New("unreachable")
+	node, err = s.Select("svc", selector.WithNamespace("Production"))
+	require.Nil(t, err)
+	assert.Equal(t, Default, node.Address, "fall back to the next cluster")
+	assert.Equal(t, Default, router.Active("Production", "svc"))
+
+	nodes, err := discovery.Get(name).List("svc", discovery.WithNamespace("Production"))
+	require.Nil(t, err)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, Default, nodes[0].Metadata[MetadataKey])
+
+	fakes[Default].err = errors.New("unreachable")
+	_, err = s.Select("svc", selector.WithNamespace("Production"))
+	assert.ErrorContains(t, err, "cluster default: unreachable")
+	_, err = discovery.Get(name).List("svc", discovery.WithNamespace("Production"))
+	assert.ErrorContains(t, err, "cluster default: unreachable")
+
+	fakes["new"].err, fakes[Default].err = nil, nil
+	nodes, err = servicerouter.Get(name).Filter("svc", []*registry.Node{{Metadata: map[string]interface{}{
+		MetadataKey: "new",
+	}}})
+	require.Nil(t, err)
+	assert.Equal(t, "new", nodes[0].Address)
+	nodes, err = servicerouter.Get(name).Filter("svc", nil, servicerouter.WithNamespace("Production"))
+	require.Nil(t, err)
+	assert.Equal(t, Default, nodes[0].Address, "the active cluster")
+
+	cb := circuitbreaker.Get(name)
+	node = &registry.Node{ServiceName: "svc", Metadata: map[string]interface{}{"namespace": "Production"}}
+	assert.True(t, cb.Available(node))
+	require.Nil(t, cb.Report(node, time.Second, nil))
+	assert.Len(t, fakes[Default].reported, 1, "reported to the active cluster")
+	node.Metadata[MetadataKey] = "unknown"
+	assert.False(t, cb.Available(node))
+	assert.NotNil(t, cb.Report(node, time.Second, nil))
+}
@@ -0,0 +1,188 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package cluster
+
+import (
+	"fmt"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/log"
+	"trpc.group/trpc-go/trpc-go/naming/circuitbreaker"
+	"trpc.group/trpc-go/trpc-go/naming/discovery"
+	"trpc.group/trpc-go/trpc-go/naming/registry"
+	"trpc.group/trpc-go/trpc-go/naming/selector"
+	"trpc.group/trpc-go/trpc-go/naming/servicerouter"
+)
+
+// Setup registers the selector, discovery, service router and circuit breaker named name,
+// which route the requests to the components named ComponentName(name, cluster) of the clusters.
+// The components of all clusters must have been set up.
+func Setup(name string, clusters []string, router *Router, setDefault bool) error {
+	s := &Selector{router: router, selectors: make(map[string]selector.Selector)}
+	d := &Discovery{router: router, discoveries: make(map[string]discovery.Discovery)}
+	sr := &ServiceRouter{router: router, routers: make(map[string]servicerouter.ServiceRouter)}
+	cb := &CircuitBreaker{router: router, breakers: make(map[string]circuitbreaker.CircuitBreaker)}
+	for _, cluster := range clusters {
+		component := ComponentName(name, cluster)
+		if s.selectors[cluster] = selector.Get(component); s.selectors[cluster] == nil {
+			return fmt.Errorf("selector %s of cluster %s is not found", component, cluster)
+		}
+		if d.discoveries[cluster] = discovery.Get(component); d.discoveries[cluster] == nil {
+			return fmt.Errorf("discovery %s of cluster %s is not found", component, cluster)
+		}
+		if sr.routers[cluster] = servicerouter.Get(component); sr.routers[cluster] == nil {
+			return fmt.Errorf("service router %s of cluster %s is not found", component, cluster)
+		}
+		if cb.breakers[cluster] = circuitbreaker.Get(component); cb.breakers[cluster] == nil {
+			return fmt.Errorf("circuit breaker %s of cluster %s is not found", component, cluster)
+		}
+	}
+	selector.Register(name, s)
+	discovery.Register(name, d)
+	servicerouter.Register(name, sr)
+	circuitbreaker.Register(name, cb)
+	if setDefault {
+		discovery.SetDefaultDiscovery(d)
+		servicerouter.SetDefaultServiceRouter(sr)
+		circuitbreaker.SetDefaultCircuitBreaker(cb)
+	}
+	return nil
+}
+
+// nodeCluster returns the cluster recorded in the node, or the active cluster of the callee service.
+func (r *Router) nodeCluster(node *registry.Node) string {
+	if cluster, ok := node.Metadata[MetadataKey].(string); ok {
+		return cluster
+	}
+	namespace, _ := node.Metadata["namespace"].(string)
+	return r.Active(namespace, node.ServiceName)
+}
+
+// Selector selects the node from the clusters of the callee service in the fallback order.
+type Selector struct {
+	router    *Router
+	selectors map[string]selector.Selector
+}
+
+// Select selects the node from the first cluster which succeeds.
+func (s *Selector) Select(serviceName string, opt ...selector.Option) (*registry.Node, error) {
+	opts := &selector.Options{}
+	for _, o := range opt {
+		o(opts)
+	}
+	var err error
+	for _, cluster := range s.router.Clusters(opts.Namespace, serviceName) {
+		var node *registry.Node
+		if node, err = s.selectors[cluster].Select(serviceName, opt...); err != nil {
+			log.Debugf("[NAMING-POLARISMESH] select %s from cluster %s err: %v", serviceName, cluster, err)
+			err = fmt.Errorf("cluster %s: %w", cluster, err)
+			continue
+		}
+		if node.Metadata == nil {
+			node.Metadata = make(map[string]interface{})
+		}
+		node.Metadata[MetadataKey] = cluster
+		s.router.setActive(opts.Namespace, serviceName, cluster)
+		return node, nil
+	}
+	return nil, err
+}
+
+// Report reports the request status to the cluster which the node is selected from.
+func (s *Selector) Report(node *registry.Node, cost time.Duration, err error) error {
+	sel, ok := s.selectors[s.router.nodeCluster(node)]
+	if !ok {
+		return fmt.Errorf("report err: unknown cluster of node %s", node.Address)
+	}
+	return sel.Report(node, cost, err)
+}
+
+// Discovery lists the nodes from the clusters of the callee service in the fallback order.
+type Discovery struct {
+	router      *Router
+	discoveries map[string]discovery.Discovery
+}
+
+// List lists the nodes from the first cluster which succeeds.
+func (d *Discovery) List(serviceName string, opt ...discovery.Option) ([]*registry.Node, error) {
+	opts := &discovery.Options{}
+	for _, o := range opt {
+		o(opts)
+	}
+	var err error
+	for _, cluster := range d.router.Clusters(opts.Namespace, serviceName) {
+		var nodes []*registry.Node
+		if nodes, err = d.discoveries[cluster].List(serviceName, opt...); err != nil {
+			log.Debugf("[NAMING-POLARISMESH] list %s from cluster %s err: %v", serviceName, cluster, err)
+			err = fmt.Errorf("cluster %s: %w", cluster, err)
+			continue
+		}
+		for _, node := range nodes {
+			if node.Metadata == nil {
+				node.Metadata = make(map[string]interface{})
+			}
+			node.Metadata[MetadataKey] = cluster
+		}
+		d.router.setActive(opts.Namespace, serviceName, cluster)
+		return nodes, nil
+	}
+	return nil, err
+}
+
+// ServiceRouter filters the nodes by the service router of the cluster which the nodes are listed from.
+type ServiceRouter struct {
+	router  *Router
+	routers map[string]servicerouter.ServiceRouter
+}
+
+// Filter filters the nodes listed by Discovery.
+func (r *ServiceRouter) Filter(serviceName string,
+	nodes []*registry.Node, opt ...servicerouter.Option) ([]*registry.Node, error) {
+	var cluster string
+	if len(nodes) > 0 {
+		cluster = r.router.nodeCluster(nodes[0])
+	} else {
+		opts := &servicerouter.Options{}
+		for _, o := range opt {
+			o(opts)
+		}
+		cluster = r.router.Active(opts.Namespace, serviceName)
+	}
+	sr, ok := r.routers[cluster]
+	if !ok {
+		return nil, fmt.Errorf("filter err: unknown cluster %s", cluster)
+	}
+	return sr.Filter(serviceName, nodes, opt...)
+}
+
+// CircuitBreaker reports the request status to the cluster serving the callee service.
+type CircuitBreaker struct {
+	router   *Router
+	breakers map[string]circuitbreaker.CircuitBreaker
+}
+
+// Available determines whether the node is available by the circuit breaker of its cluster.
+func (cb *CircuitBreaker) Available(node *registry.Node) bool {
+	b, ok := cb.breakers[cb.router.nodeCluster(node)]
+	return ok && b.Available(node)
+}
+
+// Report reports the request status to the circuit breaker of the cluster of the node.
+func (cb *CircuitBreaker) Report(node *registry.Node, cost time.Duration, err error) error {
+	b, ok := cb.breakers[cb.router.nodeCluster(node)]
+	if !ok {
+		return fmt.Errorf("report err: unknown cluster of node %s", node.Address)
+	}
+	return b.Report(node, cost, err)
+}
@@ -0,0 +1,102 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package naming
+
+import (
+	"testing"
+
+	tcb "trpc.group/trpc-go/trpc-go/naming/circuitbreaker"
+	tdiscovery "trpc.group/trpc-go/trpc-go/naming/discovery"
+	tselector "trpc.group/trpc-go/trpc-go/naming/selector"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/cluster"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/selector"
+
+	"github.com/polarismesh/polaris-go/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const clusterConfig = `
+address_list: 127.0.0.1:0
+default: false
+persistDir: /tmp/polarismesh/backup
+clusters:
+  - name: new
+    address_list: 127.0.0.2:0
+    connect_timeout: 500
+cluster_routes:
+  - namespaces: [Production]
+    clusters: [new, default]
+`
+
+func TestSetupClusters(t *testing.T) {
+	const name = "polarismesh-clusters"
+	var node yaml.Node
+	require.Nil(t, yaml.Unmarshal([]byte(clusterConfig), &node))
+	require.Nil(t, (&SelectorFactory{}).Setup(name, &node))
+
+	_, ok := tselector.Get(name).(*cluster.Selector)
+	assert.True(t, ok)
+	_, ok = tdiscovery.Get(name).(*cluster.Discovery)
+	assert.True(t, ok)
+	for _, c := range []string{cluster.Default, "new"} {
+		s, ok := tselector.Get(cluster.ComponentName(name, c)).(*selector.Selector)
+		require.True(t, ok)
+		assert.Equal(t, cluster.ComponentName(name, c), s.GetCfg().Name)
+		assert.NotNil(t, tcb.Get(cluster.ComponentName(name, c)))
+	}
+
+	require.Nil(t, reloadYAML(name, []byte(clusterConfig+`
+enable_canary: true
+`)))
+	for _, c := range []string{cluster.Default, "new"} {
+		s := tselector.Get(cluster.ComponentName(name, c)).(*selector.Selector)
+		assert.True(t, s.GetCfg().EnableCanary)
+	}
+	assert.ErrorContains(t, reloadYAML(name, []byte(clusterConfig+`
+cluster_routes:
+  - clusters: [new]
+`)), "cluster_routes")
+}
+
+func TestConfig_clusterConfig(t *testing.T) {
+	persistDir := "/tmp/polarismesh/backup"
+	conf := &Config{
+		Name:           "polarismesh",
+		AddressList:    "127.0.0.1:0",
+		Protocol:       config.DefaultServerConnector,
+		ConnectTimeout: 1000,
+		PersistDir:     &persistDir,
+		Clusters:       []ClusterConfig{{Name: "new", AddressList: "127.0.0.2:0", ConnectTimeout: 500}},
+		ClusterRoutes:  []cluster.Route{{Clusters: []string{"new"}}},
+	}
+	assert.Equal(t, []string{"polarismesh.default", "polarismesh.new"}, conf.componentNames())
+
+	cc := conf.clusterConfig(&ClusterConfig{Name: cluster.Default})
+	assert.Equal(t, "polarismesh.default", cc.Name)
+	assert.False(t, cc.getSetDefault())
+	assert.Equal(t, "127.0.0.1:0", cc.AddressList)
+	assert.Equal(t, persistDir, *cc.PersistDir)
+	assert.Nil(t, cc.Clusters)
+
+	cc = conf.clusterConfig(&conf.Clusters[0])
+	assert.Equal(t, "polarismesh.new", cc.Name)
+	assert.Equal(t, "127.0.0.2:0", cc.AddressList)
+	assert.Equal(t, config.DefaultServerConnector, cc.Protocol)
+	assert.Equal(t, 500, cc.ConnectTimeout)
+	assert.Equal(t, "/tmp/polarismesh/backup/new", *cc.PersistDir)
+	assert.Nil(t, cc.ClusterRoutes)
+	assert.Equal(t, []string{"polarismesh"}, (&Config{}).componentNames())
+}
@@ -0,0 +1,258 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+// Package config reads and watches the config files in polaris mesh configuration center
+// as a trpc-go config data provider.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	trpcconfig "trpc.group/trpc-go/trpc-go/config"
+	"trpc.group/trpc-go/trpc-go/log"
+	"trpc.group/trpc-go/trpc-go/plugin"
+	secure "trpc.group/trpc-go/trpc-naming-polarismesh/internal/connector"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/validate"
+
+	"github.com/polarismesh/polaris-go/api"
+	"github.com/polarismesh/polaris-go/pkg/model"
+)
+
+const (
+	pluginType = "config"
+	// ProviderName is the default name of the config data provider.
+	ProviderName        = "polarismesh"
+	defaultSelectorName = "polarismesh"
+	// DefaultNamespace is the default namespace of the config files.
+	DefaultNamespace = "default"
+	// DefaultPort is the default port of polaris mesh configuration center.
+	DefaultPort = 8093
+)
+
+func init() {
+	plugin.Register(ProviderName, &Factory{})
+}
+
+// Config is the config of the provider.
+type Config struct {
+	// Name is the name of the provider, default as polarismesh.
+	Name string `yaml:"name"`
+	// Selector is the name of the selector plugin whose polaris mesh server settings are reused,
+	// default as polarismesh.
+	Selector string `yaml:"selector"`
+	// Namespace is the namespace of the config files, default as default.
+	Namespace string `yaml:"namespace"`
+	// AddressList is the addresses of the configuration center,
+	// default as the hosts of the selector with Port.
+	AddressList string `yaml:"address_list"`
+	// Port is the port of the configuration center used with the hosts of the selector, default as 8093.
+	Port int `yaml:"port"`
+	// ConnectTimeout is the timeout to connect to the configuration center in ms, default as that of the selector.
+	ConnectTimeout int `yaml:"connect_timeout"`
+	// MessageTimeout is the timeout to receive a message, default as that of the selector.
+	MessageTimeout *time.Duration `yaml:"message_timeout"`
+}
+
+// Validate checks the config, and returns all problems found at once.
+func (c *Config) Validate() error {
+	var p validate.Problems
+	p.AddressList("address_list", c.AddressList)
+	if c.Port < 0 || c.Port > 65535 {
+		p.Addf("port must be in [0, 65535], got %d", c.Port)
+	}
+	p.NonNegative("connect_timeout", c.ConnectTimeout)
+	p.NonNegativeDuration("message_timeout", c.MessageTimeout)
+	return p.Err()
+}
+
+// Factory is the plugin which registers the polaris mesh config data provider.
+type Factory struct{}
+
+// Type returns the plugin type.
+func (f *Factory) Type() string {
+	return pluginType
+}
+
+// Setup creates the provider with the server settings of the selector, and registers it.
+func (f *Factory) Setup(name string, dec plugin.Decoder) error {
+	if dec == nil {
+		return errors.New("config provider config decoder empty")
+	}
+	cfg := &Config{}
+	if err := dec.Decode(cfg); err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config provider config: %w", err)
+	}
+	sdkCtx, err := selectorSDKCtx(cfg.Selector)
+	if err != nil {
+		return err
+	}
+	if secure.Get(sdkCtx.GetConfig()) != nil {
+		log.Warnf("[NAMING-POLARISMESH] config provider connects to the configuration center in plain text "+
+			"without the tls and auth_token of selector %s, which polaris-go does not support", cfg.Selector)
+	}
+	configCtx, err := newSDKCtx(sdkCtx, cfg)
+	if err != nil {
+		return fmt.Errorf("new sdk ctx of config provider err: %w", err)
+	}
+	trpcconfig.RegisterProvider(New(api.NewConfigFileAPIBySDKContext(configCtx), cfg))
+	return nil
+}
+
+// FlexDependsOn makes sure that the provider is set up after the selector, whose settings are reused.
+func (f *Factory) FlexDependsOn() []string {
+	return []string{"selector-polarismesh"}
+}
+
+// selectorSDKCtx returns the SDK context of the selector plugin.
+func selectorSDKCtx(name string) (api.SDKContext, error) {
+	if name == "" {
+		name = defaultSelectorName
+	}
+	f, ok := plugin.Get("selector", name).(interface{ GetSDKCtx() api.SDKContext })
+	if !ok || f.GetSDKCtx() == nil {
+		return nil, fmt.Errorf("selector %s is not set up", name)
+	}
+	return f.GetSDKCtx(), nil
+}
+
+// newSDKCtx creates the SDK context connecting to the configuration center,
+// the settings not configured are taken from the server connector of the selector.
+// The security settings of the selector are applied to the connections to the naming servers only,
+// as the config connector of polaris-go always connects in plain text.
+func newSDKCtx(selectorCtx api.SDKContext, cfg *Config) (api.SDKContext, error) {
+	server := selectorCtx.GetConfig().GetGlobal().GetServerConnector()
+	c := api.NewConfiguration()
+	c.GetGlobal().GetServerConnector().SetAddresses(server.GetAddresses())
+	connector := c.GetConfigFile().GetConfigConnectorConfig()
+	connector.SetAddresses(configAddresses(server.GetAddresses(), cfg))
+	connectTimeout := server.GetConnectTimeout()
+	if cfg.ConnectTimeout != 0 {
+		connectTimeout = time.Duration(cfg.ConnectTimeout) * time.Millisecond
+	}
+	connector.SetConnectTimeout(connectTimeout)
+	messageTimeout := server.GetMessageTimeout()
+	if cfg.MessageTimeout != nil {
+		messageTimeout = *cfg.MessageTimeout
+	}
+	connector.SetMessageTimeout(messageTimeout)
+	if security := secure.Get(selectorCtx.GetConfig()); security != nil {
+		if err := secure.Set(c, security.TLS, security.AuthToken); err != nil {
+			return nil, err
+		}
+	}
+	return api.InitContextByConfig(c)
+}
+
+// configAddresses returns the addresses of the configuration center.
+func configAddresses(serverAddresses []string, cfg *Config) []string {
+	if cfg.AddressList != "" {
+		return strings.Split(cfg.AddressList, ",")
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	addresses := make([]string, 0, len(serverAddresses))
+	for _, address := range serverAddresses {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		addresses = append(addresses, net.JoinHostPort(host, strconv.Itoa(port)))
+	}
+	return addresses
+}
+
+// Provider reads and watches the config files by the path of group/file name in the namespace.
+type Provider struct {
+	name      string
+	namespace string
+	configAPI api.ConfigFileAPI
+
+	mu        sync.RWMutex
+	callbacks []trpcconfig.ProviderCallback
+	watched   map[string]bool // paths listened.
+}
+
+// New creates a provider.
+func New(configAPI api.ConfigFileAPI, cfg *Config) *Provider {
+	name := cfg.Name
+	if name == "" {
+		name = ProviderName
+	}
+	namespace := cfg.Namespace
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	return &Provider{
+		name:      name,
+		namespace: namespace,
+		configAPI: configAPI,
+		watched:   make(map[string]bool),
+	}
+}
+
+// Name returns the name of the provider.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Read reads the content of the config file by the path of group/file name,
+// the file name may contain slashes. The file is watched after it is read.
+func (p *Provider) Read(path string) ([]byte, error) {
+	group, file, ok := strings.Cut(path, "/")
+	if !ok || group == "" || file == "" {
+		return nil, fmt.Errorf("invalid config path %s, which should be group/file name", path)
+	}
+	f, err := p.configAPI.GetConfigFile(p.namespace, group, file)
+	if err != nil {
+		return nil, fmt.Errorf("get config file %s/%s err: %w", p.namespace, path, err)
+	}
+	p.listen(path, f)
+	return []byte(f.GetContent()), nil
+}
+
+// Watch adds the callback of the changes of the config files read.
+func (p *Provider) Watch(cb trpcconfig.ProviderCallback) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.callbacks = append(p.callbacks, cb)
+}
+
+// listen listens the changes of the config file once.
+func (p *Provider) listen(path string, f model.ConfigFile) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.watched[path] {
+		return
+	}
+	p.watched[path] = true
+	f.AddChangeListener(func(event model.ConfigFileChangeEvent) {
+		log.Infof("[NAMING-POLARISMESH] config file %s/%s is changed", p.namespace, path)
+		p.mu.RLock()
+		callbacks := p.callbacks
+		p.mu.RUnlock()
+		for _, cb := range callbacks {
+			cb(path, []byte(event.NewValue))
+		}
+	})
+}
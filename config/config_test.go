@@ -0,0 +1,132 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/plugin"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_api"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_model"
+
+	"github.com/golang/mock/gomock"
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestProviderReadAndWatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	configAPI := mock_api.NewMockConfigFileAPI(ctrl)
+	file := mock_model.NewMockConfigFile(ctrl)
+	var listener model.OnConfigFileChange
+	configAPI.EXPECT().GetConfigFile("ns", "group", "dir/app.yaml").Return(file, nil).Times(2)
+	file.EXPECT().GetContent().Return("v1").Times(2)
+	file.EXPECT().AddChangeListener(gomock.Any()).DoAndReturn(func(cb model.OnConfigFileChange) {
+		listener = cb
+	}).Times(1)
+
+	p := New(configAPI, &Config{Namespace: "ns"})
+	assert.Equal(t, ProviderName, p.Name())
+	for i := 0; i < 2; i++ {
+		data, err := p.Read("group/dir/app.yaml")
+		require.Nil(t, err)
+		assert.Equal(t, "v1", string(data))
+	}
+
+	var paths, values []string
+	p.Watch(func(path string, data []byte) {
+		paths = append(paths, path)
+		values = append(values, string(data))
+	})
+	require.NotNil(t, listener)
+	listener(model.ConfigFileChangeEvent{OldValue: "v1", NewValue: "v2"})
+	assert.Equal(t, []string{"group/dir/app.yaml"}, paths)
+	assert.Equal(t, []string{"v2"}, values)
+}
+
+func TestProviderReadErr(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	configAPI := mock_api.NewMockConfigFileAPI(ctrl)
+	p := New(configAPI, &Config{Name: "conf"})
+	assert.Equal(t, "conf", p.Name())
+	for _, path := range []string{"app.yaml", "/app.yaml", "group/"} {
+		_, err := p.Read(path)
+		assert.ErrorContains(t, err, "invalid config path")
+	}
+
+	configAPI.EXPECT().GetConfigFile(DefaultNamespace, "group", "app.yaml").Return(nil, errors.New("timeout"))
+	_, err := p.Read("group/app.yaml")
+	assert.ErrorContains(t, err, "get config file default/group/app.yaml err: timeout")
+}
+
+func TestConfigAddresses(t *testing.T) {
+	servers := []string{"127.0.0.1:8091", "polaris.example.com:8091"}
+	assert.Equal(t, []string{"127.0.0.1:8093", "polaris.example.com:8093"},
+		configAddresses(servers, &Config{}))
+	assert.Equal(t, []string{"127.0.0.1:9093", "polaris.example.com:9093"},
+		configAddresses(servers, &Config{Port: 9093}))
+	assert.Equal(t, []string{"10.0.0.1:8093", "10.0.0.2:8093"},
+		configAddresses(servers, &Config{AddressList: "10.0.0.1:8093,10.0.0.2:8093"}))
+}
+
+func TestConfigValidate(t *testing.T) {
+	timeout := -time.Second
+	err := (&Config{
+		AddressList:    "127.0.0.1",
+		Port:           70000,
+		ConnectTimeout: -1,
+		MessageTimeout: &timeout,
+	}).Validate()
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "4 config problem(s)")
+	assert.Nil(t, (&Config{AddressList: "127.0.0.1:8093", Port: 8093}).Validate())
+}
+
+type fakeSelectorFactory struct{}
+
+func (f *fakeSelectorFactory) Type() string {
+	return "selector"
+}
+
+func (f *fakeSelectorFactory) Setup(string, plugin.Decoder) error {
+	return nil
+}
+
+func TestFactorySetup(t *testing.T) {
+	f := &Factory{}
+	assert.Equal(t, "config", f.Type())
+	assert.Equal(t, []string{"selector-polarismesh"}, f.FlexDependsOn())
+	assert.NotNil(t, f.Setup(ProviderName, nil))
+
+	decode := func(cfg string) *yaml.Node {
+		var node yaml.Node
+		require.Nil(t, yaml.Unmarshal([]byte(cfg), &node))
+		return &node
+	}
+	assert.ErrorContains(t, f.Setup(ProviderName, decode(`port: -1`)),
+		"invalid config provider config: 1 config problem(s): port must be in [0, 65535], got -1")
+	assert.ErrorContains(t, f.Setup(ProviderName, decode(`selector: not.setup`)),
+		"selector not.setup is not set up")
+	plugin.Register("polarismesh-config", &fakeSelectorFactory{})
+	assert.ErrorContains(t, f.Setup(ProviderName, decode(`selector: polarismesh-config`)),
+		"selector polarismesh-config is not set up")
+}
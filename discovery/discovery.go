@@ -19,6 +19,7 @@ import (
 
 	"trpc.group/trpc-go/trpc-go/naming/discovery"
 	"trpc.group/trpc-go/trpc-go/naming/registry"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/tracing"
 
 	"github.com/polarismesh/polaris-go/api"
 	"github.com/polarismesh/polaris-go/pkg/model"
@@ -70,6 +71,10 @@ func (d *Discovery) List(serviceName string, opt ...discovery.Option) (nodes []*
 	if err != nil {
 		return nil, err
 	}
+	_, span := tracing.Start(opts.Ctx, tracing.SpanDiscoveryList)
+	span.SetAttribute(tracing.AttrService, serviceName)
+	span.SetAttribute(tracing.AttrNamespace, opts.Namespace)
+	defer func() { span.End(err) }()
 
 	req := &api.GetInstancesRequest{
 		GetInstancesRequest: model.GetInstancesRequest{
@@ -8,6 +8,7 @@ require (
 	github.com/polarismesh/polaris-go v1.5.5
 	github.com/polarismesh/specification v1.4.1
 	github.com/stretchr/testify v1.8.4
+	google.golang.org/grpc v1.51.0
 	gopkg.in/yaml.v3 v3.0.1
 	trpc.group/trpc-go/trpc-go v1.0.0
 )
@@ -53,7 +54,6 @@ require (
 	golang.org/x/sys v0.4.0 // indirect
 	golang.org/x/text v0.6.0 // indirect
 	google.golang.org/genproto v0.0.0-20221014213838-99cd37c6964a // indirect
-	google.golang.org/grpc v1.51.0 // indirect
 	google.golang.org/protobuf v1.30.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	trpc.group/trpc-go/tnet v0.0.0-20230810071536-9d05338021cf // indirect
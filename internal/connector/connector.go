@@ -0,0 +1,284 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+// Package connector provides a grpc server connector of polaris-go, which connects to the polaris mesh servers
+// by TLS and authenticates by the access token. It is selected as the protocol of the server connector
+// only if the security settings are configured.
+package connector
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/polarismesh/polaris-go/pkg/config"
+	"github.com/polarismesh/polaris-go/pkg/log"
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"github.com/polarismesh/polaris-go/pkg/network"
+	"github.com/polarismesh/polaris-go/pkg/plugin"
+	"github.com/polarismesh/polaris-go/plugin/serverconnector/grpc"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/stats"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Protocol is the name of the server connector, whose plugin config holds the security settings.
+	Protocol = "grpc_secure"
+	// TokenHeader is the header carrying the access token to the polaris mesh servers.
+	TokenHeader = "x-polaris-token"
+
+	protocolGrpc = "grpc"
+)
+
+func init() {
+	plugin.RegisterConfigurablePlugin(&Connector{}, &Config{})
+}
+
+// TLSConfig is the TLS config to connect to the polaris mesh servers.
+type TLSConfig struct {
+	// CAFile is the CA bundle to verify the servers, default as the system one.
+	CAFile string `yaml:"ca_file"`
+	// CertFile and KeyFile are the client certificate and key, both or neither of them must be set.
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// ServerName is the name to verify the certificates of the servers, default as the host of the address.
+	ServerName string `yaml:"server_name"`
+}
+
+// Validate checks the config.
+func (c *TLSConfig) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("cert_file and key_file must be set together")
+	}
+	return nil
+}
+
+// Config is the security settings of the connections to the polaris mesh servers.
+type Config struct {
+	TLS       *TLSConfig `yaml:"tls"`
+	AuthToken string     `yaml:"auth_token"`
+}
+
+// Verify implements config.BaseConfig.
+func (c *Config) Verify() error {
+	if c.TLS != nil {
+		return c.TLS.Validate()
+	}
+	return nil
+}
+
+// SetDefault implements config.BaseConfig.
+func (c *Config) SetDefault() {}
+
+// enabled returns whether any security setting is configured.
+func (c *Config) enabled() bool {
+	return c != nil && (c.TLS != nil || c.AuthToken != "")
+}
+
+// Set selects the connector as the server connector of the SDK configuration with the security settings.
+// Nothing is set if neither of them is configured. The TLS files are loaded here to fail before the SDK
+// context is initialized, as polaris-go does not clean up well if a plugin fails to initialize.
+func Set(c config.Configuration, tlsConfig *TLSConfig, authToken string) error {
+	cfg := &Config{TLS: tlsConfig, AuthToken: authToken}
+	if !cfg.enabled() {
+		return nil
+	}
+	if tlsConfig != nil {
+		if _, err := NewTLSConfig(tlsConfig); err != nil {
+			return err
+		}
+	}
+	serverConnector := c.GetGlobal().GetServerConnector()
+	if err := serverConnector.SetPluginConfig(Protocol, cfg); err != nil {
+		return err
+	}
+	serverConnector.SetProtocol(Protocol)
+	return nil
+}
+
+// Get returns the security settings of the SDK configuration, or nil if the connector is not selected.
+func Get(c config.Configuration) *Config {
+	serverConnector := c.GetGlobal().GetServerConnector()
+	if serverConnector.GetProtocol() != Protocol {
+		return nil
+	}
+	cfg, _ := serverConnector.GetPluginConfig(Protocol).(*Config)
+	if !cfg.enabled() {
+		return nil
+	}
+	return cfg
+}
+
+// Connector is the grpc server connector, which creates the connections with the security settings.
+type Connector struct {
+	grpc.Connector
+	dialOptions []ggrpc.DialOption
+}
+
+// Name returns the name of the connector, which is selected by the protocol of the server connector.
+func (c *Connector) Name() string {
+	return Protocol
+}
+
+// IsEnable enables the connector only if it is selected, so that it is not loaded by the other SDK contexts.
+func (c *Connector) IsEnable(cfg config.Configuration) bool {
+	return cfg.GetGlobal().GetServerConnector().GetProtocol() == Protocol && c.Connector.IsEnable(cfg)
+}
+
+// Init initializes the grpc server connector, and sets the connector creating the connections
+// with the security settings as the connection creator.
+func (c *Connector) Init(ctx *plugin.InitContext) error {
+	if err := c.Connector.Init(ctx); err != nil {
+		return err
+	}
+	serverConnector := ctx.Config.GetGlobal().GetServerConnector()
+	cfg, _ := serverConnector.GetPluginConfig(Protocol).(*Config)
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	opts, err := dialOptions(cfg, maxCallRecvMsgSize(serverConnector.GetPluginConfig(protocolGrpc)))
+	if err != nil {
+		return err
+	}
+	c.dialOptions = opts
+	log.GetBaseLogger().Infof("set %s plugin as connectionCreator", c.Name())
+	c.GetConnectionManager().SetConnCreator(c)
+	return nil
+}
+
+// CreateConnection creates the connection with the security settings.
+func (c *Connector) CreateConnection(
+	address string, timeout time.Duration, clientInfo *network.ClientInfo) (network.ClosableConn, error) {
+	opts := append([]ggrpc.DialOption{ggrpc.WithBlock()}, c.dialOptions...)
+	if clientInfo.GetIPString() == "" {
+		opts = append(opts, ggrpc.WithStatsHandler(&statsHandler{clientInfo: clientInfo}))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return ggrpc.DialContext(ctx, address, opts...)
+}
+
+// dialOptions returns the dial options of the security settings.
+func dialOptions(cfg *Config, maxRecvMsgSize int) ([]ggrpc.DialOption, error) {
+	var opts []ggrpc.DialOption
+	if maxRecvMsgSize > 0 {
+		opts = append(opts, ggrpc.WithDefaultCallOptions(ggrpc.MaxCallRecvMsgSize(maxRecvMsgSize)))
+	}
+	if cfg.TLS == nil {
+		opts = append(opts, ggrpc.WithTransportCredentials(insecure.NewCredentials()))
+	} else {
+		tlsConfig, err := NewTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, ggrpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	}
+	if cfg.AuthToken != "" {
+		opts = append(opts, ggrpc.WithPerRPCCredentials(tokenCredentials{
+			token:  cfg.AuthToken,
+			secure: cfg.TLS != nil,
+		}))
+	}
+	return opts, nil
+}
+
+// NewTLSConfig loads the CA bundle and client certificate of the config.
+func NewTLSConfig(c *TLSConfig) (*tls.Config, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{ServerName: c.ServerName, MinVersion: tls.VersionTLS12}
+	if c.CAFile != "" {
+		ca, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca_file err: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in ca_file %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load cert_file and key_file err: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// maxCallRecvMsgSize returns the max message size of the plugin config of the grpc connector,
+// whose type is unexported.
+func maxCallRecvMsgSize(cfg config.BaseConfig) int {
+	var c struct {
+		MaxCallRecvMsgSize int `yaml:"maxCallRecvMsgSize"`
+	}
+	if buf, err := yaml.Marshal(cfg); err == nil {
+		_ = yaml.Unmarshal(buf, &c)
+	}
+	return c.MaxCallRecvMsgSize
+}
+
+// tokenCredentials carries the access token in the header of each request.
+type tokenCredentials struct {
+	token  string
+	secure bool
+}
+
+// GetRequestMetadata implements credentials.PerRPCCredentials.
+func (t tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{TokenHeader: t.token}, nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials.
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return t.secure
+}
+
+// statsHandler records the local address of the connection as the grpc connector does.
+type statsHandler struct {
+	clientInfo *network.ClientInfo
+}
+
+// TagRPC implements stats.Handler.
+func (s *statsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+// HandleRPC implements stats.Handler.
+func (s *statsHandler) HandleRPC(context.Context, stats.RPCStats) {}
+
+// TagConn implements stats.Handler.
+func (s *statsHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	localAddr := info.LocalAddr.String()
+	localIP := strings.Split(localAddr, ":")[0]
+	hashValue, _ := model.HashStr(localIP)
+	log.GetBaseLogger().Infof(
+		"localAddress from connection is %s, IP is %s, hashValue is %d", localAddr, localIP, hashValue)
+	s.clientInfo.IP.Store(localIP)
+	s.clientInfo.HashKey.Store([]byte(localAddr))
+	return ctx
+}
+
+// HandleConn implements stats.Handler.
+func (s *statsHandler) HandleConn(context.Context, stats.ConnStats) {}
@@ -0,0 +1,175 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package connector
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/polarismesh/polaris-go/api"
+	"github.com/polarismesh/polaris-go/pkg/config"
+	"github.com/polarismesh/polaris-go/pkg/network"
+	"github.com/polarismesh/polaris-go/pkg/plugin/common"
+	"github.com/polarismesh/polaris-go/pkg/plugin/serverconnector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// writeCert writes a self signed certificate of localhost and its key to dir.
+func writeCert(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	certFile, keyFile := writeCert(t, t.TempDir())
+	c, err := NewTLSConfig(&TLSConfig{CAFile: certFile, CertFile: certFile, KeyFile: keyFile, ServerName: "polaris"})
+	require.Nil(t, err)
+	assert.Equal(t, "polaris", c.ServerName)
+	assert.NotNil(t, c.RootCAs)
+	assert.Len(t, c.Certificates, 1)
+
+	_, err = NewTLSConfig(&TLSConfig{CertFile: certFile})
+	assert.EqualError(t, err, "cert_file and key_file must be set together")
+	_, err = NewTLSConfig(&TLSConfig{CAFile: "not_exist"})
+	assert.ErrorContains(t, err, "read ca_file err")
+	_, err = NewTLSConfig(&TLSConfig{CAFile: keyFile})
+	assert.ErrorContains(t, err, "no certificate found in ca_file")
+	_, err = NewTLSConfig(&TLSConfig{CertFile: keyFile, KeyFile: certFile})
+	assert.ErrorContains(t, err, "load cert_file and key_file err")
+}
+
+func TestSet(t *testing.T) {
+	c := api.NewConfiguration()
+	require.Nil(t, Set(c, nil, ""))
+	assert.Equal(t, protocolGrpc, c.GetGlobal().GetServerConnector().GetProtocol())
+	assert.Nil(t, Get(c))
+
+	assert.NotNil(t, Set(c, &TLSConfig{KeyFile: "key.pem"}, ""))
+	assert.ErrorContains(t, Set(c, &TLSConfig{CAFile: "not_exist"}, ""), "read ca_file err")
+	assert.Equal(t, protocolGrpc, c.GetGlobal().GetServerConnector().GetProtocol())
+	require.Nil(t, Set(c, nil, "token"))
+	assert.Equal(t, Protocol, c.GetGlobal().GetServerConnector().GetProtocol())
+	assert.Equal(t, &Config{AuthToken: "token"}, Get(c))
+}
+
+func TestConnectorInit(t *testing.T) {
+	c := config.NewDefaultConfiguration([]string{"127.0.0.1:8091"})
+	assert.False(t, (&Connector{}).IsEnable(c), "not loaded without security settings")
+	require.Nil(t, Set(c, nil, "token"))
+	assert.True(t, (&Connector{}).IsEnable(c))
+	sdkCtx, err := api.InitContextByConfig(c)
+	require.Nil(t, err)
+	defer sdkCtx.Destroy()
+	p, err := sdkCtx.GetPlugins().GetPlugin(common.TypeServerConnector, Protocol)
+	require.Nil(t, err)
+	connector, ok := p.(*serverconnector.Proxy).ServerConnector.(*Connector)
+	require.True(t, ok)
+	assert.Len(t, connector.dialOptions, 3, "max message size, insecure and token")
+	assert.Greater(t, maxCallRecvMsgSize(c.GetGlobal().GetServerConnector().GetPluginConfig(protocolGrpc)), 0)
+	p, err = sdkCtx.GetPlugins().GetPlugin(common.TypeServerConnector, protocolGrpc)
+	require.Nil(t, err)
+	_, ok = p.(*serverconnector.Proxy).ServerConnector.(*Connector)
+	assert.False(t, ok, "the grpc connector is kept")
+}
+
+func TestCreateConnection(t *testing.T) {
+	certFile, keyFile := writeCert(t, t.TempDir())
+	serverTLS, err := NewTLSConfig(&TLSConfig{CertFile: certFile, KeyFile: keyFile})
+	require.Nil(t, err)
+	tokens := make(chan []string, 1)
+	s := ggrpc.NewServer(
+		ggrpc.Creds(credentials.NewTLS(serverTLS)),
+		ggrpc.UnknownServiceHandler(func(_ interface{}, stream ggrpc.ServerStream) error {
+			md, _ := metadata.FromIncomingContext(stream.Context())
+			tokens <- md.Get(TokenHeader)
+			return nil
+		}),
+	)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	go s.Serve(l)
+	defer s.Stop()
+
+	connector := &Connector{}
+	connector.dialOptions, err = dialOptions(&Config{
+		TLS:       &TLSConfig{CAFile: certFile, ServerName: "localhost"},
+		AuthToken: "token",
+	}, 0)
+	require.Nil(t, err)
+	clientInfo := &network.ClientInfo{}
+	conn, err := connector.CreateConnection(l.Addr().String(), time.Second, clientInfo)
+	require.Nil(t, err)
+	defer conn.Close()
+	_ = conn.(*ggrpc.ClientConn).Invoke(context.Background(), "/polaris.Test/Call", &emptyMessage{}, &emptyMessage{},
+		ggrpc.ForceCodec(emptyCodec{}))
+	assert.Equal(t, []string{"token"}, <-tokens)
+	assert.Equal(t, "127.0.0.1", clientInfo.GetIPString())
+
+	connector.dialOptions, err = dialOptions(&Config{TLS: &TLSConfig{ServerName: "localhost"}}, 0)
+	require.Nil(t, err)
+	_, err = connector.CreateConnection(l.Addr().String(), 100*time.Millisecond, &network.ClientInfo{})
+	assert.NotNil(t, err, "the self signed certificate is not trusted")
+}
+
+func TestTokenCredentials(t *testing.T) {
+	md, err := tokenCredentials{token: "token"}.GetRequestMetadata(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{TokenHeader: "token"}, md)
+	assert.False(t, tokenCredentials{}.RequireTransportSecurity())
+	assert.True(t, tokenCredentials{secure: true}.RequireTransportSecurity())
+}
+
+type emptyMessage struct{}
+
+type emptyCodec struct{}
+
+func (emptyCodec) Marshal(interface{}) ([]byte, error) { return nil, nil }
+
+func (emptyCodec) Unmarshal([]byte, interface{}) error { return nil }
+
+func (emptyCodec) Name() string { return "empty" }
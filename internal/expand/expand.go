@@ -0,0 +1,83 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+// Package expand expands the environment variables and loads the file secrets of the config values.
+package expand
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/validate"
+
+	"github.com/polarismesh/polaris-go/pkg/model"
+)
+
+// FilePrefix is the prefix of the values loaded from files.
+const FilePrefix = "file://"
+
+// String returns the content of the file if s is file://path, whose path is expanded and trailing newlines
+// are trimmed. Otherwise, s is returned with the environment variables like ${ENV} expanded.
+func String(s string) (string, error) {
+	if !strings.HasPrefix(s, FilePrefix) {
+		return os.ExpandEnv(s), nil
+	}
+	path := os.ExpandEnv(strings.TrimPrefix(s, FilePrefix))
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read file %s err: %w", path, err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// Expander expands the config values in place, and collects the problems of all fields.
+type Expander struct {
+	p validate.Problems
+}
+
+// String expands the value pointed by s if it is not nil.
+func (e *Expander) String(field string, s *string) {
+	if s == nil {
+		return
+	}
+	v, err := String(*s)
+	if err != nil {
+		e.p.Add(field, err)
+		return
+	}
+	*s = v
+}
+
+// Map expands the values of m.
+func (e *Expander) Map(field string, m map[string]string) {
+	for k, v := range m {
+		e.String(field+"."+k, &v)
+		m[k] = v
+	}
+}
+
+// Location expands the region, zone and campus of l if it is not nil.
+func (e *Expander) Location(field string, l *model.Location) {
+	if l == nil {
+		return
+	}
+	e.String(field+".region", &l.Region)
+	e.String(field+".zone", &l.Zone)
+	e.String(field+".campus", &l.Campus)
+}
+
+// Err returns the error of all problems, or nil if there is no problem.
+func (e *Expander) Err() error {
+	return e.p.Err()
+}
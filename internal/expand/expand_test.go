@@ -0,0 +1,72 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package expand
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestString(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("EXPAND_DIR", dir)
+	t.Setenv("EXPAND_ZONE", "Guangdong")
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "token"), []byte("secret\n"), 0600))
+
+	for in, out := range map[string]string{
+		"":                           "",
+		"plain":                      "plain",
+		"${EXPAND_ZONE}":             "Guangdong",
+		"$EXPAND_ZONE-1":             "Guangdong-1",
+		"${EXPAND_UNSET}":            "",
+		"file://${EXPAND_DIR}/token": "secret",
+		"prefix file://not_expanded": "prefix file://not_expanded",
+	} {
+		v, err := String(in)
+		require.Nil(t, err)
+		assert.Equal(t, out, v, in)
+	}
+	_, err := String("file://${EXPAND_DIR}/not_exist")
+	assert.ErrorContains(t, err, "read file "+filepath.Join(dir, "not_exist")+" err")
+}
+
+func TestExpander(t *testing.T) {
+	t.Setenv("EXPAND_REGION", "China")
+	t.Setenv("EXPAND_VERSION", "v1")
+
+	var e Expander
+	s := "${EXPAND_REGION}"
+	e.String("s", &s)
+	e.String("nil", nil)
+	m := map[string]string{"version": "${EXPAND_VERSION}", "secret": "file://not_exist"}
+	e.Map("metadata", m)
+	l := &model.Location{Region: "${EXPAND_REGION}", Zone: "zone", Campus: "file://not_exist"}
+	e.Location("location", l)
+	e.Location("nil", nil)
+
+	assert.Equal(t, "China", s)
+	assert.Equal(t, map[string]string{"version": "v1", "secret": "file://not_exist"}, m)
+	assert.Equal(t, &model.Location{Region: "China", Zone: "zone", Campus: "file://not_exist"}, l)
+	err := e.Err()
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "2 config problem(s): ")
+	assert.Contains(t, err.Error(), "metadata.secret: read file not_exist err")
+	assert.Contains(t, err.Error(), "location.campus: read file not_exist err")
+	assert.Nil(t, (&Expander{}).Err())
+}
@@ -0,0 +1,188 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+// Package location detects the location of the instance by the providers configured,
+// which is used by both the nearby routing and the registration.
+package location
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"trpc.group/trpc-go/trpc-go/log"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/validate"
+
+	"github.com/polarismesh/polaris-go/pkg/model"
+)
+
+// The types of the location providers.
+const (
+	// TypeEnv reads the location from the environment variables.
+	TypeEnv = "env"
+	// TypeK8sLabels reads the location from the Kubernetes labels in a mounted file, one key="value" per line.
+	TypeK8sLabels = "k8s_labels"
+	// TypeFile reads the location from a local JSON file.
+	TypeFile = "file"
+	// TypeStatic is the location configured.
+	TypeStatic = "static"
+)
+
+// SourceInstanceLocation is the source of the location configured by instance_location.
+const SourceInstanceLocation = "instance_location"
+
+// ProviderConfig is the config of a location provider.
+type ProviderConfig struct {
+	// Type is one of env, k8s_labels, file and static.
+	Type string `yaml:"type"`
+	// Path is the file of k8s_labels and file.
+	Path string `yaml:"path"`
+	// Region, Zone and Campus are the names of the environment variables of env,
+	// default as POLARIS_REGION, POLARIS_ZONE and POLARIS_CAMPUS, the label keys of k8s_labels,
+	// default as topology.kubernetes.io/region and topology.kubernetes.io/zone without campus,
+	// the keys of the JSON object of file, default as region, zone and campus, or the values of static.
+	Region string `yaml:"region"`
+	Zone   string `yaml:"zone"`
+	Campus string `yaml:"campus"`
+}
+
+// Validate checks the configs of the providers.
+func Validate(p *validate.Problems, field string, providers []ProviderConfig) {
+	for i, c := range providers {
+		f := fmt.Sprintf("%s.%d", field, i)
+		p.OneOf(f+".type", c.Type, TypeEnv, TypeK8sLabels, TypeFile, TypeStatic)
+		switch c.Type {
+		case TypeK8sLabels, TypeFile:
+			if c.Path == "" {
+				p.Addf("%s.path must not be empty for %s", f, c.Type)
+			}
+		case TypeStatic:
+			if c.Region == "" {
+				p.Addf("%s.region must not be empty for %s", f, c.Type)
+			}
+		}
+	}
+}
+
+// Resolve returns the location detected by the first provider which finds the region,
+// or the instance location configured if no provider finds it. The source of the location is logged.
+func Resolve(name string, instanceLocation *model.Location, providers []ProviderConfig) *model.Location {
+	loc, source := detect(providers)
+	if loc == nil && instanceLocation != nil && *instanceLocation != (model.Location{}) {
+		loc, source = instanceLocation, SourceInstanceLocation
+	}
+	if loc == nil {
+		if len(providers) > 0 {
+			log.Warnf("[NAMING-POLARISMESH] %s finds no location by the providers", name)
+		}
+		return nil
+	}
+	if loc.Zone == "" || loc.Campus == "" {
+		log.Warnf("[NAMING-POLARISMESH] %s uses the partial location %+v from %s", name, *loc, source)
+	} else {
+		log.Infof("[NAMING-POLARISMESH] %s uses the location %+v from %s", name, *loc, source)
+	}
+	return loc
+}
+
+// detect returns the location of the first provider which finds the region, and the source of it.
+// The providers failed are skipped.
+func detect(providers []ProviderConfig) (*model.Location, string) {
+	for _, p := range providers {
+		loc, err := p.detect()
+		if err != nil {
+			log.Warnf("[NAMING-POLARISMESH] location provider %s is skipped: %v", p.source(), err)
+			continue
+		}
+		if loc.Region != "" {
+			return loc, p.source()
+		}
+	}
+	return nil, ""
+}
+
+func (c *ProviderConfig) source() string {
+	if c.Path == "" {
+		return c.Type
+	}
+	return c.Type + ":" + c.Path
+}
+
+func (c *ProviderConfig) detect() (*model.Location, error) {
+	switch c.Type {
+	case TypeEnv:
+		return &model.Location{
+			Region: os.Getenv(or(c.Region, "POLARIS_REGION")),
+			Zone:   os.Getenv(or(c.Zone, "POLARIS_ZONE")),
+			Campus: os.Getenv(or(c.Campus, "POLARIS_CAMPUS")),
+		}, nil
+	case TypeK8sLabels:
+		labels, err := readLabels(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		return &model.Location{
+			Region: labels[or(c.Region, "topology.kubernetes.io/region")],
+			Zone:   labels[or(c.Zone, "topology.kubernetes.io/zone")],
+			Campus: labels[c.Campus],
+		}, nil
+	case TypeFile:
+		b, err := os.ReadFile(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		var values map[string]string
+		if err := json.Unmarshal(b, &values); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return &model.Location{
+			Region: values[or(c.Region, "region")],
+			Zone:   values[or(c.Zone, "zone")],
+			Campus: values[or(c.Campus, "campus")],
+		}, nil
+	case TypeStatic:
+		return &model.Location{Region: c.Region, Zone: c.Zone, Campus: c.Campus}, nil
+	default:
+		return nil, errors.New("unknown type")
+	}
+}
+
+// readLabels reads the labels in the format of the Kubernetes downward API, one key="value" per line.
+func readLabels(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[string]string)
+	for _, line := range strings.Split(string(b), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		if v, err := strconv.Unquote(value); err == nil {
+			value = v
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+func or(v, defaultValue string) string {
+	if v == "" {
+		return defaultValue
+	}
+	return v
+}
@@ -0,0 +1,91 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package location
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/validate"
+
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	labels := filepath.Join(dir, "labels")
+	require.Nil(t, os.WriteFile(labels, []byte(`app="server"
+topology.kubernetes.io/region="China"
+topology.kubernetes.io/zone="Guangdong"
+campus=Shenzhen
+`), 0600))
+	file := filepath.Join(dir, "location.json")
+	require.Nil(t, os.WriteFile(file, []byte(`{"region": "China", "zone": "Beijing", "idc": "Haidian"}`), 0600))
+	invalid := filepath.Join(dir, "invalid.json")
+	require.Nil(t, os.WriteFile(invalid, []byte(`region: China`), 0600))
+	t.Setenv("POLARIS_REGION", "")
+	t.Setenv("NODE_REGION", "China")
+	t.Setenv("NODE_ZONE", "Shanghai")
+
+	static := ProviderConfig{Type: TypeStatic, Region: "China", Zone: "Guangdong", Campus: "Shenzhen"}
+	configured := &model.Location{Region: "Configured"}
+	for _, tt := range []struct {
+		name      string
+		providers []ProviderConfig
+		want      *model.Location
+	}{
+		{"no providers", nil, configured},
+		{"env not set", []ProviderConfig{{Type: TypeEnv}}, configured},
+		{"env", []ProviderConfig{{Type: TypeEnv, Region: "NODE_REGION", Zone: "NODE_ZONE"}, static},
+			&model.Location{Region: "China", Zone: "Shanghai"}},
+		{"k8s labels", []ProviderConfig{{Type: TypeK8sLabels, Path: labels, Campus: "campus"}},
+			&model.Location{Region: "China", Zone: "Guangdong", Campus: "Shenzhen"}},
+		{"file", []ProviderConfig{{Type: TypeFile, Path: file, Campus: "idc"}},
+			&model.Location{Region: "China", Zone: "Beijing", Campus: "Haidian"}},
+		{"failed ones skipped", []ProviderConfig{
+			{Type: TypeFile, Path: filepath.Join(dir, "not_exist")},
+			{Type: TypeFile, Path: invalid},
+			{Type: TypeK8sLabels, Path: filepath.Join(dir, "not_exist")},
+			static,
+		}, &model.Location{Region: "China", Zone: "Guangdong", Campus: "Shenzhen"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Resolve("test", configured, tt.providers))
+		})
+	}
+	assert.Nil(t, Resolve("test", nil, []ProviderConfig{{Type: TypeEnv}}))
+	assert.Nil(t, Resolve("test", &model.Location{}, nil))
+}
+
+func TestValidate(t *testing.T) {
+	var p validate.Problems
+	Validate(&p, "location_providers", []ProviderConfig{
+		{Type: TypeEnv},
+		{Type: "cloud"},
+		{Type: TypeFile},
+		{Type: TypeK8sLabels},
+		{Type: TypeStatic, Zone: "Guangdong"},
+	})
+	err := p.Err()
+	require.NotNil(t, err)
+	assert.Equal(t, []string{
+		`location_providers.1.type must be one of "env", "k8s_labels", "file", "static", got "cloud"`,
+		"location_providers.2.path must not be empty for file",
+		"location_providers.3.path must not be empty for k8s_labels",
+		"location_providers.4.region must not be empty for static",
+	}, err.(*validate.Error).Problems)
+}
@@ -15,7 +15,10 @@
 package metrics
 
 import (
+	"fmt"
 	"strconv"
+	"sync"
+	"time"
 
 	"trpc.group/trpc-go/trpc-go/metrics"
 
@@ -29,8 +32,138 @@ const (
 	polarisServiceNamespaceKey = "polaris_namespace"
 	polarisServiceHostKey      = "polaris_host"
 	polarisServicePortKey      = "polaris_port"
+	polarisFailoverKindKey     = "polaris_failover_kind"
+	polarisFailoverFromKey     = "polaris_failover_from"
+	polarisFailoverToKey       = "polaris_failover_to"
+	polarisAddressKey          = "polaris_address"
+	polarisMethodKey           = "polaris_method"
+	polarisStateFromKey        = "polaris_state_from"
+	polarisStateToKey          = "polaris_state_to"
+	polarisResultKey           = "polaris_result"
+	polarisEnvKey              = "polaris_env"
+	polarisSetKey              = "polaris_set"
+	polarisLoadBalancerKey     = "polaris_load_balancer"
+	polarisOperationKey        = "polaris_operation"
+	polarisSideKey             = "polaris_side"
+	polarisQuotaSourceKey      = "polaris_quota_source"
 )
 
+// Kinds of failover.
+const (
+	// FailoverEnv means failing over to another environment.
+	FailoverEnv = "env"
+	// FailoverSet means failing over to another set.
+	FailoverSet = "set"
+)
+
+// Results of the operations.
+const (
+	// ResultSuccess means the operation succeeds.
+	ResultSuccess = "success"
+	// ResultFail means the operation fails.
+	ResultFail = "fail"
+	// ResultPassed means the request passes the rate limiter.
+	ResultPassed = "passed"
+	// ResultLimited means the request is limited by the rate limiter.
+	ResultLimited = "limited"
+)
+
+// Sources of the quota of the rate limiters.
+const (
+	// QuotaSourcePolaris means the quota is allocated by polaris.
+	QuotaSourcePolaris = "polaris"
+	// QuotaSourceFallback means the quota is allocated by the local fallback limiter.
+	QuotaSourceFallback = "fallback"
+)
+
+// Operations of the registry.
+const (
+	// OperationRegister is the registration of the instance.
+	OperationRegister = "register"
+	// OperationDeregister is the deregistration of the instance.
+	OperationDeregister = "deregister"
+	// OperationHeartbeat is the heartbeat of the instance.
+	OperationHeartbeat = "heartbeat"
+)
+
+// Families of the metrics, each of which can be disabled by Configure.
+const (
+	// FamilySelect is the latency and the errors of Selector.Select.
+	FamilySelect = "select"
+	// FamilyRoute is the empty results of the service router.
+	FamilyRoute = "route"
+	// FamilyFailover is the env and set failovers and fallbacks.
+	FamilyFailover = "failover"
+	// FamilyLoadBalance is the distribution of the instances chosen by the load balancers.
+	FamilyLoadBalance = "loadbalance"
+	// FamilyCircuitBreaker is the reports, the state changes, the ejections and the probes of the circuit breakers.
+	FamilyCircuitBreaker = "circuitbreaker"
+	// FamilyRegistry is the registration and the heartbeats.
+	FamilyRegistry = "registry"
+	// FamilyRateLimit is the results of the rate limiting filters.
+	FamilyRateLimit = "ratelimit"
+)
+
+var families = struct {
+	mu       sync.RWMutex
+	disabled map[string]bool
+}{disabled: make(map[string]bool)}
+
+// Configure enables or disables the metric families by name, the omitted families keep their current states.
+// All families are enabled by default.
+func Configure(enables map[string]bool) error {
+	if err := Validate(enables); err != nil {
+		return err
+	}
+	families.mu.Lock()
+	defer families.mu.Unlock()
+	for family, enable := range enables {
+		families.disabled[family] = !enable
+	}
+	return nil
+}
+
+// Validate checks the names of the metric families.
+func Validate(enables map[string]bool) error {
+	for family := range enables {
+		switch family {
+		case FamilySelect, FamilyRoute, FamilyFailover, FamilyLoadBalance, FamilyCircuitBreaker, FamilyRegistry,
+			FamilyRateLimit:
+		default:
+			return fmt.Errorf("unknown metrics family %s", family)
+		}
+	}
+	return nil
+}
+
+// Enabled reports whether the metric family is enabled.
+func Enabled(family string) bool {
+	families.mu.RLock()
+	defer families.mu.RUnlock()
+	return !families.disabled[family]
+}
+
+// report reports the metrics of the family if it is enabled, desc describes the metrics in the error log.
+func report(family, desc string, dims []*metrics.Dimension, indices []*metrics.Metrics) {
+	if !Enabled(family) {
+		return
+	}
+	if err := metrics.ReportMultiDimensionMetricsX(polarisMetricsKey, dims, indices); err != nil {
+		plog.GetBaseLogger().Errorf("%s metrics report err: %v\n", desc, err)
+	}
+}
+
+func result(err error) string {
+	if err != nil {
+		return ResultFail
+	}
+	return ResultSuccess
+}
+
+func milliseconds(cost time.Duration) float64 {
+	return float64(cost) / float64(time.Millisecond)
+}
+
 // ReportHeartBeatFail report service heartbeat fails
 func ReportHeartBeatFail(req *api.InstanceHeartbeatRequest) {
 	dims := []*metrics.Dimension{
@@ -54,8 +187,302 @@ func ReportHeartBeatFail(req *api.InstanceHeartbeatRequest) {
 	indices := []*metrics.Metrics{
 		metrics.NewMetrics("trpc.PolarisHeartBeatFail", float64(1), metrics.PolicySUM),
 	}
-	err := metrics.ReportMultiDimensionMetricsX(polarisMetricsKey, dims, indices)
-	if err != nil {
-		plog.GetBaseLogger().Errorf("heartbeat metrics report err: %v\n", err)
+	report(FamilyRegistry, "heartbeat", dims, indices)
+}
+
+// ReportRegistry reports an operation of the registry, which is one of register, deregister and heartbeat.
+func ReportRegistry(namespace, service, operation string, cost time.Duration, err error) {
+	dims := []*metrics.Dimension{
+		{
+			Name:  polarisServiceKey,
+			Value: service,
+		},
+		{
+			Name:  polarisServiceNamespaceKey,
+			Value: namespace,
+		},
+		{
+			Name:  polarisOperationKey,
+			Value: operation,
+		},
+		{
+			Name:  polarisResultKey,
+			Value: result(err),
+		},
+	}
+	indices := []*metrics.Metrics{
+		metrics.NewMetrics("trpc.PolarisRegistry", float64(1), metrics.PolicySUM),
+		metrics.NewMetrics("trpc.PolarisRegistryCost", milliseconds(cost), metrics.PolicyAVG),
+	}
+	report(FamilyRegistry, "registry", dims, indices)
+}
+
+// ReportSelect reports a selection of the callee service by the selector.
+func ReportSelect(namespace, service string, cost time.Duration, err error) {
+	dims := []*metrics.Dimension{
+		{
+			Name:  polarisServiceKey,
+			Value: service,
+		},
+		{
+			Name:  polarisServiceNamespaceKey,
+			Value: namespace,
+		},
+		{
+			Name:  polarisResultKey,
+			Value: result(err),
+		},
+	}
+	indices := []*metrics.Metrics{
+		metrics.NewMetrics("trpc.PolarisSelect", float64(1), metrics.PolicySUM),
+		metrics.NewMetrics("trpc.PolarisSelectCost", milliseconds(cost), metrics.PolicyAVG),
+	}
+	report(FamilySelect, "select", dims, indices)
+}
+
+// ReportRouteEmpty reports an empty result of routing the callee service to the env and the set.
+func ReportRouteEmpty(namespace, service, env, set string) {
+	dims := []*metrics.Dimension{
+		{
+			Name:  polarisServiceKey,
+			Value: service,
+		},
+		{
+			Name:  polarisServiceNamespaceKey,
+			Value: namespace,
+		},
+		{
+			Name:  polarisEnvKey,
+			Value: env,
+		},
+		{
+			Name:  polarisSetKey,
+			Value: set,
+		},
+	}
+	indices := []*metrics.Metrics{
+		metrics.NewMetrics("trpc.PolarisRouteEmpty", float64(1), metrics.PolicySUM),
+	}
+	report(FamilyRoute, "route empty", dims, indices)
+}
+
+// ReportFailover reports a failover of the callee service from one environment or set to another.
+func ReportFailover(namespace, service, kind, from, to string) {
+	dims := []*metrics.Dimension{
+		{
+			Name:  polarisServiceKey,
+			Value: service,
+		},
+		{
+			Name:  polarisServiceNamespaceKey,
+			Value: namespace,
+		},
+		{
+			Name:  polarisFailoverKindKey,
+			Value: kind,
+		},
+		{
+			Name:  polarisFailoverFromKey,
+			Value: from,
+		},
+		{
+			Name:  polarisFailoverToKey,
+			Value: to,
+		},
+	}
+	indices := []*metrics.Metrics{
+		metrics.NewMetrics("trpc.PolarisFailover", float64(1), metrics.PolicySUM),
+	}
+	report(FamilyFailover, "failover", dims, indices)
+}
+
+// ReportLoadBalance reports the instance of the callee service chosen by the load balancer.
+func ReportLoadBalance(namespace, service, loadBalancer, address string) {
+	dims := []*metrics.Dimension{
+		{
+			Name:  polarisServiceKey,
+			Value: service,
+		},
+		{
+			Name:  polarisServiceNamespaceKey,
+			Value: namespace,
+		},
+		{
+			Name:  polarisLoadBalancerKey,
+			Value: loadBalancer,
+		},
+		{
+			Name:  polarisAddressKey,
+			Value: address,
+		},
+	}
+	indices := []*metrics.Metrics{
+		metrics.NewMetrics("trpc.PolarisLoadBalance", float64(1), metrics.PolicySUM),
+	}
+	report(FamilyLoadBalance, "load balance", dims, indices)
+}
+
+// ReportCircuitBreakerResult reports a result of the request to the callee service by the outcome
+// classified for the circuit breakers, such as success, fail, unknown and ignore.
+func ReportCircuitBreakerResult(namespace, service, outcome string) {
+	dims := []*metrics.Dimension{
+		{
+			Name:  polarisServiceKey,
+			Value: service,
+		},
+		{
+			Name:  polarisServiceNamespaceKey,
+			Value: namespace,
+		},
+		{
+			Name:  polarisResultKey,
+			Value: outcome,
+		},
+	}
+	indices := []*metrics.Metrics{
+		metrics.NewMetrics("trpc.PolarisCircuitBreakerReport", float64(1), metrics.PolicySUM),
+	}
+	report(FamilyCircuitBreaker, "circuit breaker report", dims, indices)
+}
+
+// ReportCircuitBreakerStateChange reports a state change of the circuit breaker of the callee service.
+// The address is empty for the method level breakers, and the method is empty for the instance level breakers.
+func ReportCircuitBreakerStateChange(namespace, service, address, method, from, to string) {
+	dims := []*metrics.Dimension{
+		{
+			Name:  polarisServiceKey,
+			Value: service,
+		},
+		{
+			Name:  polarisServiceNamespaceKey,
+			Value: namespace,
+		},
+		{
+			Name:  polarisAddressKey,
+			Value: address,
+		},
+		{
+			Name:  polarisMethodKey,
+			Value: method,
+		},
+		{
+			Name:  polarisStateFromKey,
+			Value: from,
+		},
+		{
+			Name:  polarisStateToKey,
+			Value: to,
+		},
+	}
+	indices := []*metrics.Metrics{
+		metrics.NewMetrics("trpc.PolarisCircuitBreakerStateChange", float64(1), metrics.PolicySUM),
+	}
+	report(FamilyCircuitBreaker, "circuit breaker state change", dims, indices)
+}
+
+// ReportCircuitBreakerOpenInstances reports the number and the ratio of the open instances of the callee service.
+func ReportCircuitBreakerOpenInstances(namespace, service string, open, total int) {
+	dims := []*metrics.Dimension{
+		{
+			Name:  polarisServiceKey,
+			Value: service,
+		},
+		{
+			Name:  polarisServiceNamespaceKey,
+			Value: namespace,
+		},
+	}
+	var ratio float64
+	if total > 0 {
+		ratio = float64(open) / float64(total)
+	}
+	indices := []*metrics.Metrics{
+		metrics.NewMetrics("trpc.PolarisCircuitBreakerOpenInstances", float64(open), metrics.PolicySET),
+		metrics.NewMetrics("trpc.PolarisCircuitBreakerOpenRatio", ratio, metrics.PolicySET),
+	}
+	report(FamilyCircuitBreaker, "circuit breaker open instances", dims, indices)
+}
+
+// ReportOutlierEjection reports an ejection of the instance of the callee service by the outlier detection.
+func ReportOutlierEjection(namespace, service, address string) {
+	dims := []*metrics.Dimension{
+		{
+			Name:  polarisServiceKey,
+			Value: service,
+		},
+		{
+			Name:  polarisServiceNamespaceKey,
+			Value: namespace,
+		},
+		{
+			Name:  polarisAddressKey,
+			Value: address,
+		},
+	}
+	indices := []*metrics.Metrics{
+		metrics.NewMetrics("trpc.PolarisOutlierEjection", float64(1), metrics.PolicySUM),
+	}
+	report(FamilyCircuitBreaker, "outlier ejection", dims, indices)
+}
+
+// ReportProbe reports an active health probe of the instance of the callee service.
+func ReportProbe(namespace, service, address string, cost time.Duration, err error) {
+	dims := []*metrics.Dimension{
+		{
+			Name:  polarisServiceKey,
+			Value: service,
+		},
+		{
+			Name:  polarisServiceNamespaceKey,
+			Value: namespace,
+		},
+		{
+			Name:  polarisAddressKey,
+			Value: address,
+		},
+		{
+			Name:  polarisResultKey,
+			Value: result(err),
+		},
+	}
+	indices := []*metrics.Metrics{
+		metrics.NewMetrics("trpc.PolarisCircuitBreakerProbe", float64(1), metrics.PolicySUM),
+		metrics.NewMetrics("trpc.PolarisCircuitBreakerProbeCost", milliseconds(cost), metrics.PolicyAVG),
+	}
+	report(FamilyCircuitBreaker, "circuit breaker probe", dims, indices)
+}
+
+// ReportRateLimit reports a result of the rate limiting of the method of the service on the side, server or client.
+// The source is where the quota is allocated, polaris or the local fallback limiter.
+func ReportRateLimit(namespace, service, method, side, source, result string) {
+	dims := []*metrics.Dimension{
+		{
+			Name:  polarisServiceKey,
+			Value: service,
+		},
+		{
+			Name:  polarisServiceNamespaceKey,
+			Value: namespace,
+		},
+		{
+			Name:  polarisMethodKey,
+			Value: method,
+		},
+		{
+			Name:  polarisSideKey,
+			Value: side,
+		},
+		{
+			Name:  polarisQuotaSourceKey,
+			Value: source,
+		},
+		{
+			Name:  polarisResultKey,
+			Value: result,
+		},
+	}
+	indices := []*metrics.Metrics{
+		metrics.NewMetrics("trpc.PolarisRateLimit", float64(1), metrics.PolicySUM),
 	}
+	report(FamilyRateLimit, "rate limit", dims, indices)
 }
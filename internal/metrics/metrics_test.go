@@ -0,0 +1,93 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package metrics
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type captureSink struct {
+	mu      sync.Mutex
+	records []metrics.Record
+}
+
+func (s *captureSink) Name() string {
+	return "polaris_capture"
+}
+
+func (s *captureSink) Report(rec metrics.Record, _ ...metrics.Option) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, rec)
+	return nil
+}
+
+func (s *captureSink) take() []metrics.Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := s.records
+	s.records = nil
+	return records
+}
+
+func TestConfigure(t *testing.T) {
+	sink := &captureSink{}
+	metrics.RegisterMetricsSink(sink)
+
+	assert.NotNil(t, Configure(map[string]bool{"unknown": false}))
+	assert.True(t, Enabled(FamilySelect), "unchanged by invalid config")
+
+	ReportSelect("Test", "configure", time.Millisecond, errors.New("select fail"))
+	records := sink.take()
+	require.Len(t, records, 1)
+	assert.Equal(t, polarisMetricsKey, records[0].GetName())
+	assert.Contains(t, records[0].GetDimensions(), &metrics.Dimension{Name: polarisResultKey, Value: ResultFail})
+	require.Len(t, records[0].GetMetrics(), 2)
+	assert.Equal(t, "trpc.PolarisSelect", records[0].GetMetrics()[0].Name())
+	assert.Equal(t, float64(1), records[0].GetMetrics()[1].Value())
+
+	require.Nil(t, Configure(map[string]bool{FamilySelect: false, FamilyRoute: true}))
+	defer func() {
+		require.Nil(t, Configure(map[string]bool{FamilySelect: true}))
+	}()
+	assert.False(t, Enabled(FamilySelect))
+	assert.True(t, Enabled(FamilyRoute))
+	ReportSelect("Test", "configure", time.Millisecond, nil)
+	assert.Empty(t, sink.take(), "disabled family is not reported")
+	ReportRouteEmpty("Test", "configure", "test", "")
+	assert.Len(t, sink.take(), 1)
+
+	ReportRateLimit("Test", "configure", "/method", "server", QuotaSourcePolaris, ResultLimited)
+	records = sink.take()
+	require.Len(t, records, 1)
+	assert.Contains(t, records[0].GetDimensions(), &metrics.Dimension{Name: polarisResultKey, Value: ResultLimited})
+	assert.Equal(t, "trpc.PolarisRateLimit", records[0].GetMetrics()[0].Name())
+
+	ReportOutlierEjection("Test", "configure", "127.0.0.1:8080")
+	records = sink.take()
+	require.Len(t, records, 1)
+	assert.Contains(t, records[0].GetDimensions(), &metrics.Dimension{Name: polarisServiceNamespaceKey, Value: "Test"})
+	assert.Equal(t, "trpc.PolarisOutlierEjection", records[0].GetMetrics()[0].Name())
+
+	require.Nil(t, Configure(nil))
+	assert.False(t, Enabled(FamilySelect), "omitted family keeps its state")
+}
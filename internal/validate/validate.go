@@ -0,0 +1,194 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+// Package validate collects the problems of the configs, so that all of them are reported at once.
+package validate
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problems collects the problems of a config.
+type Problems struct {
+	problems []string
+}
+
+// Addf adds a problem.
+func (p *Problems) Addf(format string, args ...interface{}) {
+	p.problems = append(p.problems, fmt.Sprintf(format, args...))
+}
+
+// Add adds the problem of err with the field prefixed if err is not nil.
+func (p *Problems) Add(field string, err error) {
+	if err != nil {
+		p.Addf("%s: %v", field, err)
+	}
+}
+
+// Err returns the error of all problems, or nil if there is no problem.
+func (p *Problems) Err() error {
+	if len(p.problems) == 0 {
+		return nil
+	}
+	return &Error{Problems: p.problems}
+}
+
+// NonNegative checks the int field is not negative.
+func (p *Problems) NonNegative(field string, v int) {
+	if v < 0 {
+		p.Addf("%s must not be negative, got %d", field, v)
+	}
+}
+
+// NonNegativeDuration checks the duration field is not negative if it is set.
+func (p *Problems) NonNegativeDuration(field string, d *time.Duration) {
+	if d != nil && *d < 0 {
+		p.Addf("%s must not be negative, got %v", field, *d)
+	}
+}
+
+// Range checks the float field is in [min, max].
+func (p *Problems) Range(field string, v, min, max float64) {
+	if v < min || v > max {
+		p.Addf("%s must be in [%v, %v], got %v", field, min, max, v)
+	}
+}
+
+// OneOf checks the string field is one of the values.
+func (p *Problems) OneOf(field, v string, values ...string) {
+	for _, value := range values {
+		if v == value {
+			return
+		}
+	}
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = strconv.Quote(value)
+	}
+	p.Addf("%s must be one of %s, got %q", field, strings.Join(quoted, ", "), v)
+}
+
+// AddressList checks the comma separated addresses are all in the format of host:port.
+func (p *Problems) AddressList(field, addresses string) {
+	if addresses == "" {
+		return
+	}
+	for _, address := range strings.Split(addresses, ",") {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil || host == "" {
+			p.Addf("%s must be host:port separated by commas, got %q", field, address)
+			continue
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+			p.Addf("%s has an invalid port in %q", field, address)
+		}
+	}
+}
+
+// Error is the error of the problems of a config.
+type Error struct {
+	Problems []string
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d config problem(s): %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// UnknownKeys returns the keys in node which are not the yaml fields of the struct pointed by v, recursively.
+// The values of the types with their own yaml unmarshaler are not checked.
+func UnknownKeys(node *yaml.Node, v interface{}) []string {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	return unknownKeys(node, reflect.TypeOf(v).Elem(), "")
+}
+
+func unknownKeys(node *yaml.Node, t reflect.Type, prefix string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var keys []string
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := make(map[string]reflect.Type)
+		collectFields(t, fields)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			ft, ok := fields[key.Value]
+			if !ok {
+				keys = append(keys, fmt.Sprintf("%s%s (line %d)", prefix, key.Value, key.Line))
+				continue
+			}
+			if checkable(ft) {
+				keys = append(keys, unknownKeys(value, ft, prefix+key.Value+".")...)
+			}
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode && checkable(t.Elem()):
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keys = append(keys, unknownKeys(node.Content[i+1], t.Elem(), prefix+node.Content[i].Value+".")...)
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode && checkable(t.Elem()):
+		for i, value := range node.Content {
+			keys = append(keys, unknownKeys(value, t.Elem(), fmt.Sprintf("%s%d.", prefix, i))...)
+		}
+	}
+	return keys
+}
+
+// collectFields collects the yaml field names of the struct type, including the inlined ones.
+func collectFields(t reflect.Type, fields map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("yaml"), ",")
+		if tag[0] == "-" {
+			continue
+		}
+		if len(tag) > 1 && tag[1] == "inline" {
+			collectFields(f.Type, fields)
+			continue
+		}
+		name := tag[0]
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+}
+
+// checkable reports whether the keys of the values of type t can be checked.
+func checkable(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(yaml.Node{}) || reflect.PtrTo(t).Implements(unmarshalerType) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package validate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestProblems(t *testing.T) {
+	var p Problems
+	require.Nil(t, p.Err())
+
+	d := -time.Second
+	p.NonNegative("a", -1)
+	p.NonNegative("a", 0)
+	p.NonNegativeDuration("b", &d)
+	p.NonNegativeDuration("b", nil)
+	p.Range("c", 2, 0, 1)
+	p.Range("c", 0.5, 0, 1)
+	p.OneOf("d", "x", "y", "z")
+	p.OneOf("d", "y", "y", "z")
+	p.AddressList("e", "127.0.0.1:8081,127.0.0.1,:80,127.0.0.1:70000")
+	p.AddressList("e", "")
+	p.Add("f", nil)
+	p.Add("f", assert.AnError)
+
+	err := p.Err()
+	require.NotNil(t, err)
+	e, ok := err.(*Error)
+	require.True(t, ok)
+	require.Equal(t, []string{
+		"a must not be negative, got -1",
+		"b must not be negative, got -1s",
+		"c must be in [0, 1], got 2",
+		`d must be one of "y", "z", got "x"`,
+		`e must be host:port separated by commas, got "127.0.0.1"`,
+		`e must be host:port separated by commas, got ":80"`,
+		`e has an invalid port in "127.0.0.1:70000"`,
+		"f: " + assert.AnError.Error(),
+	}, e.Problems)
+	require.Contains(t, err.Error(), "8 config problem(s): a must not be negative")
+}
+
+type inner struct {
+	Name string `yaml:"name"`
+}
+
+type custom struct{}
+
+func (*custom) UnmarshalYAML(*yaml.Node) error { return nil }
+
+type inlined struct {
+	Extra string `yaml:"extra"`
+}
+
+type outer struct {
+	inlined `yaml:",inline"`
+	Inner   inner            `yaml:"inner"`
+	Ptr     *inner           `yaml:"ptr"`
+	Map     map[string]inner `yaml:"map"`
+	List    []inner          `yaml:"list"`
+	Custom  custom           `yaml:"custom"`
+	Node    yaml.Node        `yaml:"node"`
+	Skipped string           `yaml:"-"`
+	Default string
+}
+
+func TestUnknownKeys(t *testing.T) {
+	var node yaml.Node
+	require.Nil(t, yaml.Unmarshal([]byte(`
+extra: a
+default: b
+skipped: c
+inner:
+  name: a
+  nmae: b
+ptr:
+  foo: a
+map:
+  k:
+    name: a
+    bar: b
+list:
+  - name: a
+  - baz: b
+custom:
+  anything: a
+node:
+  anything: a
+`), &node))
+	require.Equal(t, []string{
+		"skipped (line 4)",
+		"inner.nmae (line 7)",
+		"ptr.foo (line 9)",
+		"map.k.bar (line 13)",
+		"list.1.baz (line 16)",
+	}, UnknownKeys(&node, &outer{}))
+}
@@ -18,10 +18,15 @@ import (
 	"fmt"
 	"net"
 	"strconv"
+	"strings"
+	"sync"
 
 	"gopkg.in/yaml.v3"
 	"trpc.group/trpc-go/trpc-go/naming/loadbalance"
 	"trpc.group/trpc-go/trpc-go/naming/registry"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/circuitbreaker"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/metrics"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/tracing"
 
 	"github.com/polarismesh/polaris-go/api"
 	"github.com/polarismesh/polaris-go/pkg/config"
@@ -95,6 +100,7 @@ func New(ctx api.SDKContext, name string) (*WRLoadBalancer, error) {
 	return &WRLoadBalancer{
 		sdkCtx: ctx,
 		lb:     lb,
+		name:   name,
 	}, nil
 }
 
@@ -102,6 +108,7 @@ func New(ctx api.SDKContext, name string) (*WRLoadBalancer, error) {
 type WRLoadBalancer struct {
 	sdkCtx api.SDKContext
 	lb     loadbalancer.LoadBalancer
+	name   string
 }
 
 // Select selects a load balancing node.
@@ -111,7 +118,26 @@ func (wr *WRLoadBalancer) Select(serviceName string,
 	for _, o := range opt {
 		o(opts)
 	}
+	_, span := tracing.Start(opts.Ctx, tracing.SpanLoadBalanceSelect)
+	span.SetAttribute(tracing.AttrService, serviceName)
+	span.SetAttribute(tracing.AttrNamespace, opts.Namespace)
+	node, err := wr.selectNode(serviceName, list, opts)
+	if err == nil {
+		span.SetAttribute(tracing.AttrAddress, node.Address)
+		if node.SetName != "" {
+			span.SetAttribute(tracing.AttrSet, node.SetName)
+		}
+		if node.EnvKey != "" {
+			span.SetAttribute(tracing.AttrEnv, node.EnvKey)
+		}
+	}
+	span.End(err)
+	return node, err
+}
 
+// selectNode selects a node from the routed list by the options.
+func (wr *WRLoadBalancer) selectNode(serviceName string,
+	list []*registry.Node, opts *loadbalance.Options) (*registry.Node, error) {
 	if len(list) == 0 {
 		return nil, loadbalance.ErrNoServerAvailable
 	}
@@ -119,13 +145,10 @@ func (wr *WRLoadBalancer) Select(serviceName string,
 	serviceInstances := list[0].Metadata["serviceInstances"].(model.ServiceInstances)
 	envKey := list[0].EnvKey
 
-	criteria := &loadbalancer.Criteria{
-		Cluster: cluster,
-		HashKey: []byte(opts.Key),
-	}
-	inst, err := loadbalancer.ChooseInstance(wr.sdkCtx.GetValueContext(), wr.lb, criteria, serviceInstances)
+	inst, err := wr.choose(opts.Namespace, serviceName, circuitbreaker.CalleeMethod(opts.Ctx), cluster,
+		serviceInstances, []byte(opts.Key))
 	if err != nil {
-		return nil, fmt.Errorf("choose instance err: %s", err.Error())
+		return nil, err
 	}
 	var (
 		setName       string
@@ -146,13 +169,158 @@ func (wr *WRLoadBalancer) Select(serviceName string,
 		Weight:        inst.GetWeight(),
 		EnvKey:        envKey,
 		Metadata: map[string]interface{}{
-			"instance": inst,
+			"instance":  inst,
+			"namespace": opts.Namespace,
 		},
 	}
-
+	circuitbreaker.WithMethod(opts.Ctx, node)
+	metrics.ReportLoadBalance(opts.Namespace, serviceName, wr.name, node.Address)
 	return node, nil
 }
 
+// choose chooses an instance of the cluster for the method by the load balancer.
+// If any instance of the service is forced by circuitbreaker.Force, ejected by the outlier detection or held by
+// the prober, or the method is broken by the method level breakers, the load balancer chooses from the instances
+// filtered by circuitbreaker.FilterInstances, so that the hash based load balancers only remap the keys of
+// the excluded instances.
+func (wr *WRLoadBalancer) choose(
+	namespace string,
+	serviceName string,
+	method string,
+	cluster *model.Cluster,
+	serviceInstances model.ServiceInstances,
+	hashKey []byte,
+) (model.Instance, error) {
+	if !circuitbreaker.HasExcluded(namespace, serviceName, method) {
+		return wr.chooseCluster(cluster, serviceInstances, hashKey)
+	}
+	inst, err := wr.chooseFiltered(namespace, serviceName, method, cluster, serviceInstances, hashKey)
+	if err != nil {
+		return nil, err
+	}
+	circuitbreaker.Selected(namespace, serviceName, method, inst)
+	return inst, nil
+}
+
+// chooseFiltered chooses an instance from the instances of the cluster filtered by circuitbreaker.FilterInstances.
+func (wr *WRLoadBalancer) chooseFiltered(
+	namespace string,
+	serviceName string,
+	method string,
+	cluster *model.Cluster,
+	serviceInstances model.ServiceInstances,
+	hashKey []byte,
+) (model.Instance, error) {
+	// The selectable instances without the isolated and the unhealthy ones, including the broken ones.
+	instances := cluster.GetClusterValue().GetInstancesSet(true, true).GetRealInstances()
+	filtered, ok := circuitbreaker.FilterInstances(namespace, serviceName, method, instances)
+	if !ok {
+		inst := circuitbreaker.SelectInstance(namespace, serviceName, filtered, hashKey)
+		if inst == nil {
+			return nil, loadbalance.ErrNoServerAvailable
+		}
+		return inst, nil
+	}
+	if len(filtered) == 0 {
+		return nil, loadbalance.ErrNoServerAvailable
+	}
+	if len(filtered) < len(instances) {
+		return chooseInstance(wr.sdkCtx.GetValueContext(), wr.lb, namespace, serviceName, filtered, hashKey)
+	}
+	return wr.chooseCluster(cluster, serviceInstances, hashKey)
+}
+
+// chooseCluster chooses an instance of the cluster by the load balancer.
+func (wr *WRLoadBalancer) chooseCluster(
+	cluster *model.Cluster,
+	serviceInstances model.ServiceInstances,
+	hashKey []byte,
+) (model.Instance, error) {
+	criteria := &loadbalancer.Criteria{
+		Cluster: cluster,
+		HashKey: hashKey,
+	}
+	inst, err := loadbalancer.ChooseInstance(wr.sdkCtx.GetValueContext(), wr.lb, criteria, serviceInstances)
+	if err != nil {
+		return nil, fmt.Errorf("choose instance err: %s", err.Error())
+	}
+	return inst, nil
+}
+
+// ChooseInstance chooses an instance from the instances of the service of the namespace
+// by the load balancer plugin of polaris named name, such as the instances filtered by
+// circuitbreaker.FilterInstances.
+func ChooseInstance(
+	sdkCtx api.SDKContext,
+	name string,
+	namespace string,
+	serviceName string,
+	instances []model.Instance,
+	hashKey []byte,
+) (model.Instance, error) {
+	lb, err := sdkCtx.GetPlugins().GetPlugin(common.TypeLoadBalancer, name)
+	if err != nil {
+		return nil, fmt.Errorf("api sdk ctx get plugin for %s err: %w", name, err)
+	}
+	return chooseInstance(sdkCtx.GetValueContext(), lb.(loadbalancer.LoadBalancer), namespace, serviceName,
+		instances, hashKey)
+}
+
+func chooseInstance(
+	valueCtx model.ValueContext,
+	lb loadbalancer.LoadBalancer,
+	namespace string,
+	serviceName string,
+	instances []model.Instance,
+	hashKey []byte,
+) (model.Instance, error) {
+	serviceInstances := filteredServiceInstances(namespace, serviceName, instances)
+	criteria := &loadbalancer.Criteria{
+		Cluster: model.NewCluster(serviceInstances.GetServiceClusters(), nil),
+		HashKey: hashKey,
+	}
+	inst, err := loadbalancer.ChooseInstance(valueCtx, lb, criteria, serviceInstances)
+	if err != nil {
+		return nil, fmt.Errorf("choose instance err: %s", err.Error())
+	}
+	return inst, nil
+}
+
+// filtered are the service instances built from the filtered instances by service.
+var filtered = struct {
+	mu sync.Mutex
+	m  map[model.ServiceKey]filteredInstances
+}{m: make(map[model.ServiceKey]filteredInstances)}
+
+type filteredInstances struct {
+	// key identifies the filtered instances by their ids and revisions.
+	key              string
+	serviceInstances model.ServiceInstances
+}
+
+// filteredServiceInstances returns the service instances of the filtered instances of the service.
+// They are reused until the filtered instances change, so that the hash rings built on them are kept.
+func filteredServiceInstances(namespace, serviceName string, instances []model.Instance) model.ServiceInstances {
+	var b strings.Builder
+	for _, inst := range instances {
+		b.WriteString(inst.GetId())
+		b.WriteByte('@')
+		b.WriteString(inst.GetRevision())
+		b.WriteByte(',')
+	}
+	key := b.String()
+	service := model.ServiceKey{Namespace: namespace, Service: serviceName}
+	filtered.mu.Lock()
+	defer filtered.mu.Unlock()
+	if f, ok := filtered.m[service]; ok && f.key == key {
+		return f.serviceInstances
+	}
+	serviceInstances := model.NewDefaultServiceInstances(
+		model.ServiceInfo{Namespace: namespace, Service: serviceName}, instances)
+	filtered.m[service] = filteredInstances{key: key, serviceInstances: serviceInstances}
+	return serviceInstances
+}
+
 // AsPluginCfgs parses yaml node to polaris mesh load balance configures.
 func AsPluginCfgs(yamlCfgs map[string]yaml.Node) (map[string]config.BaseConfig, error) {
 	cfgs := make(map[string]config.BaseConfig)
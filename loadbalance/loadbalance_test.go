@@ -14,19 +14,36 @@
 package loadbalance
 
 import (
+	"context"
+	"fmt"
 	"testing"
+	"time"
 
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/errs"
+	tcb "trpc.group/trpc-go/trpc-go/naming/circuitbreaker"
+	"trpc.group/trpc-go/trpc-go/naming/discovery"
+	"trpc.group/trpc-go/trpc-go/naming/loadbalance"
 	"trpc.group/trpc-go/trpc-go/naming/registry"
+	"trpc.group/trpc-go/trpc-go/naming/selector"
+	"trpc.group/trpc-go/trpc-go/naming/servicerouter"
 
+	"trpc.group/trpc-go/trpc-naming-polarismesh/circuitbreaker"
 	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_api"
 	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_loadbalancer"
 	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_model"
 	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_plugin"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/tracing"
 
 	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/polarismesh/polaris-go/pkg/config"
 	"github.com/polarismesh/polaris-go/pkg/model"
+	"github.com/polarismesh/polaris-go/pkg/model/local"
+	"github.com/polarismesh/polaris-go/pkg/model/pb"
 	"github.com/polarismesh/polaris-go/pkg/plugin/common"
+	"github.com/polarismesh/polaris-go/pkg/plugin/loadbalancer"
+	apiservice "github.com/polarismesh/specification/source/go/api/v1/service_manage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v3"
@@ -86,10 +103,219 @@ func TestSelect(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, node.Weight, 100)
 
+	ctx, msg := codec.WithNewMessage(context.Background())
+	msg.WithCalleeMethod("/trpc.app.server.service/Method")
+	node, err = lb.Select("service", list, loadbalance.WithContext(ctx))
+	assert.Nil(t, err)
+	assert.Equal(t, "/trpc.app.server.service/Method", node.Metadata[circuitbreaker.MethodKey])
+
 	_, err = lb.Select("service", nil)
 	assert.NotNil(t, err)
 }
 
+func TestSelectTracing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	inst := mock_model.NewMockInstance(ctrl)
+	inst.EXPECT().GetMetadata().Return(map[string]string{setEnableKey: setEnableValue, setNameKey: "a.b.c"}).AnyTimes()
+	inst.EXPECT().GetWeight().Return(100).AnyTimes()
+	inst.EXPECT().GetHost().Return("127.0.0.1").AnyTimes()
+	inst.EXPECT().GetPort().Return(uint32(8080)).AnyTimes()
+	plugin := mock_loadbalancer.NewMockLoadBalancer(ctrl)
+	plugin.EXPECT().ChooseInstance(gomock.Any(), gomock.Any()).Return(inst, nil).AnyTimes()
+	m := mock_api.NewMockSDKContext(ctrl)
+	m.EXPECT().GetValueContext().Return(mock_model.NewMockValueContext(ctrl)).AnyTimes()
+	clustersMock := mock_model.NewMockServiceClusters(ctrl)
+	clustersMock.EXPECT().GetServiceInstances().Return(mock_model.NewMockServiceInstances(ctrl)).AnyTimes()
+	lb := &WRLoadBalancer{sdkCtx: m, lb: plugin}
+	list := []*registry.Node{{
+		EnvKey: "test",
+		Metadata: map[string]interface{}{
+			"cluster":          model.NewCluster(clustersMock, nil),
+			"serviceInstances": mock_model.NewMockServiceInstances(ctrl),
+		},
+	}}
+
+	recorder := tracing.NewRecorder()
+	tracing.SetTracer(recorder)
+	defer tracing.SetTracer(nil)
+	ctx, parent := tracing.Start(context.Background(), "parent")
+	_, err := lb.Select("traced", list, loadbalance.WithContext(ctx), loadbalance.WithNamespace("Test"))
+	require.Nil(t, err)
+	parent.End(nil)
+	_, err = lb.Select("traced", nil)
+	require.NotNil(t, err)
+
+	spans := recorder.Spans()
+	require.Len(t, spans, 3)
+	assert.Equal(t, tracing.SpanLoadBalanceSelect, spans[0].Name)
+	assert.Equal(t, "parent", spans[0].Parent)
+	assert.Equal(t, map[string]string{
+		tracing.AttrService:   "traced",
+		tracing.AttrNamespace: "Test",
+		tracing.AttrAddress:   "127.0.0.1:8080",
+		tracing.AttrSet:       "a.b.c",
+		tracing.AttrEnv:       "test",
+	}, spans[0].Attributes)
+	assert.NotNil(t, spans[2].Err)
+}
+
+func TestSelectForced(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	resp := &apiservice.DiscoverResponse{
+		Type: apiservice.DiscoverResponse_INSTANCE,
+		Service: &apiservice.Service{
+			Name:      &wrappers.StringValue{Value: "forced"},
+			Namespace: &wrappers.StringValue{Value: "Production"},
+		},
+	}
+	for i := 1; i <= 3; i++ {
+		resp.Instances = append(resp.Instances, &apiservice.Instance{
+			Id:      &wrappers.StringValue{Value: fmt.Sprintf("id-%d", i)},
+			Host:    &wrappers.StringValue{Value: "127.0.0.1"},
+			Port:    &wrappers.UInt32Value{Value: uint32(i)},
+			Weight:  &wrappers.UInt32Value{Value: 100},
+			Healthy: &wrappers.BoolValue{Value: i != 3},
+		})
+	}
+	serviceInstances := pb.NewServiceInstancesInProto(resp, func(string) local.InstanceLocalValue {
+		return local.NewInstanceLocalValue()
+	}, &pb.SvcPluginValues{}, local.NewServiceLocalValue())
+	list := []*registry.Node{{
+		Metadata: map[string]interface{}{
+			"cluster":          model.NewCluster(serviceInstances.GetServiceClusters(), nil),
+			"serviceInstances": serviceInstances,
+		},
+	}}
+	var chosen []model.ServiceInstances
+	plugin := mock_loadbalancer.NewMockLoadBalancer(ctrl)
+	plugin.EXPECT().ChooseInstance(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(criteria *loadbalancer.Criteria, instances model.ServiceInstances) (model.Instance, error) {
+			chosen = append(chosen, instances)
+			return instances.GetInstances()[0], nil
+		}).AnyTimes()
+	m := mock_api.NewMockSDKContext(ctrl)
+	m.EXPECT().GetValueContext().Return(mock_model.NewMockValueContext(ctrl)).AnyTimes()
+	lb := &WRLoadBalancer{sdkCtx: m, lb: plugin}
+
+	require.Nil(t, circuitbreaker.Force("Production", "forced", "127.0.0.1:1", circuitbreaker.ForceIsolate, time.Minute))
+	defer circuitbreaker.Unforce("Production", "forced", "127.0.0.1:1")
+	for i := 0; i < 10; i++ {
+		node, err := lb.Select("forced", list, loadbalance.WithNamespace("Production"), loadbalance.WithKey("key"))
+		require.Nil(t, err)
+		assert.Equal(t, "127.0.0.1:2", node.Address, "isolated and unhealthy instances are not selected")
+	}
+	require.Len(t, chosen, 10, "the load balancer chooses from the filtered instances")
+	require.Len(t, chosen[0].GetInstances(), 1)
+	assert.Equal(t, "id-2", chosen[0].GetInstances()[0].GetId())
+	assert.Same(t, chosen[0], chosen[9], "the filtered instances are reused to keep the hash rings")
+
+	require.Nil(t, circuitbreaker.Force("Production", "forced", "127.0.0.1:2", circuitbreaker.ForceIsolate, time.Minute))
+	defer circuitbreaker.Unforce("Production", "forced", "127.0.0.1:2")
+	_, err := lb.Select("forced", list, loadbalance.WithNamespace("Production"))
+	assert.Equal(t, loadbalance.ErrNoServerAvailable, err)
+	assert.Len(t, chosen, 10)
+}
+
+// instancesDiscovery discovers the service instances with a new cluster every time like polaris discovery,
+// as the cluster is put back to the pool after the load balancer chooses from it.
+type instancesDiscovery struct {
+	model.ServiceInstances
+}
+
+func (d instancesDiscovery) List(string, ...discovery.Option) ([]*registry.Node, error) {
+	return []*registry.Node{{
+		Metadata: map[string]interface{}{
+			"cluster":          model.NewCluster(d.GetServiceClusters(), nil),
+			"serviceInstances": d.ServiceInstances,
+		},
+	}}, nil
+}
+
+// callResultEngine records the call results reported to polaris.
+type callResultEngine struct {
+	model.Engine
+	results []model.RetStatus
+}
+
+func (e *callResultEngine) SyncUpdateServiceCallResult(result *model.ServiceCallResult) error {
+	e.results = append(e.results, result.RetStatus)
+	return nil
+}
+
+func TestSelectBrokenMethod(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	resp := &apiservice.DiscoverResponse{
+		Type: apiservice.DiscoverResponse_INSTANCE,
+		Service: &apiservice.Service{
+			Name:      &wrappers.StringValue{Value: "broken.method"},
+			Namespace: &wrappers.StringValue{Value: "Production"},
+		},
+	}
+	for i := 1; i <= 2; i++ {
+		resp.Instances = append(resp.Instances, &apiservice.Instance{
+			Id:      &wrappers.StringValue{Value: fmt.Sprintf("id-%d", i)},
+			Host:    &wrappers.StringValue{Value: "127.0.0.1"},
+			Port:    &wrappers.UInt32Value{Value: uint32(i)},
+			Weight:  &wrappers.UInt32Value{Value: 100},
+			Healthy: &wrappers.BoolValue{Value: true},
+		})
+	}
+	serviceInstances := pb.NewServiceInstancesInProto(resp, func(string) local.InstanceLocalValue {
+		return local.NewInstanceLocalValue()
+	}, &pb.SvcPluginValues{}, local.NewServiceLocalValue())
+	plugin := mock_loadbalancer.NewMockLoadBalancer(ctrl)
+	plugin.EXPECT().ChooseInstance(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(criteria *loadbalancer.Criteria, instances model.ServiceInstances) (model.Instance, error) {
+			return instances.GetInstances()[0], nil
+		}).AnyTimes()
+	engine := &callResultEngine{}
+	m := mock_api.NewMockSDKContext(ctrl)
+	m.EXPECT().GetValueContext().Return(mock_model.NewMockValueContext(ctrl)).AnyTimes()
+	m.EXPECT().IsDestroyed().Return(false).AnyTimes()
+	m.EXPECT().GetEngine().Return(engine).AnyTimes()
+	require.Nil(t, circuitbreaker.Setup(m, &circuitbreaker.Config{
+		Name:                     "broken.method",
+		Levels:                   map[string]string{"broken.method": circuitbreaker.LevelInstanceMethod},
+		ContinuousErrorThreshold: 2,
+		SleepWindow:              time.Hour,
+	}, false))
+
+	s := &selector.TrpcSelector{}
+	selectMethod := func(method string) (*registry.Node, error) {
+		ctx, msg := codec.WithNewMessage(context.Background())
+		msg.WithCalleeMethod(method)
+		return s.Select("broken.method",
+			selector.WithContext(ctx),
+			selector.WithNamespace("Production"),
+			selector.WithDiscovery(instancesDiscovery{serviceInstances}),
+			selector.WithServiceRouter(&servicerouter.NoopServiceRouter{}),
+			selector.WithLoadBalancer(&WRLoadBalancer{sdkCtx: m, lb: plugin}),
+			selector.WithCircuitBreaker(tcb.Get("broken.method")),
+		)
+	}
+	for i := 0; i < 2; i++ {
+		node, err := selectMethod("/broken")
+		require.Nil(t, err)
+		require.Equal(t, "127.0.0.1:1", node.Address)
+		require.Nil(t, s.Report(node, time.Millisecond, errs.NewFrameError(errs.RetClientNetErr, "")))
+	}
+	assert.Equal(t, []model.RetStatus{model.RetFail, model.RetFail}, engine.results, "failures are reported")
+	for i := 0; i < 10; i++ {
+		node, err := selectMethod("/broken")
+		require.Nil(t, err)
+		assert.Equal(t, "127.0.0.1:2", node.Address, "the broken method is not selected on the instance")
+		node, err = selectMethod("/other")
+		require.Nil(t, err)
+		assert.Equal(t, "127.0.0.1:1", node.Address, "the other methods are not affected")
+	}
+}
+
 func TestAsPluginCfgs(t *testing.T) {
 	newYamlCfgs := func(cfg string) map[string]yaml.Node {
 		yamlCfgs := make(map[string]yaml.Node)
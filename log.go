@@ -0,0 +1,130 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package naming
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"trpc.group/trpc-go/trpc-go/log"
+
+	plog "github.com/polarismesh/polaris-go/pkg/log"
+)
+
+// LogBridgeTRPC forwards the polaris-go SDK logs to the trpc-go log instead of the rotating log files of the SDK.
+const LogBridgeTRPC = "trpc"
+
+// Categories of the polaris-go SDK logs, which are the keys of Logs.Levels.
+const (
+	logCategoryBase       = "base"
+	logCategoryStat       = "stat"
+	logCategoryDetect     = "detect"
+	logCategoryNetwork    = "network"
+	logCategoryStatReport = "stat_report"
+)
+
+// bridgeLogs installs the loggers forwarding the polaris-go SDK logs to the trpc-go log.
+func bridgeLogs(l *Logs) error {
+	levels := map[string]int{
+		logCategoryBase:       getLogLevel(l.Level),
+		logCategoryStat:       getLogLevel(l.Level),
+		logCategoryDetect:     getLogLevel(l.Level),
+		logCategoryNetwork:    getLogLevel(l.Level),
+		logCategoryStatReport: getLogLevel(l.Level),
+	}
+	for category, level := range l.Levels {
+		if _, ok := levels[category]; !ok {
+			return fmt.Errorf("unknown log category %s", category)
+		}
+		levels[category] = getLogLevel(level)
+	}
+	plog.SetBaseLogger(newTRPCLogger(logCategoryBase, levels[logCategoryBase]))
+	plog.SetStatLogger(newTRPCLogger(logCategoryStat, levels[logCategoryStat]))
+	plog.SetDetectLogger(newTRPCLogger(logCategoryDetect, levels[logCategoryDetect]))
+	plog.SetNetworkLogger(newTRPCLogger(logCategoryNetwork, levels[logCategoryNetwork]))
+	plog.SetStatReportLogger(newTRPCLogger(logCategoryStatReport, levels[logCategoryStatReport]))
+	return nil
+}
+
+// trpcLogger is a polaris-go logger forwarding the logs of a category to the trpc-go log.
+// The logs below its level are dropped before being forwarded, and the fatal logs are forwarded as errors
+// since the trpc-go fatal log exits the process.
+type trpcLogger struct {
+	prefix string
+	level  int32
+}
+
+func newTRPCLogger(category string, level int) *trpcLogger {
+	return &trpcLogger{
+		prefix: "[POLARIS-" + strings.ToUpper(category) + "] ",
+		level:  int32(level),
+	}
+}
+
+// Tracef implements plog.Logger.
+func (l *trpcLogger) Tracef(format string, args ...interface{}) {
+	if l.IsLevelEnabled(plog.TraceLog) {
+		log.Tracef(l.prefix+format, args...)
+	}
+}
+
+// Debugf implements plog.Logger.
+func (l *trpcLogger) Debugf(format string, args ...interface{}) {
+	if l.IsLevelEnabled(plog.DebugLog) {
+		log.Debugf(l.prefix+format, args...)
+	}
+}
+
+// Infof implements plog.Logger.
+func (l *trpcLogger) Infof(format string, args ...interface{}) {
+	if l.IsLevelEnabled(plog.InfoLog) {
+		log.Infof(l.prefix+format, args...)
+	}
+}
+
+// Warnf implements plog.Logger.
+func (l *trpcLogger) Warnf(format string, args ...interface{}) {
+	if l.IsLevelEnabled(plog.WarnLog) {
+		log.Warnf(l.prefix+format, args...)
+	}
+}
+
+// Errorf implements plog.Logger.
+func (l *trpcLogger) Errorf(format string, args ...interface{}) {
+	if l.IsLevelEnabled(plog.ErrorLog) {
+		log.Errorf(l.prefix+format, args...)
+	}
+}
+
+// Fatalf implements plog.Logger.
+func (l *trpcLogger) Fatalf(format string, args ...interface{}) {
+	if l.IsLevelEnabled(plog.FatalLog) {
+		log.Errorf(l.prefix+format, args...)
+	}
+}
+
+// IsLevelEnabled implements plog.Logger.
+func (l *trpcLogger) IsLevelEnabled(level int) bool {
+	return level >= int(atomic.LoadInt32(&l.level))
+}
+
+// SetLogLevel implements plog.Logger.
+func (l *trpcLogger) SetLogLevel(level int) error {
+	if level < plog.TraceLog || level > plog.NoneLog {
+		return fmt.Errorf("log level %d out of range", level)
+	}
+	atomic.StoreInt32(&l.level, int32(level))
+	return nil
+}
@@ -0,0 +1,100 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package naming
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"trpc.group/trpc-go/trpc-go/log"
+
+	plog "github.com/polarismesh/polaris-go/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type captureLogger struct {
+	log.Logger
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *captureLogger) capture(level, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, level+" "+fmt.Sprintf(format, args...))
+}
+
+func (l *captureLogger) Debugf(format string, args ...interface{}) {
+	l.capture("debug", format, args...)
+}
+
+func (l *captureLogger) Infof(format string, args ...interface{}) {
+	l.capture("info", format, args...)
+}
+
+func (l *captureLogger) Warnf(format string, args ...interface{}) {
+	l.capture("warn", format, args...)
+}
+
+func (l *captureLogger) Errorf(format string, args ...interface{}) {
+	l.capture("error", format, args...)
+}
+
+func TestBridgeLogs(t *testing.T) {
+	base, stat := plog.GetBaseLogger(), plog.GetStatLogger()
+	detect, network, statReport := plog.GetDetectLogger(), plog.GetNetworkLogger(), plog.GetStatReportLogger()
+	defer func() {
+		plog.SetBaseLogger(base)
+		plog.SetStatLogger(stat)
+		plog.SetDetectLogger(detect)
+		plog.SetNetworkLogger(network)
+		plog.SetStatReportLogger(statReport)
+	}()
+	l := &captureLogger{Logger: log.GetDefaultLogger()}
+	defaultLogger := log.GetDefaultLogger()
+	log.SetLogger(l)
+	defer log.SetLogger(defaultLogger)
+
+	c := &Config{Logs: &Logs{Bridge: "unknown"}}
+	assert.NotNil(t, c.setLog())
+	c = &Config{Logs: &Logs{Bridge: LogBridgeTRPC, Levels: map[string]string{"unknown": "info"}}}
+	assert.NotNil(t, c.setLog())
+
+	c = &Config{Logs: &Logs{
+		Bridge: LogBridgeTRPC,
+		Level:  "info",
+		Levels: map[string]string{logCategoryStat: "warn", logCategoryNetwork: "none"},
+	}}
+	require.Nil(t, c.setLog())
+	plog.GetBaseLogger().Debugf("dropped")
+	plog.GetBaseLogger().Infof("base %d", 1)
+	plog.GetStatLogger().Infof("dropped")
+	plog.GetStatLogger().Warnf("stat")
+	plog.GetDetectLogger().Fatalf("detect")
+	plog.GetNetworkLogger().Errorf("dropped")
+	plog.GetStatReportLogger().Errorf("stat report")
+	assert.Equal(t, []string{
+		"info [POLARIS-BASE] base 1",
+		"warn [POLARIS-STAT] stat",
+		"error [POLARIS-DETECT] detect",
+		"error [POLARIS-STAT_REPORT] stat report",
+	}, l.lines)
+
+	c.Debug = true
+	require.Nil(t, c.setLog())
+	assert.True(t, plog.GetBaseLogger().IsLevelEnabled(plog.DebugLog))
+	assert.NotNil(t, plog.GetBaseLogger().SetLogLevel(plog.NoneLog+1))
+}
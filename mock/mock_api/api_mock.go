@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/polarismesh/polaris-go/api (interfaces: SDKContext,ConsumerAPI,ProviderAPI)
+// Source: github.com/polarismesh/polaris-go/api (interfaces: SDKContext,ConsumerAPI,ProviderAPI,LimitAPI,ConfigFileAPI)
 
 // Package mock_api is a generated GoMock package.
 package mock_api
@@ -422,3 +422,161 @@ func (mr *MockProviderAPIMockRecorder) SDKContext() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SDKContext", reflect.TypeOf((*MockProviderAPI)(nil).SDKContext))
 }
+
+// MockLimitAPI is a mock of LimitAPI interface.
+type MockLimitAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockLimitAPIMockRecorder
+}
+
+// MockLimitAPIMockRecorder is the mock recorder for MockLimitAPI.
+type MockLimitAPIMockRecorder struct {
+	mock *MockLimitAPI
+}
+
+// NewMockLimitAPI creates a new mock instance.
+func NewMockLimitAPI(ctrl *gomock.Controller) *MockLimitAPI {
+	mock := &MockLimitAPI{ctrl: ctrl}
+	mock.recorder = &MockLimitAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLimitAPI) EXPECT() *MockLimitAPIMockRecorder {
+	return m.recorder
+}
+
+// Destroy mocks base method.
+func (m *MockLimitAPI) Destroy() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Destroy")
+}
+
+// Destroy indicates an expected call of Destroy.
+func (mr *MockLimitAPIMockRecorder) Destroy() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Destroy", reflect.TypeOf((*MockLimitAPI)(nil).Destroy))
+}
+
+// GetQuota mocks base method.
+func (m *MockLimitAPI) GetQuota(arg0 api.QuotaRequest) (api.QuotaFuture, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetQuota", arg0)
+	ret0, _ := ret[0].(api.QuotaFuture)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetQuota indicates an expected call of GetQuota.
+func (mr *MockLimitAPIMockRecorder) GetQuota(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQuota", reflect.TypeOf((*MockLimitAPI)(nil).GetQuota), arg0)
+}
+
+// SDKContext mocks base method.
+func (m *MockLimitAPI) SDKContext() api.SDKContext {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SDKContext")
+	ret0, _ := ret[0].(api.SDKContext)
+	return ret0
+}
+
+// SDKContext indicates an expected call of SDKContext.
+func (mr *MockLimitAPIMockRecorder) SDKContext() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SDKContext", reflect.TypeOf((*MockLimitAPI)(nil).SDKContext))
+}
+
+// MockConfigFileAPI is a mock of ConfigFileAPI interface.
+type MockConfigFileAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockConfigFileAPIMockRecorder
+}
+
+// MockConfigFileAPIMockRecorder is the mock recorder for MockConfigFileAPI.
+type MockConfigFileAPIMockRecorder struct {
+	mock *MockConfigFileAPI
+}
+
+// NewMockConfigFileAPI creates a new mock instance.
+func NewMockConfigFileAPI(ctrl *gomock.Controller) *MockConfigFileAPI {
+	mock := &MockConfigFileAPI{ctrl: ctrl}
+	mock.recorder = &MockConfigFileAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockConfigFileAPI) EXPECT() *MockConfigFileAPIMockRecorder {
+	return m.recorder
+}
+
+// CreateConfigFile mocks base method.
+func (m *MockConfigFileAPI) CreateConfigFile(arg0, arg1, arg2, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateConfigFile", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateConfigFile indicates an expected call of CreateConfigFile.
+func (mr *MockConfigFileAPIMockRecorder) CreateConfigFile(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateConfigFile", reflect.TypeOf((*MockConfigFileAPI)(nil).CreateConfigFile), arg0, arg1, arg2, arg3)
+}
+
+// GetConfigFile mocks base method.
+func (m *MockConfigFileAPI) GetConfigFile(arg0, arg1, arg2 string) (model.ConfigFile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetConfigFile", arg0, arg1, arg2)
+	ret0, _ := ret[0].(model.ConfigFile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetConfigFile indicates an expected call of GetConfigFile.
+func (mr *MockConfigFileAPIMockRecorder) GetConfigFile(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConfigFile", reflect.TypeOf((*MockConfigFileAPI)(nil).GetConfigFile), arg0, arg1, arg2)
+}
+
+// PublishConfigFile mocks base method.
+func (m *MockConfigFileAPI) PublishConfigFile(arg0, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PublishConfigFile", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PublishConfigFile indicates an expected call of PublishConfigFile.
+func (mr *MockConfigFileAPIMockRecorder) PublishConfigFile(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishConfigFile", reflect.TypeOf((*MockConfigFileAPI)(nil).PublishConfigFile), arg0, arg1, arg2)
+}
+
+// SDKContext mocks base method.
+func (m *MockConfigFileAPI) SDKContext() api.SDKContext {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SDKContext")
+	ret0, _ := ret[0].(api.SDKContext)
+	return ret0
+}
+
+// SDKContext indicates an expected call of SDKContext.
+func (mr *MockConfigFileAPIMockRecorder) SDKContext() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SDKContext", reflect.TypeOf((*MockConfigFileAPI)(nil).SDKContext))
+}
+
+// UpdateConfigFile mocks base method.
+func (m *MockConfigFileAPI) UpdateConfigFile(arg0, arg1, arg2, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateConfigFile", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateConfigFile indicates an expected call of UpdateConfigFile.
+func (mr *MockConfigFileAPIMockRecorder) UpdateConfigFile(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateConfigFile", reflect.TypeOf((*MockConfigFileAPI)(nil).UpdateConfigFile), arg0, arg1, arg2, arg3)
+}
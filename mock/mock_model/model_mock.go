@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/polarismesh/polaris-go/pkg/model (interfaces: Instance,CircuitBreakerStatus,ServiceInstances,ValueContext,ServiceClusters)
+// Source: github.com/polarismesh/polaris-go/pkg/model (interfaces: Instance,CircuitBreakerStatus,ServiceInstances,ValueContext,ServiceClusters,ConfigFile)
 
 // Package mock_model is a generated GoMock package.
 package mock_model
@@ -1181,3 +1181,122 @@ func (mr *MockServiceClustersMockRecorder) SetNearbyCluster(arg0, arg1, arg2 int
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetNearbyCluster", reflect.TypeOf((*MockServiceClusters)(nil).SetNearbyCluster), arg0, arg1, arg2)
 }
+
+// MockConfigFile is a mock of ConfigFile interface.
+type MockConfigFile struct {
+	ctrl     *gomock.Controller
+	recorder *MockConfigFileMockRecorder
+}
+
+// MockConfigFileMockRecorder is the mock recorder for MockConfigFile.
+type MockConfigFileMockRecorder struct {
+	mock *MockConfigFile
+}
+
+// NewMockConfigFile creates a new mock instance.
+func NewMockConfigFile(ctrl *gomock.Controller) *MockConfigFile {
+	mock := &MockConfigFile{ctrl: ctrl}
+	mock.recorder = &MockConfigFileMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockConfigFile) EXPECT() *MockConfigFileMockRecorder {
+	return m.recorder
+}
+
+// AddChangeListener mocks base method.
+func (m *MockConfigFile) AddChangeListener(arg0 model.OnConfigFileChange) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "AddChangeListener", arg0)
+}
+
+// AddChangeListener indicates an expected call of AddChangeListener.
+func (mr *MockConfigFileMockRecorder) AddChangeListener(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddChangeListener", reflect.TypeOf((*MockConfigFile)(nil).AddChangeListener), arg0)
+}
+
+// AddChangeListenerWithChannel mocks base method.
+func (m *MockConfigFile) AddChangeListenerWithChannel() <-chan model.ConfigFileChangeEvent {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddChangeListenerWithChannel")
+	ret0, _ := ret[0].(<-chan model.ConfigFileChangeEvent)
+	return ret0
+}
+
+// AddChangeListenerWithChannel indicates an expected call of AddChangeListenerWithChannel.
+func (mr *MockConfigFileMockRecorder) AddChangeListenerWithChannel() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddChangeListenerWithChannel", reflect.TypeOf((*MockConfigFile)(nil).AddChangeListenerWithChannel))
+}
+
+// GetContent mocks base method.
+func (m *MockConfigFile) GetContent() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetContent")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetContent indicates an expected call of GetContent.
+func (mr *MockConfigFileMockRecorder) GetContent() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContent", reflect.TypeOf((*MockConfigFile)(nil).GetContent))
+}
+
+// GetFileGroup mocks base method.
+func (m *MockConfigFile) GetFileGroup() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFileGroup")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetFileGroup indicates an expected call of GetFileGroup.
+func (mr *MockConfigFileMockRecorder) GetFileGroup() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFileGroup", reflect.TypeOf((*MockConfigFile)(nil).GetFileGroup))
+}
+
+// GetFileName mocks base method.
+func (m *MockConfigFile) GetFileName() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFileName")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetFileName indicates an expected call of GetFileName.
+func (mr *MockConfigFileMockRecorder) GetFileName() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFileName", reflect.TypeOf((*MockConfigFile)(nil).GetFileName))
+}
+
+// GetNamespace mocks base method.
+func (m *MockConfigFile) GetNamespace() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNamespace")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetNamespace indicates an expected call of GetNamespace.
+func (mr *MockConfigFileMockRecorder) GetNamespace() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNamespace", reflect.TypeOf((*MockConfigFile)(nil).GetNamespace))
+}
+
+// HasContent mocks base method.
+func (m *MockConfigFile) HasContent() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HasContent")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// HasContent indicates an expected call of HasContent.
+func (mr *MockConfigFileMockRecorder) HasContent() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasContent", reflect.TypeOf((*MockConfigFile)(nil).HasContent))
+}
@@ -2,7 +2,7 @@
 
 mockgen -destination mock_api/api_mock.go \
   github.com/polarismesh/polaris-go/api \
-  SDKContext,ConsumerAPI,ProviderAPI
+  SDKContext,ConsumerAPI,ProviderAPI,LimitAPI,ConfigFileAPI
 
 mockgen -destination mock_loadbalancer/loadbalancer_mock.go \
   github.com/polarismesh/polaris-go/pkg/plugin/loadbalancer \
@@ -10,7 +10,7 @@ mockgen -destination mock_loadbalancer/loadbalancer_mock.go \
 
 mockgen -destination mock_model/model_mock.go \
   github.com/polarismesh/polaris-go/pkg/model \
-  Instance,CircuitBreakerStatus,ServiceInstances,ValueContext,ServiceClusters
+  Instance,CircuitBreakerStatus,ServiceInstances,ValueContext,ServiceClusters,ConfigFile
 
 mockgen -destination mock_plugin/plugin_mock.go \
   github.com/polarismesh/polaris-go/pkg/plugin \
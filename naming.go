@@ -18,12 +18,19 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"trpc.group/trpc-go/trpc-go/plugin"
 	"trpc.group/trpc-go/trpc-naming-polarismesh/circuitbreaker"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/cluster"
 	"trpc.group/trpc-go/trpc-naming-polarismesh/discovery"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/connector"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/expand"
+	locationprovider "trpc.group/trpc-go/trpc-naming-polarismesh/internal/location"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/metrics"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/validate"
 	"trpc.group/trpc-go/trpc-naming-polarismesh/loadbalance"
 	_ "trpc.group/trpc-go/trpc-naming-polarismesh/registry" // 初始化注册模块
 	"trpc.group/trpc-go/trpc-naming-polarismesh/selector"
@@ -41,7 +48,15 @@ func init() {
 	plugin.Register("polarismesh", &SelectorFactory{})
 }
 
+// TLSConfig is the TLS config to connect to the polaris mesh servers.
+type TLSConfig = connector.TLSConfig
+
+// LocationProviderConfig is the config of a provider to detect the location of the instance.
+type LocationProviderConfig = locationprovider.ProviderConfig
+
 // Config framework configuration.
+// The environment variables like ${ENV} are expanded in the addresses, token, directories and location,
+// and those values like file://path are loaded from the files.
 type Config struct {
 	Name                string               `yaml:"-"` // Name is the current name of plugin.
 	Debug               bool                 `yaml:"debug"`
@@ -50,6 +65,7 @@ type Config struct {
 	ReportTimeout       *time.Duration       `yaml:"report_timeout"`
 	EnableServiceRouter *bool                `yaml:"enable_servicerouter"`
 	EnableCanary        *bool                `yaml:"enable_canary"`
+	Canary              CanaryConfig         `yaml:"canary"`
 	PersistDir          *string              `yaml:"persistDir"`
 	ServiceExpireTime   *time.Duration       `yaml:"service_expire_time"`
 	LogDir              *string              `yaml:"log_dir"`
@@ -66,7 +82,25 @@ type Config struct {
 	EnableTransMeta     bool                 `yaml:"enable_trans_meta"`
 	BindIP              string               `yaml:"bind_ip"`
 	InstanceLocation    *model.Location      `yaml:"instance_location"`
-	PolarisConfig       config.Configuration
+	// LocationProviders detect the location of the instance in order, falling back to InstanceLocation.
+	LocationProviders []LocationProviderConfig `yaml:"location_providers"`
+	// Metrics enables or disables the metric families by name, all of them are enabled by default.
+	Metrics map[string]bool `yaml:"metrics"`
+	// Strict rejects the unknown keys of the config.
+	Strict bool `yaml:"strict"`
+	// Clusters are the polaris mesh clusters besides the default one configured by the top level fields.
+	Clusters []ClusterConfig `yaml:"clusters"`
+	// ClusterRoutes maps the callee services to the clusters, the others are routed to the default cluster.
+	ClusterRoutes []cluster.Route `yaml:"cluster_routes"`
+	// TLS enables TLS on the connections to the polaris mesh servers.
+	TLS *TLSConfig `yaml:"tls"`
+	// AuthToken is the access token to the polaris mesh servers.
+	AuthToken string `yaml:"auth_token"`
+	// PolarisConfig is the polaris mesh config of the default cluster.
+	PolarisConfig config.Configuration
+
+	// unknownKeys are the unknown keys found in strict mode.
+	unknownKeys []string
 }
 
 // UnmarshalYAML is the customized unmarshal function to ensure the default value of the config.
@@ -75,6 +109,12 @@ func (c *Config) UnmarshalYAML(value *yaml.Node) error {
 	if err := value.Decode((*plain)(c)); err != nil {
 		return err
 	}
+	if c.Strict {
+		c.unknownKeys = validate.UnknownKeys(value, c)
+	}
+	if err := c.expand(); err != nil {
+		return fmt.Errorf("expand selector config err: %w", err)
+	}
 	if c.LogDir != nil {
 		c.Logs = &Logs{
 			DirPath:    *c.LogDir,
@@ -97,12 +137,37 @@ func (c *Config) UnmarshalYAML(value *yaml.Node) error {
 	return nil
 }
 
+// expand expands the environment variables and loads the file secrets of the config values.
+func (c *Config) expand() error {
+	var e expand.Expander
+	e.String("address_list", &c.AddressList)
+	e.String("auth_token", &c.AuthToken)
+	e.String("persistDir", c.PersistDir)
+	e.String("log_dir", c.LogDir)
+	if c.Logs != nil {
+		e.String("logs.dir_path", &c.Logs.DirPath)
+	}
+	e.Location("instance_location", c.InstanceLocation)
+	for i := range c.Clusters {
+		cl := &c.Clusters[i]
+		e.String(fmt.Sprintf("clusters.%d.address_list", i), &cl.AddressList)
+		e.String(fmt.Sprintf("clusters.%d.persistDir", i), cl.PersistDir)
+	}
+	return e.Err()
+}
+
 // Logs log configuration.
 type Logs struct {
 	DirPath    string `yaml:"dir_path"`
 	Level      string `yaml:"level"`
 	MaxBackups int    `yaml:"max_backups"`
 	MaxSize    int    `yaml:"max_size"`
+	// Bridge is where the polaris-go SDK logs go, empty for the rotating log files under DirPath,
+	// or trpc for the trpc-go log.
+	Bridge string `yaml:"bridge"`
+	// Levels are the levels of the categories forwarded to the trpc-go log, which override Level.
+	// The categories are base, stat, detect, network and stat_report.
+	Levels map[string]string `yaml:"levels"`
 }
 
 // ServiceRouterConfig service routing configuration.
@@ -117,6 +182,31 @@ type ServiceRouterConfig struct {
 	PercentOfMinInstances float64 `yaml:"percent_of_min_instances"`
 	// NeedReturnAllNodes indicates whether to expand all nodes into registry.Node return.
 	NeedReturnAllNodes bool `yaml:"need_return_all_nodes"`
+	// MetadataKeys are the keys read from trpc message metadata as the request metadata.
+	MetadataKeys []string `yaml:"metadata_keys"`
+	// MetadataRoutes are the local routes matched against request metadata in order,
+	// which support exact, regex, prefix, in and range match types.
+	MetadataRoutes []*servicerouter.MetadataRoute `yaml:"metadata_routes"`
+	// Failover configures the fallback environments and sets by callee service name,
+	// "*" applies to all services without specific config.
+	Failover map[string]*servicerouter.Failover `yaml:"failover"`
+	// EnableSetFallback falls back to the region level set and then to no set
+	// when the set has no available instances.
+	EnableSetFallback bool `yaml:"enable_set_fallback"`
+	// RouteCache configures the cache of routing results.
+	RouteCache *servicerouter.RouteCacheConfig `yaml:"route_cache"`
+}
+
+// CanaryConfig percentage based canary configuration.
+type CanaryConfig struct {
+	// Value is the canary label value of canary instances, default as "1".
+	Value string `yaml:"value"`
+	// HashKey is the client metadata key used to make canary sticky, such as user id.
+	HashKey string `yaml:"hash_key"`
+	// Percent is the default percentage of untagged traffic sent to canary instances, in range [0, 100].
+	Percent float64 `yaml:"percent"`
+	// Services overrides the percentage by callee service name.
+	Services map[string]float64 `yaml:"services"`
 }
 
 // DiscoveryConfig configuration.
@@ -133,22 +223,89 @@ type LoadbalanceConfig struct {
 
 // CircuitBreakerConfig circuit breaker configuration.
 type CircuitBreakerConfig struct {
-	CheckPeriod               *time.Duration `yaml:"checkPeriod"`
-	RequestCountAfterHalfOpen *int           `yaml:"requestCountAfterHalfOpen"`
-	SleepWindow               *time.Duration `yaml:"sleepWindow"`
-	SuccessCountAfterHalfOpen *int           `yaml:"successCountAfterHalfOpen"`
-	Chain                     []string       `yaml:"chain"`
-	ErrorCount                *struct {
-		ContinuousErrorThreshold *int           `yaml:"continuousErrorThreshold"`
-		MetricNumBuckets         *int           `yaml:"metricNumBuckets"`
-		MetricStatTimeWindow     *time.Duration `yaml:"metricStatTimeWindow"`
-	} `yaml:"errorCount"`
-	ErrorRate *struct {
-		ErrorRateThreshold     *float64       `yaml:"errorRateThreshold"`
-		MetricNumBuckets       *int           `yaml:"metricNumBuckets"`
-		MetricStatTimeWindow   *time.Duration `yaml:"metricStatTimeWindow"`
-		RequestVolumeThreshold *int           `yaml:"requestVolumeThreshold"`
-	} `yaml:"errorRate"`
+	CheckPeriod               *time.Duration    `yaml:"checkPeriod"`
+	RequestCountAfterHalfOpen *int              `yaml:"requestCountAfterHalfOpen"`
+	SleepWindow               *time.Duration    `yaml:"sleepWindow"`
+	SuccessCountAfterHalfOpen *int              `yaml:"successCountAfterHalfOpen"`
+	Chain                     []string          `yaml:"chain"`
+	ErrorCount                *ErrorCountConfig `yaml:"errorCount"`
+	ErrorRate                 *ErrorRateConfig  `yaml:"errorRate"`
+	// Services overrides the thresholds by callee service name.
+	Services map[string]*ServiceCircuitBreakerConfig `yaml:"services"`
+	// RetCodes maps the trpc errors to the RetCode reported to polaris.
+	RetCodes []*circuitbreaker.RetCodeRule `yaml:"ret_codes"`
+	// ErrorPolicy classifies the trpc errors for circuit breaking.
+	ErrorPolicy *circuitbreaker.ErrorPolicy `yaml:"error_policy"`
+	// Levels configures the circuit breaking level by callee service name, one of instance, method and instance_method.
+	// The method levels only work with the discovery, service router, load balancer and circuit breaker pipeline.
+	Levels map[string]string `yaml:"levels"`
+	// StateWatchInterval is the interval of watching the instance states for the state change events,
+	// default as 0 which disables it.
+	StateWatchInterval time.Duration `yaml:"state_watch_interval"`
+	// OutlierDetection configures the latency based outlier detection.
+	OutlierDetection *circuitbreaker.OutlierConfig `yaml:"outlier_detection"`
+	// Probe configures the active health probe of the broken instances.
+	Probe *circuitbreaker.ProbeConfig `yaml:"probe"`
+}
+
+// ErrorCountConfig is the config of the circuit breaking by continuous errors.
+type ErrorCountConfig struct {
+	ContinuousErrorThreshold *int           `yaml:"continuousErrorThreshold"`
+	MetricNumBuckets         *int           `yaml:"metricNumBuckets"`
+	MetricStatTimeWindow     *time.Duration `yaml:"metricStatTimeWindow"`
+}
+
+// ErrorRateConfig is the config of the circuit breaking by error rate.
+type ErrorRateConfig struct {
+	ErrorRateThreshold     *float64       `yaml:"errorRateThreshold"`
+	MetricNumBuckets       *int           `yaml:"metricNumBuckets"`
+	MetricStatTimeWindow   *time.Duration `yaml:"metricStatTimeWindow"`
+	RequestVolumeThreshold *int           `yaml:"requestVolumeThreshold"`
+}
+
+// ServiceCircuitBreakerConfig overrides the circuit breaker thresholds of a callee service,
+// the omitted fields fall back to the global values.
+type ServiceCircuitBreakerConfig struct {
+	// Namespace is the namespace of the callee service, default as Production.
+	Namespace  string            `yaml:"namespace"`
+	ErrorCount *ErrorCountConfig `yaml:"errorCount"`
+	// The fields below are rejected by Config.Validate, as polaris-go only honors errorCount per service.
+	RequestCountAfterHalfOpen *int             `yaml:"requestCountAfterHalfOpen"`
+	SleepWindow               *time.Duration   `yaml:"sleepWindow"`
+	SuccessCountAfterHalfOpen *int             `yaml:"successCountAfterHalfOpen"`
+	ErrorRate                 *ErrorRateConfig `yaml:"errorRate"`
+}
+
+// defaultServiceNamespace is the default namespace of the callee services with specific circuit breaker config.
+const defaultServiceNamespace = "Production"
+
+// setMethodThresholds sets the thresholds of the method level breakers the same as polaris.
+func (c *CircuitBreakerConfig) setMethodThresholds(cfg *circuitbreaker.Config) {
+	if c.ErrorCount != nil && c.ErrorCount.ContinuousErrorThreshold != nil {
+		cfg.ContinuousErrorThreshold = *c.ErrorCount.ContinuousErrorThreshold
+	}
+	if c.SleepWindow != nil {
+		cfg.SleepWindow = *c.SleepWindow
+	}
+	if c.RequestCountAfterHalfOpen != nil {
+		cfg.RequestCountAfterHalfOpen = *c.RequestCountAfterHalfOpen
+	}
+	if c.SuccessCountAfterHalfOpen != nil {
+		cfg.SuccessCountAfterHalfOpen = *c.SuccessCountAfterHalfOpen
+	}
+	for service, sc := range c.Services {
+		if sc == nil {
+			continue
+		}
+		var t circuitbreaker.Thresholds
+		if sc.ErrorCount != nil && sc.ErrorCount.ContinuousErrorThreshold != nil {
+			t.ContinuousErrorThreshold = *sc.ErrorCount.ContinuousErrorThreshold
+		}
+		if cfg.Services == nil {
+			cfg.Services = make(map[string]circuitbreaker.Thresholds)
+		}
+		cfg.Services[service] = t
+	}
 }
 
 // ClusterService cluster service.
@@ -192,8 +349,24 @@ func (c *Config) getEnableServiceRouter() bool {
 	return isEnable
 }
 
-func (c *Config) setLog() {
-	if l := c.Logs; l != nil {
+func (c *Config) getCanary() *servicerouter.CanaryConfig {
+	return &servicerouter.CanaryConfig{
+		Value:    c.Canary.Value,
+		HashKey:  c.Canary.HashKey,
+		Percent:  c.Canary.Percent,
+		Services: c.Canary.Services,
+	}
+}
+
+func (c *Config) setLog() error {
+	if l := c.Logs; l != nil && l.Bridge != "" {
+		if l.Bridge != LogBridgeTRPC {
+			return fmt.Errorf("unknown log bridge %s", l.Bridge)
+		}
+		if err := bridgeLogs(l); err != nil {
+			return err
+		}
+	} else if l != nil {
 		newLogOptions := func(path string) *plog.Options {
 			o := plog.CreateDefaultLoggerOptions(filepath.Join(l.DirPath, path), getLogLevel(l.Level))
 			o.RotationMaxBackups = l.MaxBackups
@@ -209,6 +382,7 @@ func (c *Config) setLog() {
 	if c.Debug {
 		plog.GetBaseLogger().SetLogLevel(plog.DebugLog)
 	}
+	return nil
 }
 
 // Setup initialization.
@@ -238,65 +412,109 @@ func SetupWithConfig(conf *Config) error {
 }
 
 func setupWithConfig(conf *Config) (api.SDKContext, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid selector config: %w", err)
+	}
+	// The config as is before the defaults are filled, which is compared by Reload.
+	raw := *conf
 	// 如果没设置协议默认使用 grpc 协议
 	if len(conf.Protocol) == 0 {
 		conf.Protocol = "grpc"
 	}
 
 	// Initialization log.
-	conf.setLog()
+	if err := conf.setLog(); err != nil {
+		return nil, fmt.Errorf("invalid logs config: %w", err)
+	}
+	if err := metrics.Configure(conf.Metrics); err != nil {
+		return nil, fmt.Errorf("invalid metrics config: %w", err)
+	}
 	sdkCtx, err := newSDKContext(conf)
 	if err != nil {
 		return nil, fmt.Errorf("new sdk ctx err: %w", err)
 	}
-	return sdkCtx, setupComponents(sdkCtx, conf)
+	if len(conf.Clusters) > 0 {
+		err = setupClusters(sdkCtx, conf)
+	} else {
+		err = setupComponents(sdkCtx, conf)
+	}
+	if err != nil {
+		return sdkCtx, err
+	}
+	setApplied(&raw)
+	return sdkCtx, nil
 }
 
 func setupComponents(sdkCtx api.SDKContext, conf *Config) error {
 	setDefault := conf.getSetDefault()
-	enableServiceRouter := conf.getEnableServiceRouter()
-	enableCanary := conf.getEnableCanary()
+	metadataRouter, err := servicerouter.NewMetadataRouter(
+		conf.ServiceRouter.MetadataKeys, conf.ServiceRouter.MetadataRoutes)
+	if err != nil {
+		return fmt.Errorf("new metadata router err: %w", err)
+	}
+	retCodes, err := circuitbreaker.NewRetCodeMapper(conf.CircuitBreaker.RetCodes)
+	if err != nil {
+		return fmt.Errorf("new ret code mapper err: %w", err)
+	}
+	classifier, err := circuitbreaker.NewClassifier(conf.ReportTimeout, conf.CircuitBreaker.ErrorPolicy, retCodes)
+	if err != nil {
+		return fmt.Errorf("new circuit breaker classifier err: %w", err)
+	}
+	if err := classifier.DetectOutliers(conf.CircuitBreaker.OutlierDetection); err != nil {
+		return fmt.Errorf("invalid outlier detection config: %w", err)
+	}
 	if err := discovery.Setup(sdkCtx, &discovery.Config{Name: conf.Name}, setDefault); err != nil {
 		return err
 	}
 
 	// Initialize service routing.
-	if err := servicerouter.Setup(
-		sdkCtx,
-		&servicerouter.Config{
-			Name:               conf.Name,
-			Enable:             enableServiceRouter,
-			EnableCanary:       enableCanary,
-			NeedReturnAllNodes: conf.ServiceRouter.NeedReturnAllNodes,
-		},
-		setDefault,
-	); err != nil {
+	if err := servicerouter.Setup(sdkCtx, conf.serviceRouterConfig(metadataRouter), setDefault); err != nil {
 		return err
 	}
 	if err := setupLoadbalance(sdkCtx, conf, setDefault); err != nil {
 		return err
 	}
-	if err := circuitbreaker.Setup(
-		sdkCtx,
-		&circuitbreaker.Config{
-			Name:          conf.Name,
-			ReportTimeout: conf.ReportTimeout,
-		},
-		setDefault,
-	); err != nil {
-		return err
+	cbConfig := &circuitbreaker.Config{
+		Name:               conf.Name,
+		Classifier:         classifier,
+		Levels:             conf.CircuitBreaker.Levels,
+		StateWatchInterval: conf.CircuitBreaker.StateWatchInterval,
+		Probe:              conf.CircuitBreaker.Probe,
 	}
-	if err := selector.Setup(sdkCtx,
-		&selector.Config{
-			Name:            conf.Name,
-			Enable:          enableServiceRouter,
-			EnableCanary:    enableCanary,
-			ReportTimeout:   conf.ReportTimeout,
-			EnableTransMeta: conf.EnableTransMeta,
-		}); err != nil {
+	conf.CircuitBreaker.setMethodThresholds(cbConfig)
+	if err := circuitbreaker.Setup(sdkCtx, cbConfig, setDefault); err != nil {
 		return err
 	}
-	return nil
+	return selector.Setup(sdkCtx, conf.selectorConfig(metadataRouter, classifier))
+}
+
+func (c *Config) serviceRouterConfig(metadataRouter *servicerouter.MetadataRouter) *servicerouter.Config {
+	return &servicerouter.Config{
+		Name:               c.Name,
+		Enable:             c.getEnableServiceRouter(),
+		EnableCanary:       c.getEnableCanary(),
+		NeedReturnAllNodes: c.ServiceRouter.NeedReturnAllNodes,
+		Canary:             c.getCanary(),
+		MetadataRouter:     metadataRouter,
+		Failover:           c.ServiceRouter.Failover,
+		EnableSetFallback:  c.ServiceRouter.EnableSetFallback,
+		RouteCache:         c.ServiceRouter.RouteCache,
+	}
+}
+
+func (c *Config) selectorConfig(
+	metadataRouter *servicerouter.MetadataRouter, classifier *circuitbreaker.Classifier) *selector.Config {
+	return &selector.Config{
+		Name:              c.Name,
+		Enable:            c.getEnableServiceRouter(),
+		EnableCanary:      c.getEnableCanary(),
+		ReportTimeout:     c.ReportTimeout,
+		EnableTransMeta:   c.EnableTransMeta,
+		Canary:            c.getCanary(),
+		MetadataRouter:    metadataRouter,
+		EnableSetFallback: c.ServiceRouter.EnableSetFallback,
+		Classifier:        classifier,
+	}
 }
 
 func setupLoadbalance(sdkCtx api.SDKContext, conf *Config, setDefault bool) error {
@@ -322,61 +540,111 @@ func setupLoadbalance(sdkCtx api.SDKContext, conf *Config, setDefault bool) erro
 }
 
 func setSdkCircuitBreaker(c config.Configuration, cfg *Config) {
+	cb := c.GetConsumer().GetCircuitBreaker()
 	if len(cfg.CircuitBreaker.Chain) > 0 {
-		c.GetConsumer().GetCircuitBreaker().SetChain(cfg.CircuitBreaker.Chain)
+		cb.SetChain(cfg.CircuitBreaker.Chain)
 	}
 	if cfg.CircuitBreaker.CheckPeriod != nil {
-		c.GetConsumer().GetCircuitBreaker().SetCheckPeriod(*cfg.CircuitBreaker.CheckPeriod)
+		cb.SetCheckPeriod(*cfg.CircuitBreaker.CheckPeriod)
 	}
 	if cfg.CircuitBreaker.RequestCountAfterHalfOpen != nil {
-		c.GetConsumer().GetCircuitBreaker().SetRequestCountAfterHalfOpen(*cfg.CircuitBreaker.RequestCountAfterHalfOpen)
+		cb.SetRequestCountAfterHalfOpen(*cfg.CircuitBreaker.RequestCountAfterHalfOpen)
 	}
 	if cfg.CircuitBreaker.SleepWindow != nil {
-		c.GetConsumer().GetCircuitBreaker().SetSleepWindow(*cfg.CircuitBreaker.SleepWindow)
+		cb.SetSleepWindow(*cfg.CircuitBreaker.SleepWindow)
 	}
 	if cfg.CircuitBreaker.SuccessCountAfterHalfOpen != nil {
-		c.GetConsumer().GetCircuitBreaker().SetSuccessCountAfterHalfOpen(*cfg.CircuitBreaker.SuccessCountAfterHalfOpen)
+		cb.SetSuccessCountAfterHalfOpen(*cfg.CircuitBreaker.SuccessCountAfterHalfOpen)
 	}
-	setErrorCount(c, cfg)
-	setErrorRate(c, cfg)
+	setErrorCount(cb.GetErrorCountConfig(), cfg.CircuitBreaker.ErrorCount)
+	setErrorRate(cb.GetErrorRateConfig(), cfg.CircuitBreaker.ErrorRate)
+	setServiceCircuitBreakers(c, cfg)
 }
 
-func setErrorCount(c config.Configuration, cfg *Config) {
-	if cfg.CircuitBreaker.ErrorCount == nil {
+// setServiceCircuitBreakers sets the service specific circuit breaker config of polaris,
+// which starts from the global config and is overridden by the config of the service.
+func setServiceCircuitBreakers(c config.Configuration, cfg *Config) {
+	consumer, ok := c.GetConsumer().(*config.ConsumerConfigImpl)
+	if !ok {
+		return
+	}
+	global := c.GetConsumer().GetCircuitBreaker()
+	services := make([]string, 0, len(cfg.CircuitBreaker.Services))
+	for service := range cfg.CircuitBreaker.Services {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		sc := cfg.CircuitBreaker.Services[service]
+		if sc == nil {
+			continue
+		}
+		namespace := sc.Namespace
+		if namespace == "" {
+			namespace = defaultServiceNamespace
+		}
+		specific := &config.ServiceSpecific{Namespace: namespace, Service: service}
+		specific.Init()
+		specific.SetDefault()
+		cb := specific.CircuitBreaker
+		cb.SetChain(global.GetChain())
+		cb.SetCheckPeriod(global.GetCheckPeriod())
+		cb.SetSleepWindow(global.GetSleepWindow())
+		cb.SetRequestCountAfterHalfOpen(global.GetRequestCountAfterHalfOpen())
+		cb.SetSuccessCountAfterHalfOpen(global.GetSuccessCountAfterHalfOpen())
+		copyErrorCount(cb.GetErrorCountConfig(), global.GetErrorCountConfig())
+		copyErrorRate(cb.GetErrorRateConfig(), global.GetErrorRateConfig())
+		setErrorCount(cb.GetErrorCountConfig(), sc.ErrorCount)
+		consumer.ServicesSpecific = append(consumer.ServicesSpecific, specific)
+	}
+}
+
+func setErrorCount(c config.ErrorCountConfig, errorCount *ErrorCountConfig) {
+	if errorCount == nil {
 		return
 	}
-	errorCount := cfg.CircuitBreaker.ErrorCount
 	if errorCount.ContinuousErrorThreshold != nil {
-		c.GetConsumer().GetCircuitBreaker().GetErrorCountConfig().
-			SetContinuousErrorThreshold(*errorCount.ContinuousErrorThreshold)
+		c.SetContinuousErrorThreshold(*errorCount.ContinuousErrorThreshold)
 	}
 	if errorCount.MetricNumBuckets != nil {
-		c.GetConsumer().GetCircuitBreaker().GetErrorCountConfig().SetMetricNumBuckets(*errorCount.MetricNumBuckets)
+		c.SetMetricNumBuckets(*errorCount.MetricNumBuckets)
 	}
 	if errorCount.MetricStatTimeWindow != nil {
-		c.GetConsumer().GetCircuitBreaker().GetErrorCountConfig().SetMetricStatTimeWindow(*errorCount.MetricStatTimeWindow)
+		c.SetMetricStatTimeWindow(*errorCount.MetricStatTimeWindow)
 	}
 }
 
-func setErrorRate(c config.Configuration, cfg *Config) {
-	if cfg.CircuitBreaker.ErrorRate == nil {
+func setErrorRate(c config.ErrorRateConfig, errorRate *ErrorRateConfig) {
+	if errorRate == nil {
 		return
 	}
-	errorRate := cfg.CircuitBreaker.ErrorRate
 	if errorRate.ErrorRateThreshold != nil {
-		c.GetConsumer().GetCircuitBreaker().GetErrorRateConfig().SetErrorRatePercent(int(*errorRate.ErrorRateThreshold * 100))
+		c.SetErrorRatePercent(int(*errorRate.ErrorRateThreshold * 100))
 	}
 	if errorRate.MetricNumBuckets != nil {
-		c.GetConsumer().GetCircuitBreaker().GetErrorRateConfig().SetMetricNumBuckets(*errorRate.MetricNumBuckets)
+		c.SetMetricNumBuckets(*errorRate.MetricNumBuckets)
 	}
 	if errorRate.RequestVolumeThreshold != nil {
-		c.GetConsumer().GetCircuitBreaker().GetErrorRateConfig().SetRequestVolumeThreshold(*errorRate.RequestVolumeThreshold)
+		c.SetRequestVolumeThreshold(*errorRate.RequestVolumeThreshold)
 	}
 	if errorRate.MetricStatTimeWindow != nil {
-		c.GetConsumer().GetCircuitBreaker().GetErrorRateConfig().SetMetricStatTimeWindow(*errorRate.MetricStatTimeWindow)
+		c.SetMetricStatTimeWindow(*errorRate.MetricStatTimeWindow)
 	}
 }
 
+func copyErrorCount(dst, src config.ErrorCountConfig) {
+	dst.SetContinuousErrorThreshold(src.GetContinuousErrorThreshold())
+	dst.SetMetricNumBuckets(src.GetMetricNumBuckets())
+	dst.SetMetricStatTimeWindow(src.GetMetricStatTimeWindow())
+}
+
+func copyErrorRate(dst, src config.ErrorRateConfig) {
+	dst.SetErrorRatePercent(src.GetErrorRatePercent())
+	dst.SetMetricNumBuckets(src.GetMetricNumBuckets())
+	dst.SetRequestVolumeThreshold(src.GetRequestVolumeThreshold())
+	dst.SetMetricStatTimeWindow(src.GetMetricStatTimeWindow())
+}
+
 func setSdkProperty(c config.Configuration, cfg *Config) {
 	if cfg.Timeout != 0 {
 		timeout := time.Duration(cfg.Timeout) * time.Millisecond
@@ -413,8 +681,8 @@ func setSdkProperty(c config.Configuration, cfg *Config) {
 }
 
 func setLocation(c config.Configuration, cfg *Config) {
-	il := cfg.InstanceLocation
-	if il != nil && il.Region != "" && il.Zone != "" && il.Campus != "" {
+	il := locationprovider.Resolve("selector "+cfg.Name, cfg.InstanceLocation, cfg.LocationProviders)
+	if il != nil {
 		// It seems that the location API of polaris-go is broken.
 		// I have no choice but to write the following codes.
 		l := c.GetGlobal().GetLocation().(*config.LocationConfigImpl)
@@ -490,6 +758,10 @@ func newSDKContext(cfg *Config) (api.SDKContext, error) {
 	setLocation(c, cfg)
 	// Configure other properties.
 	setSdkProperty(c, cfg)
+	// Configure the security settings of the connections.
+	if err := connector.Set(c, cfg.TLS, cfg.AuthToken); err != nil {
+		return nil, fmt.Errorf("failed to set security config: %w", err)
+	}
 	sdkCtx, err := api.InitContextByConfig(c)
 	if err != nil {
 		return nil, err
@@ -19,6 +19,8 @@ import (
 	"testing"
 	"time"
 
+	"trpc.group/trpc-go/trpc-naming-polarismesh/circuitbreaker"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/connector"
 	_ "trpc.group/trpc-go/trpc-naming-polarismesh/registry"
 
 	"trpc.group/trpc-go/trpc-go"
@@ -26,6 +28,7 @@ import (
 	"github.com/polarismesh/polaris-go/api"
 	"github.com/polarismesh/polaris-go/pkg/config"
 	plog "github.com/polarismesh/polaris-go/pkg/log"
+	"github.com/polarismesh/polaris-go/pkg/model"
 	"github.com/polarismesh/polaris-go/plugin/loadbalancer/ringhash"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -197,6 +200,74 @@ instance_location:                # 注册实例的地址位置信息
 	assert.Equal(t, "Shenzhen", sdkCtx.GetValueContext().GetCurrentLocation().GetLocation().Campus)
 }
 
+func Test_newSDKContextServiceCircuitBreaker(t *testing.T) {
+	cfgstr := `
+address_list: 127.0.0.1:0
+log_dir: /tmp/polarismesh/log
+persistDir: /tmp/polarismesh/backup
+circuitbreaker:
+  sleepWindow: 25s
+  errorCount:
+    continuousErrorThreshold: 20
+  errorRate:
+    requestVolumeThreshold: 12
+  services:
+    trpc.app.flaky.service:
+      errorCount:
+        continuousErrorThreshold: 50
+    trpc.app.db.proxy:
+      namespace: Development
+      errorCount:
+        metricStatTimeWindow: 30s
+`
+	cfg := Config{}
+	require.Nil(t, yaml.Unmarshal([]byte(cfgstr), &cfg))
+	sdkCtx, err := newSDKContext(&cfg)
+	require.Nil(t, err)
+	consumer := sdkCtx.GetConfig().GetConsumer()
+	assert.Nil(t, consumer.GetServiceSpecific("Development", "trpc.app.flaky.service"))
+
+	flaky := consumer.GetServiceSpecific("Production", "trpc.app.flaky.service").GetServiceCircuitBreaker()
+	assert.Equal(t, 25*time.Second, flaky.GetSleepWindow(), "fall back to the global value")
+	assert.Equal(t, 50, flaky.GetErrorCountConfig().GetContinuousErrorThreshold())
+	assert.Equal(t, 12, flaky.GetErrorRateConfig().GetRequestVolumeThreshold())
+
+	db := consumer.GetServiceSpecific("Development", "trpc.app.db.proxy").GetServiceCircuitBreaker()
+	assert.Equal(t, 30*time.Second, db.GetErrorCountConfig().GetMetricStatTimeWindow())
+	assert.Equal(t, 20, db.GetErrorCountConfig().GetContinuousErrorThreshold())
+
+	cbConfig := &circuitbreaker.Config{}
+	cfg.CircuitBreaker.setMethodThresholds(cbConfig)
+	assert.Equal(t, 20, cbConfig.ContinuousErrorThreshold)
+	assert.Equal(t, map[string]circuitbreaker.Thresholds{
+		"trpc.app.flaky.service": {ContinuousErrorThreshold: 50},
+		"trpc.app.db.proxy":      {},
+	}, cbConfig.Services)
+}
+
+func Test_newSDKContextSecurity(t *testing.T) {
+	t.Setenv("POLARIS_TOKEN", "secret")
+	cfgstr := `
+address_list: 127.0.0.1:0
+auth_token: ${POLARIS_TOKEN}
+tls:
+  server_name: polaris.example.com
+`
+	cfg := Config{}
+	require.Nil(t, yaml.Unmarshal([]byte(cfgstr), &cfg))
+	sdkCtx, err := newSDKContext(&cfg)
+	require.Nil(t, err)
+	assert.Equal(t, connector.Protocol, sdkCtx.GetConfig().GetGlobal().GetServerConnector().GetProtocol())
+	security := connector.Get(sdkCtx.GetConfig())
+	require.NotNil(t, security)
+	assert.Equal(t, "secret", security.AuthToken)
+	assert.Equal(t, "polaris.example.com", security.TLS.ServerName)
+
+	cfg.TLS = &TLSConfig{CAFile: "not_exist"}
+	_, err = newSDKContext(&cfg)
+	assert.ErrorContains(t, err, "read ca_file err")
+}
+
 func Test_getLogLevel(t *testing.T) {
 	type args struct {
 		desc string
@@ -391,3 +462,61 @@ plugins:
 		})
 	}
 }
+
+func TestConfig_UnmarshalYAMLExpand(t *testing.T) {
+	dir := t.TempDir()
+	require.Nil(t, os.WriteFile(dir+"/token", []byte("secret\n"), 0600))
+	t.Setenv("POLARIS_ADDRESS", "127.0.0.1:8091")
+	t.Setenv("POLARIS_DIR", dir)
+	t.Setenv("NODE_ZONE", "Guangdong")
+	cfg := Config{}
+	require.Nil(t, yaml.Unmarshal([]byte(`
+address_list: ${POLARIS_ADDRESS}
+auth_token: file://${POLARIS_DIR}/token
+persistDir: ${POLARIS_DIR}/backup
+log_dir: ${POLARIS_DIR}/log
+instance_location:
+  region: China
+  zone: ${NODE_ZONE}
+  campus: $NODE_CAMPUS
+clusters:
+  - name: new
+    address_list: ${POLARIS_ADDRESS}
+`), &cfg))
+	assert.Equal(t, "127.0.0.1:8091", cfg.AddressList)
+	assert.Equal(t, "secret", cfg.AuthToken)
+	assert.Equal(t, dir+"/backup", *cfg.PersistDir)
+	assert.Equal(t, dir+"/log", cfg.Logs.DirPath)
+	assert.Equal(t, &model.Location{Region: "China", Zone: "Guangdong"}, cfg.InstanceLocation)
+	assert.Equal(t, "127.0.0.1:8091", cfg.Clusters[0].AddressList)
+
+	err := yaml.Unmarshal([]byte(`auth_token: file://${POLARIS_DIR}/not_exist`), &Config{})
+	assert.ErrorContains(t, err, "expand selector config err: 1 config problem(s): auth_token: read file")
+}
+
+func Test_newSDKContextLocationProviders(t *testing.T) {
+	t.Setenv("NODE_REGION", "China")
+	t.Setenv("NODE_ZONE", "Guangdong")
+	cfgstr := `
+address_list: 127.0.0.1:0
+location_providers:
+  - type: env
+    region: NODE_REGION
+    zone: NODE_ZONE
+    campus: NODE_CAMPUS
+  - type: static
+    region: Default
+instance_location:
+  region: Configured
+`
+	cfg := Config{}
+	require.Nil(t, yaml.Unmarshal([]byte(cfgstr), &cfg))
+	require.Nil(t, cfg.Validate())
+	sdkCtx, err := newSDKContext(&cfg)
+	require.Nil(t, err)
+	assert.Equal(t, &model.Location{Region: "China", Zone: "Guangdong"},
+		sdkCtx.GetValueContext().GetCurrentLocation().GetLocation(), "the partial location is applied")
+
+	cfg.LocationProviders = []LocationProviderConfig{{Type: "cloud"}}
+	assert.ErrorContains(t, cfg.Validate(), `location_providers.0.type must be one of`)
+}
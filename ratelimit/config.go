@@ -0,0 +1,88 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package ratelimit
+
+import (
+	"time"
+
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/validate"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the config of the rate limiting filters.
+type Config struct {
+	// Selector is the name of the selector plugin whose polaris SDK context is shared, default as polarismesh.
+	Selector string `yaml:"selector"`
+	// Namespace overrides the namespace of the limited services, default as the namespace of this server
+	// for the server filter, and the callee namespace of the client options for the client filter.
+	Namespace string `yaml:"namespace"`
+	// Labels are the keys of the request metadata, whose values are matched with the labels of the limit rules.
+	Labels []string `yaml:"labels"`
+	// Timeout is the timeout to acquire the quota from polaris, default as the API timeout of the SDK context.
+	Timeout time.Duration `yaml:"timeout"`
+	// ErrorCode is the trpc error code returned for the limited requests,
+	// default as 23 (server throttled) for the server filter and 123 (client throttled) for the client filter.
+	ErrorCode int `yaml:"error_code"`
+	// Fallback limits the requests locally when the quota fails to be acquired from polaris.
+	Fallback FallbackConfig `yaml:"fallback"`
+	// Strict rejects the unknown keys of the config.
+	Strict bool `yaml:"strict"`
+
+	// unknownKeys are the unknown keys found in strict mode.
+	unknownKeys []string
+}
+
+// FallbackConfig is the config of the local fallback limiter, which limits each method of each service by
+// a token bucket.
+type FallbackConfig struct {
+	// QPS is the rate of the requests allowed, 0 to allow all requests.
+	QPS float64 `yaml:"qps"`
+	// Burst is the max number of requests allowed at once, default as QPS.
+	Burst int `yaml:"burst"`
+}
+
+// UnmarshalYAML records the unknown keys if strict is enabled.
+func (c *Config) UnmarshalYAML(value *yaml.Node) error {
+	type plain Config
+	if err := value.Decode((*plain)(c)); err != nil {
+		return err
+	}
+	if c.Strict {
+		c.unknownKeys = validate.UnknownKeys(value, c)
+	}
+	return nil
+}
+
+// Validate checks the config, and returns all problems found at once.
+func (c *Config) Validate() error {
+	var p validate.Problems
+	for _, key := range c.unknownKeys {
+		p.Addf("unknown key %s", key)
+	}
+	if c.Timeout < 0 {
+		p.Addf("timeout must not be negative, got %v", c.Timeout)
+	}
+	p.NonNegative("error_code", c.ErrorCode)
+	if c.Fallback.QPS < 0 {
+		p.Addf("fallback.qps must not be negative, got %v", c.Fallback.QPS)
+	}
+	p.NonNegative("fallback.burst", c.Fallback.Burst)
+	for i, label := range c.Labels {
+		if label == "" {
+			p.Addf("labels.%d must not be empty", i)
+		}
+	}
+	return p.Err()
+}
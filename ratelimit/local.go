@@ -0,0 +1,70 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// localLimiter limits the requests by key with token buckets, it is used when polaris is unavailable.
+type localLimiter struct {
+	qps   float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newLocalLimiter(cfg FallbackConfig) *localLimiter {
+	burst := float64(cfg.Burst)
+	if burst == 0 {
+		burst = cfg.QPS
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &localLimiter{qps: cfg.QPS, burst: burst, buckets: make(map[string]*bucket)}
+}
+
+// allow reports whether the request of the key is allowed at now, all requests are allowed if qps is 0.
+func (l *localLimiter) allow(key string, now time.Time) bool {
+	if l.qps == 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * l.qps
+		if b.tokens > l.burst {
+			b.tokens = l.burst
+		}
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
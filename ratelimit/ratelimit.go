@@ -0,0 +1,199 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+// Package ratelimit limits the requests by the rate limit rules of polaris mesh with trpc filters.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/client"
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/errs"
+	"trpc.group/trpc-go/trpc-go/filter"
+	"trpc.group/trpc-go/trpc-go/log"
+	"trpc.group/trpc-go/trpc-go/naming/selector"
+	"trpc.group/trpc-go/trpc-go/plugin"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/metrics"
+
+	"github.com/polarismesh/polaris-go/api"
+	"github.com/polarismesh/polaris-go/pkg/model"
+)
+
+const (
+	pluginType          = "ratelimit"
+	pluginName          = "polarismesh"
+	defaultSelectorName = "polarismesh"
+
+	// FilterName is the name of the server and client rate limiting filters.
+	FilterName = "polarismesh_ratelimit"
+
+	sideServer = "server"
+	sideClient = "client"
+)
+
+func init() {
+	plugin.Register(pluginName, &Factory{})
+}
+
+// Factory is the rate limiting plugin, which registers the filters named FilterName.
+type Factory struct{}
+
+// Type returns the plugin type.
+func (f *Factory) Type() string {
+	return pluginType
+}
+
+// Setup creates the limiter on the polaris SDK context of the selector, and registers the filters.
+func (f *Factory) Setup(name string, dec plugin.Decoder) error {
+	if dec == nil {
+		return errors.New("ratelimit config decoder empty")
+	}
+	cfg := &Config{}
+	if err := dec.Decode(cfg); err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid ratelimit config: %w", err)
+	}
+	sdkCtx, err := selectorSDKCtx(cfg.Selector)
+	if err != nil {
+		return err
+	}
+	l := New(api.NewLimitAPIByContext(sdkCtx), cfg)
+	filter.Register(FilterName, l.ServerFilter, l.ClientFilter)
+	return nil
+}
+
+// FlexDependsOn makes sure that the limiter is set up after the selector, whose SDK context is shared.
+func (f *Factory) FlexDependsOn() []string {
+	return []string{"selector-polarismesh"}
+}
+
+// selectorSDKCtx returns the SDK context of the selector plugin.
+func selectorSDKCtx(name string) (api.SDKContext, error) {
+	if name == "" {
+		name = defaultSelectorName
+	}
+	f, ok := plugin.Get("selector", name).(interface{ GetSDKCtx() api.SDKContext })
+	if !ok || f.GetSDKCtx() == nil {
+		return nil, fmt.Errorf("selector %s is not set up", name)
+	}
+	return f.GetSDKCtx(), nil
+}
+
+// Limiter limits the requests by the quota acquired from polaris mesh,
+// and by the local fallback limiter if the quota fails to be acquired.
+type Limiter struct {
+	limitAPI api.LimitAPI
+	cfg      *Config
+	fallback *localLimiter
+}
+
+// New creates a limiter.
+func New(limitAPI api.LimitAPI, cfg *Config) *Limiter {
+	return &Limiter{
+		limitAPI: limitAPI,
+		cfg:      cfg,
+		fallback: newLocalLimiter(cfg.Fallback),
+	}
+}
+
+// ServerFilter limits the requests by the callee service and method,
+// and the labels read from the metadata of the request.
+func (l *Limiter) ServerFilter(ctx context.Context, req interface{}, next filter.ServerHandleFunc) (interface{}, error) {
+	msg := codec.Message(ctx)
+	release, err := l.acquire(sideServer, msg.Namespace(), msg.CalleeServiceName(), msg.CalleeMethod(),
+		msg.ServerMetaData())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return next(ctx, req)
+}
+
+// ClientFilter limits the requests to the callee service of the namespace set by the client options and method,
+// and the labels read from the metadata sent to the callee.
+func (l *Limiter) ClientFilter(ctx context.Context, req, rsp interface{}, next filter.ClientHandleFunc) error {
+	msg := codec.Message(ctx)
+	release, err := l.acquire(sideClient, calleeNamespace(ctx), msg.CalleeServiceName(), msg.CalleeMethod(),
+		msg.ClientMetaData())
+	if err != nil {
+		return err
+	}
+	defer release()
+	return next(ctx, req, rsp)
+}
+
+// calleeNamespace returns the namespace of the callee service set by the client options,
+// such as client.WithNamespace and the namespace of the client config.
+func calleeNamespace(ctx context.Context) string {
+	opts := &selector.Options{}
+	for _, o := range client.OptionsFromContext(ctx).SelectOptions {
+		o(opts)
+	}
+	return opts.Namespace
+}
+
+// acquire acquires the quota of the request, and returns the function to release it after the request,
+// which is required by the concurrency limit rules.
+func (l *Limiter) acquire(side, namespace, service, method string, md codec.MetaData) (func(), error) {
+	if l.cfg.Namespace != "" {
+		namespace = l.cfg.Namespace
+	}
+	req := api.NewQuotaRequest()
+	req.SetNamespace(namespace)
+	req.SetService(service)
+	req.SetMethod(method)
+	for _, key := range l.cfg.Labels {
+		if value, ok := md[key]; ok {
+			req.AddArgument(model.BuildCustomArgument(key, string(value)))
+		}
+	}
+	if l.cfg.Timeout > 0 {
+		req.SetTimeout(l.cfg.Timeout)
+	}
+	future, err := l.limitAPI.GetQuota(req)
+	if err != nil {
+		log.Debugf("[NAMING-POLARISMESH] get quota of %s/%s/%s err: %v, fall back to local limiter",
+			namespace, service, method, err)
+		if !l.fallback.allow(namespace+"/"+service+"/"+method, time.Now()) {
+			metrics.ReportRateLimit(namespace, service, method, side,
+				metrics.QuotaSourceFallback, metrics.ResultLimited)
+			return nil, l.limitedError(side, "local fallback limiter")
+		}
+		metrics.ReportRateLimit(namespace, service, method, side, metrics.QuotaSourceFallback, metrics.ResultPassed)
+		return func() {}, nil
+	}
+	if resp := future.Get(); resp != nil && resp.Code == model.QuotaResultLimited {
+		metrics.ReportRateLimit(namespace, service, method, side, metrics.QuotaSourcePolaris, metrics.ResultLimited)
+		return nil, l.limitedError(side, resp.Info)
+	}
+	metrics.ReportRateLimit(namespace, service, method, side, metrics.QuotaSourcePolaris, metrics.ResultPassed)
+	return future.Release, nil
+}
+
+// limitedError returns the error of the limited request.
+func (l *Limiter) limitedError(side, info string) error {
+	code := int(errs.RetServerThrottled)
+	if side == sideClient {
+		code = int(errs.RetClientThrottled)
+	}
+	if l.cfg.ErrorCode != 0 {
+		code = l.cfg.ErrorCode
+	}
+	return errs.NewFrameError(code, "polaris rate limited: "+info)
+}
@@ -0,0 +1,217 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/client"
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/errs"
+	"trpc.group/trpc-go/trpc-go/filter"
+	"trpc.group/trpc-go/trpc-go/plugin"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_api"
+
+	"github.com/golang/mock/gomock"
+	"github.com/polarismesh/polaris-go/api"
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+type fakeFuture struct {
+	resp     *model.QuotaResponse
+	released int
+}
+
+func (f *fakeFuture) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+func (f *fakeFuture) Get() *model.QuotaResponse {
+	return f.resp
+}
+
+func (f *fakeFuture) GetImmediately() *model.QuotaResponse {
+	return f.resp
+}
+
+func (f *fakeFuture) Release() {
+	f.released++
+}
+
+func newMessage(md codec.MetaData) context.Context {
+	ctx, msg := codec.WithNewMessage(context.Background())
+	msg.WithNamespace("Production")
+	msg.WithCalleeServiceName("trpc.app.server.Service")
+	msg.WithCalleeMethod("/trpc.app.server.Service/Hello")
+	msg.WithServerMetaData(md)
+	msg.WithClientMetaData(md)
+	return ctx
+}
+
+// newClientContext returns the context passed to the client filters,
+// which carries the message and the client options.
+func newClientContext(t *testing.T, opts ...client.Option) context.Context {
+	var filterCtx context.Context
+	capture := func(ctx context.Context, req, rsp interface{}, next filter.ClientHandleFunc) error {
+		filterCtx = ctx
+		return nil
+	}
+	ctx := newMessage(nil)
+	opts = append(opts, client.WithTarget("ip://127.0.0.1:8000"), client.WithFilter(capture))
+	require.Nil(t, client.New().Invoke(ctx, nil, nil, opts...))
+	return filterCtx
+}
+
+func TestServerFilter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	future := &fakeFuture{resp: &model.QuotaResponse{Code: model.QuotaResultOk}}
+	limitAPI := mock_api.NewMockLimitAPI(ctrl)
+	limitAPI.EXPECT().GetQuota(gomock.Any()).DoAndReturn(func(req api.QuotaRequest) (api.QuotaFuture, error) {
+		r := req.(*model.QuotaRequestImpl)
+		assert.Equal(t, "Production", r.GetNamespace())
+		assert.Equal(t, "trpc.app.server.Service", r.GetService())
+		assert.Equal(t, "/trpc.app.server.Service/Hello", r.GetMethod())
+		assert.Equal(t, []model.Argument{model.BuildCustomArgument("uid", "1")}, r.Arguments())
+		assert.Equal(t, 50*time.Millisecond, *r.GetTimeoutPtr())
+		return future, nil
+	}).Times(2)
+	l := New(limitAPI, &Config{Labels: []string{"uid", "app"}, Timeout: 50 * time.Millisecond})
+
+	ctx := newMessage(codec.MetaData{"uid": []byte("1")})
+	rsp, err := l.ServerFilter(ctx, "req", func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "rsp", nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, "rsp", rsp)
+	assert.Equal(t, 1, future.released)
+
+	future.resp = &model.QuotaResponse{Code: model.QuotaResultLimited, Info: "rule1"}
+	_, err = l.ServerFilter(ctx, "req", func(ctx context.Context, req interface{}) (interface{}, error) {
+		t.Fatal("limited request is handled")
+		return nil, nil
+	})
+	assert.Equal(t, errs.RetServerThrottled, errs.Code(err))
+	assert.Contains(t, errs.Msg(err), "rule1")
+	assert.Equal(t, 1, future.released)
+}
+
+func TestClientFilter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	limitAPI := mock_api.NewMockLimitAPI(ctrl)
+	limitAPI.EXPECT().GetQuota(gomock.Any()).DoAndReturn(func(req api.QuotaRequest) (api.QuotaFuture, error) {
+		assert.Equal(t, "Development", req.(*model.QuotaRequestImpl).GetNamespace())
+		return &fakeFuture{resp: &model.QuotaResponse{Code: model.QuotaResultLimited}}, nil
+	})
+	limitAPI.EXPECT().GetQuota(gomock.Any()).Return(nil, errors.New("unreachable")).Times(3)
+	l := New(limitAPI, &Config{ErrorCode: 1000, Fallback: FallbackConfig{QPS: 0.001, Burst: 2}})
+
+	next := func(ctx context.Context, req, rsp interface{}) error { return nil }
+	ctx := newClientContext(t, client.WithNamespace("Development"))
+	assert.Equal(t, "Production", codec.Message(ctx).Namespace(), "the message carries the caller namespace")
+	err := l.ClientFilter(ctx, nil, nil, next)
+	assert.Equal(t, 1000, int(errs.Code(err)))
+	assert.Nil(t, l.ClientFilter(ctx, nil, nil, next), "fall back to the local limiter")
+	assert.Nil(t, l.ClientFilter(ctx, nil, nil, next))
+	err = l.ClientFilter(ctx, nil, nil, next)
+	assert.Equal(t, 1000, int(errs.Code(err)))
+	assert.Contains(t, errs.Msg(err), "local fallback limiter")
+
+	l = New(limitAPI, &Config{})
+	assert.Equal(t, errs.RetClientThrottled, errs.Code(l.limitedError(sideClient, "")))
+
+	limitAPI = mock_api.NewMockLimitAPI(ctrl)
+	limitAPI.EXPECT().GetQuota(gomock.Any()).DoAndReturn(func(req api.QuotaRequest) (api.QuotaFuture, error) {
+		assert.Equal(t, "Test", req.(*model.QuotaRequestImpl).GetNamespace(), "the config overrides the namespace")
+		return &fakeFuture{resp: &model.QuotaResponse{Code: model.QuotaResultOk}}, nil
+	})
+	l = New(limitAPI, &Config{Namespace: "Test"})
+	assert.Nil(t, l.ClientFilter(ctx, nil, nil, next))
+	assert.Empty(t, calleeNamespace(context.Background()))
+}
+
+func TestLocalLimiter(t *testing.T) {
+	now := time.Now()
+	l := newLocalLimiter(FallbackConfig{})
+	assert.True(t, l.allow("a", now), "all requests are allowed without qps")
+
+	l = newLocalLimiter(FallbackConfig{QPS: 2})
+	assert.True(t, l.allow("a", now))
+	assert.True(t, l.allow("a", now))
+	assert.False(t, l.allow("a", now), "burst defaults to qps")
+	assert.True(t, l.allow("b", now), "keys are limited separately")
+	assert.True(t, l.allow("a", now.Add(500*time.Millisecond)))
+	assert.False(t, l.allow("a", now.Add(500*time.Millisecond)))
+	assert.True(t, l.allow("a", now.Add(10*time.Second)))
+	assert.True(t, l.allow("a", now.Add(10*time.Second)))
+	assert.False(t, l.allow("a", now.Add(10*time.Second)), "tokens are capped by burst")
+}
+
+type fakeSelectorFactory struct {
+	sdkCtx api.SDKContext
+}
+
+func (f *fakeSelectorFactory) Type() string {
+	return "selector"
+}
+
+func (f *fakeSelectorFactory) Setup(string, plugin.Decoder) error {
+	return nil
+}
+
+func (f *fakeSelectorFactory) GetSDKCtx() api.SDKContext {
+	return f.sdkCtx
+}
+
+func TestFactorySetup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	f := &Factory{}
+	assert.Equal(t, "ratelimit", f.Type())
+	assert.Equal(t, []string{"selector-polarismesh"}, f.FlexDependsOn())
+	assert.NotNil(t, f.Setup(pluginName, nil))
+
+	decode := func(cfg string) *yaml.Node {
+		var node yaml.Node
+		require.Nil(t, yaml.Unmarshal([]byte(cfg), &node))
+		return &node
+	}
+	assert.ErrorContains(t, f.Setup(pluginName, decode(`
+strict: true
+timeout: -1s
+fallback:
+  qbs: 100
+`)), "invalid ratelimit config: 2 config problem(s): unknown key fallback.qbs (line 5); timeout must not be negative")
+	assert.ErrorContains(t, f.Setup(pluginName, decode(`selector: not.setup`)), "selector not.setup is not set up")
+
+	plugin.Register("polarismesh-ratelimit", &fakeSelectorFactory{sdkCtx: mock_api.NewMockSDKContext(ctrl)})
+	require.Nil(t, f.Setup(pluginName, decode(`
+selector: polarismesh-ratelimit
+labels: [uid]
+`)))
+	assert.NotNil(t, filter.GetServer(FilterName))
+	assert.NotNil(t, filter.GetClient(FilterName))
+}
@@ -0,0 +1,108 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package registry
+
+import (
+	"fmt"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/naming/registry"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/cluster"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/validate"
+
+	"github.com/polarismesh/polaris-go/api"
+)
+
+// Cluster is a polaris mesh cluster which the services are registered in as well,
+// the fields not set are inherited from the top level config.
+type Cluster struct {
+	Name           string         `yaml:"name"`
+	AddressList    string         `yaml:"address_list"`
+	Protocol       string         `yaml:"protocol"`
+	ConnectTimeout int            `yaml:"connect_timeout"`
+	MessageTimeout *time.Duration `yaml:"message_timeout"`
+}
+
+// clusterConfig returns the config to connect to the cluster cl.
+func (c *FactoryConfig) clusterConfig(cl *Cluster) *FactoryConfig {
+	cc := *c
+	cc.AddressList = cl.AddressList
+	if cl.Protocol != "" {
+		cc.Protocol = cl.Protocol
+	}
+	if cl.ConnectTimeout != 0 {
+		cc.ConnectTimeout = cl.ConnectTimeout
+	}
+	if cl.MessageTimeout != nil {
+		cc.MessageTimeout = cl.MessageTimeout
+	}
+	return &cc
+}
+
+func (c *FactoryConfig) validateClusters(p *validate.Problems) {
+	clusters := map[string]bool{cluster.Default: true}
+	for i, cl := range c.Clusters {
+		field := fmt.Sprintf("clusters.%d", i)
+		switch {
+		case cl.Name == "":
+			p.Addf("%s.name must not be empty", field)
+		case clusters[cl.Name]:
+			p.Addf("%s.name %s is duplicated or reserved", field, cl.Name)
+		}
+		clusters[cl.Name] = true
+		if cl.AddressList == "" {
+			p.Addf("%s.address_list must not be empty", field)
+		}
+		p.AddressList(field+".address_list", cl.AddressList)
+		p.OneOf(field+".protocol", cl.Protocol, "", defaultProtocol)
+		p.NonNegative(field+".connect_timeout", cl.ConnectTimeout)
+		p.NonNegativeDuration(field+".message_timeout", cl.MessageTimeout)
+	}
+}
+
+// clusterProvider is the provider of the cluster named name.
+type clusterProvider struct {
+	name     string
+	provider api.ProviderAPI
+}
+
+// multiRegistry registers the service in all clusters.
+type multiRegistry struct {
+	clusters   []string
+	registries []*Registry
+}
+
+// Register registers the service in all clusters, it goes on with the others if any cluster fails,
+// and returns the first error.
+func (m *multiRegistry) Register(service string, opt ...registry.Option) error {
+	var firstErr error
+	for i, r := range m.registries {
+		if err := r.Register(service, opt...); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("register in cluster %s err: %w", m.clusters[i], err)
+		}
+	}
+	return firstErr
+}
+
+// Deregister deregisters the service from all clusters, it goes on with the others if any cluster fails,
+// and returns the first error.
+func (m *multiRegistry) Deregister(service string) error {
+	var firstErr error
+	for i, r := range m.registries {
+		if err := r.Deregister(service); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("deregister from cluster %s err: %w", m.clusters[i], err)
+		}
+	}
+	return firstErr
+}
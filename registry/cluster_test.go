@@ -0,0 +1,76 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package registry
+
+import (
+	"errors"
+	"testing"
+
+	"trpc.group/trpc-go/trpc-go/naming/registry"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/cluster"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_api"
+
+	"github.com/golang/mock/gomock"
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterClusters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	oldProvider := mock_api.NewMockProviderAPI(ctrl)
+	oldProvider.EXPECT().Register(gomock.Any()).Return(&model.InstanceRegisterResponse{InstanceID: "old"}, nil)
+	oldProvider.EXPECT().Heartbeat(gomock.Any()).Return(nil).AnyTimes()
+	oldProvider.EXPECT().Deregister(gomock.Any()).Return(errors.New("unreachable"))
+	newProvider := mock_api.NewMockProviderAPI(ctrl)
+	newProvider.EXPECT().Register(gomock.Any()).Return(&model.InstanceRegisterResponse{InstanceID: "new"}, nil)
+	newProvider.EXPECT().Heartbeat(gomock.Any()).Return(nil).AnyTimes()
+	newProvider.EXPECT().Deregister(gomock.Any()).Return(nil)
+
+	const service = "trpc.test.cluster.Service"
+	require.Nil(t, registerClusters([]clusterProvider{
+		{name: cluster.Default, provider: oldProvider},
+		{name: "new", provider: newProvider},
+	}, &FactoryConfig{
+		EnableRegister: true,
+		Services:       []Service{{ServiceName: service, Namespace: "Development"}},
+	}))
+	m, ok := registry.Get(service).(*multiRegistry)
+	require.True(t, ok)
+	require.Nil(t, m.Register(service, registry.WithAddress("127.0.0.1:8080")))
+	assert.Equal(t, "old", m.registries[0].cfg.InstanceID)
+	assert.Equal(t, "new", m.registries[1].cfg.InstanceID, "the configs are not shared")
+	assert.EqualError(t, m.Deregister(service), "deregister from cluster default err: deregister error: unreachable")
+}
+
+func TestFactoryConfig_clusterConfig(t *testing.T) {
+	conf := &FactoryConfig{
+		Protocol:       defaultProtocol,
+		AddressList:    "127.0.0.1:0",
+		ConnectTimeout: 1000,
+		Clusters:       []Cluster{{Name: "new", AddressList: "127.0.0.2:0", ConnectTimeout: 500}},
+	}
+	cc := conf.clusterConfig(&conf.Clusters[0])
+	assert.Equal(t, "127.0.0.2:0", cc.AddressList)
+	assert.Equal(t, defaultProtocol, cc.Protocol)
+	assert.Equal(t, 500, cc.ConnectTimeout)
+	assert.Equal(t, "127.0.0.1:0", conf.AddressList)
+
+	conf.Clusters = append(conf.Clusters, Cluster{Name: "new"}, Cluster{AddressList: "127.0.0.3"})
+	assert.EqualError(t, conf.Validate(), "4 config problem(s): clusters.1.name new is duplicated or reserved; "+
+		"clusters.1.address_list must not be empty; clusters.2.name must not be empty; "+
+		`clusters.2.address_list must be host:port separated by commas, got "127.0.0.3"`)
+}
@@ -15,6 +15,7 @@
 package registry
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os"
@@ -24,6 +25,7 @@ import (
 	"trpc.group/trpc-go/trpc-go/healthcheck"
 	"trpc.group/trpc-go/trpc-go/naming/registry"
 	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/metrics"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/tracing"
 
 	"github.com/polarismesh/polaris-go/api"
 	plog "github.com/polarismesh/polaris-go/pkg/log"
@@ -125,7 +127,9 @@ func (r *Registry) register() error {
 	if !r.cfg.DisableHealthCheck {
 		req.SetTTL(r.cfg.TTL)
 	}
+	start := time.Now()
 	resp, err := r.Provider.Register(req)
+	metrics.ReportRegistry(r.cfg.Namespace, r.cfg.ServiceName, metrics.OperationRegister, time.Since(start), err)
 	if err != nil {
 		return fmt.Errorf("fail to Register instance, err is %v", err)
 	}
@@ -186,7 +190,15 @@ func (r *Registry) heartBeat() {
 			Port:         r.port,
 		},
 	}
-	if err := r.Provider.Heartbeat(heartBeatRequest); err != nil {
+	_, span := tracing.Start(context.Background(), tracing.SpanHeartbeat)
+	span.SetAttribute(tracing.AttrService, r.cfg.ServiceName)
+	span.SetAttribute(tracing.AttrNamespace, r.cfg.Namespace)
+	span.SetAttribute(tracing.AttrAddress, net.JoinHostPort(r.host, strconv.Itoa(r.port)))
+	start := time.Now()
+	err := r.Provider.Heartbeat(heartBeatRequest)
+	metrics.ReportRegistry(r.cfg.Namespace, r.cfg.ServiceName, metrics.OperationHeartbeat, time.Since(start), err)
+	span.End(err)
+	if err != nil {
 		plog.GetBaseLogger().Errorf("heartbeat report err: %v\n", err)
 		metrics.ReportHeartBeatFail(heartBeatRequest)
 	} else {
@@ -209,7 +221,10 @@ func (r *Registry) Deregister(_ string) error {
 			Port:         r.port,
 		},
 	}
-	if err := r.Provider.Deregister(req); err != nil {
+	start := time.Now()
+	err := r.Provider.Deregister(req)
+	metrics.ReportRegistry(r.cfg.Namespace, r.cfg.ServiceName, metrics.OperationDeregister, time.Since(start), err)
+	if err != nil {
 		return fmt.Errorf("deregister error: %s", err.Error())
 	}
 	return nil
@@ -22,11 +22,18 @@ import (
 	"trpc.group/trpc-go/trpc-go/log"
 	"trpc.group/trpc-go/trpc-go/naming/registry"
 	"trpc.group/trpc-go/trpc-go/plugin"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/cluster"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/connector"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/expand"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/location"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/metrics"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/validate"
 
 	"github.com/polarismesh/polaris-go/api"
 	"github.com/polarismesh/polaris-go/pkg/config"
 	plog "github.com/polarismesh/polaris-go/pkg/log"
 	"github.com/polarismesh/polaris-go/pkg/model"
+	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -35,7 +42,15 @@ const (
 	defaultProtocol       = "grpc"
 )
 
+// TLSConfig is the TLS config to connect to the polaris mesh servers.
+type TLSConfig = connector.TLSConfig
+
+// LocationProviderConfig is the config of a provider to detect the location of the instances.
+type LocationProviderConfig = location.ProviderConfig
+
 // FactoryConfig is factory configuration.
+// The environment variables like ${ENV} are expanded in the addresses, tokens, instance ids, metadata and location,
+// and those values like file://path are loaded from the files.
 type FactoryConfig struct {
 	EnableRegister     bool            `yaml:"register_self"`
 	Protocol           string          `yaml:"protocol"`
@@ -48,6 +63,97 @@ type FactoryConfig struct {
 	MessageTimeout     *time.Duration  `yaml:"message_timeout"`
 	DisableHealthCheck bool            `yaml:"disable_health_check"`
 	InstanceLocation   *model.Location `yaml:"instance_location"`
+	// LocationProviders detect the location of the instances in order, falling back to InstanceLocation
+	// and then the location of the selector.
+	LocationProviders []LocationProviderConfig `yaml:"location_providers"`
+	// Metrics enables or disables the metric families by name, all of them are enabled by default.
+	Metrics map[string]bool `yaml:"metrics"`
+	// Strict rejects the unknown keys of the config.
+	Strict bool `yaml:"strict"`
+	// Clusters are the polaris mesh clusters which the services are registered in
+	// besides the one configured by the top level fields.
+	Clusters []Cluster `yaml:"clusters"`
+	// TLS enables TLS on the connections to the polaris mesh servers.
+	TLS *TLSConfig `yaml:"tls"`
+	// AuthToken is the access token to the polaris mesh servers.
+	AuthToken string `yaml:"auth_token"`
+
+	// unknownKeys are the unknown keys found in strict mode.
+	unknownKeys []string
+}
+
+// UnmarshalYAML records the unknown keys if strict is enabled.
+func (c *FactoryConfig) UnmarshalYAML(value *yaml.Node) error {
+	type plain FactoryConfig
+	if err := value.Decode((*plain)(c)); err != nil {
+		return err
+	}
+	if c.Strict {
+		c.unknownKeys = validate.UnknownKeys(value, c)
+	}
+	if err := c.expand(); err != nil {
+		return fmt.Errorf("expand registry config err: %w", err)
+	}
+	return nil
+}
+
+// expand expands the environment variables and loads the file secrets of the config values.
+func (c *FactoryConfig) expand() error {
+	var e expand.Expander
+	e.String("address_list", &c.AddressList)
+	e.String("auth_token", &c.AuthToken)
+	e.Location("instance_location", c.InstanceLocation)
+	for i := range c.Services {
+		s := &c.Services[i]
+		field := fmt.Sprintf("service.%d", i)
+		e.String(field+".token", &s.Token)
+		e.String(field+".instance_id", &s.InstanceID)
+		e.Map(field+".metadata", s.MetaData)
+	}
+	for i := range c.Clusters {
+		e.String(fmt.Sprintf("clusters.%d.address_list", i), &c.Clusters[i].AddressList)
+	}
+	return e.Err()
+}
+
+// Validate checks the config, and returns all problems found at once.
+// The unknown keys are reported as problems if the config is decoded from yaml with strict enabled.
+func (c *FactoryConfig) Validate() error {
+	var p validate.Problems
+	for _, key := range c.unknownKeys {
+		p.Addf("unknown key %s", key)
+	}
+	p.OneOf("protocol", c.Protocol, "", defaultProtocol)
+	p.AddressList("address_list", c.AddressList)
+	p.NonNegative("heartbeat_interval", c.HeartbeatInterval)
+	p.NonNegative("connect_timeout", c.ConnectTimeout)
+	p.NonNegativeDuration("message_timeout", c.MessageTimeout)
+	p.Add("metrics", metrics.Validate(c.Metrics))
+	if c.TLS != nil {
+		p.Add("tls", c.TLS.Validate())
+	}
+	if (c.TLS != nil || c.AuthToken != "") && c.ClusterService != (ClusterService{}) {
+		p.Addf("cluster_service must not be used with tls or auth_token, " +
+			"as polaris-go discovers the system services by the grpc protocol only")
+	}
+	location.Validate(&p, "location_providers", c.LocationProviders)
+	for i, s := range c.Services {
+		field := fmt.Sprintf("service.%d", i)
+		if s.ServiceName == "" {
+			p.Addf("%s.name must not be empty", field)
+		}
+		if s.Namespace == "" {
+			p.Addf("%s.namespace must not be empty", field)
+		}
+		if !c.EnableRegister && s.InstanceID == "" {
+			p.Addf("%s.instance_id must not be empty if register_self is false", field)
+		}
+		if s.Weight != nil {
+			p.NonNegative(field+".weight", *s.Weight)
+		}
+	}
+	c.validateClusters(&p)
+	return p.Err()
 }
 
 // ClusterService is cluster service.
@@ -92,16 +198,50 @@ func (f *RegistryFactory) Setup(name string, configDec plugin.Decoder) error {
 	if err := configDec.Decode(conf); err != nil {
 		return err
 	}
+	if err := conf.Validate(); err != nil {
+		return fmt.Errorf("invalid registry config: %w", err)
+	}
 	if conf.Debug {
 		log.Debug("set polaris mesh log level debug")
 		plog.GetBaseLogger().SetLogLevel(plog.DebugLog)
 	}
+	if err := metrics.Configure(conf.Metrics); err != nil {
+		return fmt.Errorf("invalid metrics config: %w", err)
+	}
+	conf.InstanceLocation = instanceLocation(conf)
 	sdkCtx, err := newSDKCtx(conf)
 	if err != nil {
 		return fmt.Errorf("create new provider failed: err %w", err)
 	}
 	f.sdkCtx = sdkCtx
-	return register(api.NewProviderAPIByContext(sdkCtx), conf)
+	providers := []clusterProvider{{name: cluster.Default, provider: api.NewProviderAPIByContext(sdkCtx)}}
+	for i := range conf.Clusters {
+		cl := &conf.Clusters[i]
+		ctx, err := newSDKCtx(conf.clusterConfig(cl))
+		if err != nil {
+			return fmt.Errorf("create new provider of cluster %s failed: err %w", cl.Name, err)
+		}
+		providers = append(providers, clusterProvider{name: cl.Name, provider: api.NewProviderAPIByContext(ctx)})
+	}
+	return registerClusters(providers, conf)
+}
+
+// instanceLocation returns the location of the instances detected by the providers or configured,
+// or else the location of the selector, which is set up before, so that the same location is used by both.
+func instanceLocation(conf *FactoryConfig) *model.Location {
+	if loc := location.Resolve("registry", conf.InstanceLocation, conf.LocationProviders); loc != nil {
+		return loc
+	}
+	f, ok := plugin.Get("selector", "polarismesh").(interface{ GetSDKCtx() api.SDKContext })
+	if !ok || f.GetSDKCtx() == nil {
+		return nil
+	}
+	loc := f.GetSDKCtx().GetValueContext().GetCurrentLocation().GetLocation()
+	if loc == nil || *loc == (model.Location{}) {
+		return nil
+	}
+	log.Infof("[NAMING-POLARISMESH] registry uses the location %+v of selector polarismesh", *loc)
+	return loc
 }
 
 // FlexDependsOn makes sure that register is initialized after selector,
@@ -148,31 +288,54 @@ func newSDKCtx(cfg *FactoryConfig) (api.SDKContext, error) {
 		messageTimeout = *cfg.MessageTimeout
 	}
 	c.GetGlobal().GetServerConnector().SetMessageTimeout(messageTimeout)
+	if err := connector.Set(c, cfg.TLS, cfg.AuthToken); err != nil {
+		return nil, fmt.Errorf("set security config err: %w", err)
+	}
 	return api.InitContextByConfig(c)
 }
 
 func register(provider api.ProviderAPI, conf *FactoryConfig) error {
+	return registerClusters([]clusterProvider{{name: cluster.Default, provider: provider}}, conf)
+}
+
+// registerClusters registers the registry of each service, which registers the service in all clusters
+// if there are more than one.
+func registerClusters(providers []clusterProvider, conf *FactoryConfig) error {
 	for _, service := range conf.Services {
-		cfg := &Config{
-			Protocol:           conf.Protocol,
-			EnableRegister:     conf.EnableRegister,
-			HeartBeat:          conf.HeartbeatInterval / 1000,
-			ServiceName:        service.ServiceName,
-			Namespace:          service.Namespace,
-			ServiceToken:       service.Token,
-			InstanceID:         service.InstanceID,
-			Metadata:           service.MetaData,
-			BindAddress:        service.BindAddress,
-			PreferBindAddress:  service.PreferBindAddress,
-			Weight:             service.Weight,
-			DisableHealthCheck: conf.DisableHealthCheck,
-			InstanceLocation:   conf.InstanceLocation,
+		m := &multiRegistry{}
+		for _, p := range providers {
+			reg, err := newRegistry(p.provider, serviceConfig(conf, service))
+			if err != nil {
+				return fmt.Errorf("create new registry for service %s in cluster %s failed: err %w",
+					service.ServiceName, p.name, err)
+			}
+			m.clusters = append(m.clusters, p.name)
+			m.registries = append(m.registries, reg)
 		}
-		reg, err := newRegistry(provider, cfg)
-		if err != nil {
-			return fmt.Errorf("create new registry for service %s failed: err %w", service.ServiceName, err)
+		if len(m.registries) == 1 {
+			registry.Register(service.ServiceName, m.registries[0])
+		} else {
+			registry.Register(service.ServiceName, m)
 		}
-		registry.Register(service.ServiceName, reg)
 	}
 	return nil
 }
+
+// serviceConfig returns the config of the registry of the service.
+func serviceConfig(conf *FactoryConfig, service Service) *Config {
+	return &Config{
+		Protocol:           conf.Protocol,
+		EnableRegister:     conf.EnableRegister,
+		HeartBeat:          conf.HeartbeatInterval / 1000,
+		ServiceName:        service.ServiceName,
+		Namespace:          service.Namespace,
+		ServiceToken:       service.Token,
+		InstanceID:         service.InstanceID,
+		Metadata:           service.MetaData,
+		BindAddress:        service.BindAddress,
+		PreferBindAddress:  service.PreferBindAddress,
+		Weight:             service.Weight,
+		DisableHealthCheck: conf.DisableHealthCheck,
+		InstanceLocation:   conf.InstanceLocation,
+	}
+}
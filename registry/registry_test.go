@@ -15,6 +15,7 @@ package registry
 
 import (
 	"fmt"
+	"os"
 	"testing"
 	"time"
 
@@ -64,7 +65,7 @@ plugins:
       message_timeout: 1s
       join_point: default
       disable_health_check: true
-      address_list: "not_exist"
+      address_list: "127.0.0.1:0"
 `
 	cfg := trpc.Config{}
 	err := yaml.Unmarshal([]byte(cfgstr), &cfg)
@@ -136,7 +137,7 @@ instance_location:
   region: %s
   zone: %s
   campus: %s
-address_list: "not_exist"
+address_list: "127.0.0.1:0"
 service:
   - name: %s
     token: "xxx"
@@ -218,7 +219,7 @@ func TestRegistryZeroWeight(t *testing.T) {
 register_self: true
 heartbeat_interval: 3000
 protocol: grpc
-address_list: "not_exist"
+address_list: "127.0.0.1:0"
 service:                         
   - name: %s
     namespace: Development       
@@ -241,3 +242,114 @@ func (p *provider) Heartbeat(*api.InstanceHeartbeatRequest) error {
 	p.heartbeat <- struct{}{}
 	return nil
 }
+
+func TestConfigValidate(t *testing.T) {
+	cfgstr := `
+plugins:
+  registry:
+    polarismesh:
+      strict: true
+      protocol: http
+      address_list: 127.0.0.1
+      heartbeat_interval: -1
+      message_timeout: -1s
+      join_point: default
+      tls:
+        key_file: client.key
+      cluster_service:
+        monitor: polaris.monitor
+      service:
+        - name: ""
+          namespace: Development
+          weight: -1
+`
+	cfg := trpc.Config{}
+	require.Nil(t, yaml.Unmarshal([]byte(cfgstr), &cfg))
+	polarisCfg := cfg.Plugins["registry"]["polarismesh"]
+	err := (&RegistryFactory{}).Setup("polarismesh", &polarisCfg)
+	require.NotNil(t, err)
+	require.Equal(t, "invalid registry config: 10 config problem(s): "+
+		"unknown key join_point (line 10); "+
+		`protocol must be one of "", "grpc", got "http"; `+
+		`address_list must be host:port separated by commas, got "127.0.0.1"; `+
+		"heartbeat_interval must not be negative, got -1; "+
+		"message_timeout must not be negative, got -1s; "+
+		"tls: cert_file and key_file must be set together; "+
+		"cluster_service must not be used with tls or auth_token, "+
+		"as polaris-go discovers the system services by the grpc protocol only; "+
+		"service.0.name must not be empty; "+
+		"service.0.instance_id must not be empty if register_self is false; "+
+		"service.0.weight must not be negative, got -1", err.Error())
+}
+
+func TestConfigExpand(t *testing.T) {
+	dir := t.TempDir()
+	require.Nil(t, os.WriteFile(dir+"/token", []byte("secret\n"), 0600))
+	t.Setenv("POLARIS_DIR", dir)
+	t.Setenv("POLARIS_ADDRESS", "127.0.0.1:8091")
+	t.Setenv("POD_NAME", "pod-1")
+	t.Setenv("NODE_ZONE", "Guangdong")
+	cfg := FactoryConfig{}
+	require.Nil(t, yaml.Unmarshal([]byte(`
+address_list: ${POLARIS_ADDRESS}
+instance_location:
+  zone: ${NODE_ZONE}
+service:
+  - name: trpc.app.server.service
+    namespace: Production
+    token: file://${POLARIS_DIR}/token
+    instance_id: ${POD_NAME}
+    metadata:
+      pod: ${POD_NAME}
+      version: v1
+`), &cfg))
+	assert.Equal(t, "127.0.0.1:8091", cfg.AddressList)
+	assert.Equal(t, &model.Location{Zone: "Guangdong"}, cfg.InstanceLocation)
+	s := cfg.Services[0]
+	assert.Equal(t, "secret", s.Token)
+	assert.Equal(t, "pod-1", s.InstanceID)
+	assert.Equal(t, map[string]string{"pod": "pod-1", "version": "v1"}, s.MetaData)
+
+	err := yaml.Unmarshal([]byte(`
+service:
+  - instance_id: file://not_exist
+`), &FactoryConfig{})
+	assert.ErrorContains(t, err, "expand registry config err: 1 config problem(s): service.0.instance_id: read file not_exist")
+}
+
+type fakeSelectorFactory struct {
+	sdkCtx api.SDKContext
+}
+
+func (f *fakeSelectorFactory) Type() string {
+	return "selector"
+}
+
+func (f *fakeSelectorFactory) Setup(string, plugin.Decoder) error {
+	return nil
+}
+
+func (f *fakeSelectorFactory) GetSDKCtx() api.SDKContext {
+	return f.sdkCtx
+}
+
+func TestInstanceLocation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	configured := &model.Location{Region: "China", Zone: "Guangdong"}
+	assert.Equal(t, configured, instanceLocation(&FactoryConfig{InstanceLocation: configured}))
+	assert.Equal(t, &model.Location{Region: "China", Zone: "Beijing"}, instanceLocation(&FactoryConfig{
+		InstanceLocation:  configured,
+		LocationProviders: []LocationProviderConfig{{Type: "static", Region: "China", Zone: "Beijing"}},
+	}))
+
+	valueCtx := model.NewValueContext()
+	sdkCtx := mock_api.NewMockSDKContext(ctrl)
+	sdkCtx.EXPECT().GetValueContext().Return(valueCtx).AnyTimes()
+	plugin.Register("polarismesh", &fakeSelectorFactory{sdkCtx: sdkCtx})
+	defer plugin.Register("polarismesh", &fakeSelectorFactory{})
+	assert.Nil(t, instanceLocation(&FactoryConfig{}), "the location of selector is not ready")
+	valueCtx.SetCurrentLocation(&model.Location{Region: "China", Zone: "Shanghai"}, nil)
+	assert.Equal(t, &model.Location{Region: "China", Zone: "Shanghai"}, instanceLocation(&FactoryConfig{}))
+}
@@ -0,0 +1,241 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package naming
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	trpcconfig "trpc.group/trpc-go/trpc-go/config"
+	"trpc.group/trpc-go/trpc-go/log"
+	tcb "trpc.group/trpc-go/trpc-go/naming/circuitbreaker"
+	tselector "trpc.group/trpc-go/trpc-go/naming/selector"
+	tsr "trpc.group/trpc-go/trpc-go/naming/servicerouter"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/circuitbreaker"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/metrics"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/selector"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/servicerouter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// reloadableFields are the yaml paths of the fields applied by Reload, including their sub fields.
+// The other fields are bound to the polaris SDK context or the components at setup.
+var reloadableFields = map[string]bool{
+	"enable_servicerouter":                 true,
+	"enable_canary":                        true,
+	"canary":                               true,
+	"enable_trans_meta":                    true,
+	"metrics":                              true,
+	"service_router.need_return_all_nodes": true,
+	"service_router.metadata_keys":         true,
+	"service_router.metadata_routes":       true,
+	"service_router.failover":              true,
+	"service_router.enable_set_fallback":   true,
+	"circuitbreaker.levels":                true,
+}
+
+const defaultSelectorName = "polarismesh"
+
+var applied = struct {
+	mu sync.Mutex
+	m  map[string]*Config // selector name -> config applied.
+}{m: make(map[string]*Config)}
+
+func selectorName(conf *Config) string {
+	if conf.Name == "" {
+		return defaultSelectorName
+	}
+	return conf.Name
+}
+
+// setApplied records the config applied to the selector.
+func setApplied(conf *Config) {
+	applied.mu.Lock()
+	defer applied.mu.Unlock()
+	applied.m[selectorName(conf)] = conf
+}
+
+// Reload applies the config to the live selector, service router and circuit breaker named conf.Name.
+//
+// Only the fields in reloadableFields are applied, which are enable_servicerouter, enable_canary, canary,
+// enable_trans_meta, metrics, the metadata routes, the failover, the set fallback and need_return_all_nodes of
+// service_router and the levels of circuitbreaker. If any other field is changed, such as the load balancers,
+// the nearby match level or the circuit breaker thresholds which are bound to the polaris SDK context,
+// the config is rejected as a whole and nothing is changed.
+func Reload(conf *Config) error {
+	if err := conf.Validate(); err != nil {
+		return fmt.Errorf("invalid selector config: %w", err)
+	}
+	name := selectorName(conf)
+	applied.mu.Lock()
+	defer applied.mu.Unlock()
+	old, ok := applied.m[name]
+	if !ok {
+		return fmt.Errorf("selector %s is not set up", name)
+	}
+	var rejected []string
+	for _, field := range changedFields(reflect.ValueOf(*old), reflect.ValueOf(*conf), "") {
+		if !isReloadable(field) {
+			rejected = append(rejected, field)
+		}
+	}
+	if len(rejected) > 0 {
+		return fmt.Errorf("fields %s of selector %s need a restart to take effect",
+			strings.Join(rejected, ", "), name)
+	}
+
+	// With clusters, the components of each cluster are reloaded.
+	var (
+		routers   []*servicerouter.ServiceRouter
+		selectors []*selector.Selector
+		cbs       []*circuitbreaker.CircuitBreaker
+	)
+	for _, component := range conf.componentNames() {
+		router, ok := tsr.Get(component).(*servicerouter.ServiceRouter)
+		if !ok {
+			return fmt.Errorf("service router %s is not found", component)
+		}
+		s, ok := tselector.Get(component).(*selector.Selector)
+		if !ok {
+			return fmt.Errorf("selector %s is not found", component)
+		}
+		cb, ok := tcb.Get(component).(*circuitbreaker.CircuitBreaker)
+		if !ok {
+			return fmt.Errorf("circuit breaker %s is not found", component)
+		}
+		routers, selectors, cbs = append(routers, router), append(selectors, s), append(cbs, cb)
+	}
+	metadataRouter, err := servicerouter.NewMetadataRouter(
+		conf.ServiceRouter.MetadataKeys, conf.ServiceRouter.MetadataRoutes)
+	if err != nil {
+		return fmt.Errorf("new metadata router err: %w", err)
+	}
+	// The families omitted by the new config are enabled again.
+	enables := make(map[string]bool, len(old.Metrics)+len(conf.Metrics))
+	for family := range old.Metrics {
+		enables[family] = true
+	}
+	for family, enable := range conf.Metrics {
+		enables[family] = enable
+	}
+	if err := metrics.Validate(enables); err != nil {
+		return fmt.Errorf("invalid metrics config: %w", err)
+	}
+	// The levels are the same for all clusters, so either the first one fails or none does.
+	for _, cb := range cbs {
+		if err := cb.SetLevels(conf.CircuitBreaker.Levels); err != nil {
+			return fmt.Errorf("invalid circuit breaker levels: %w", err)
+		}
+	}
+	_ = metrics.Configure(enables)
+	for i := range routers {
+		routers[i].Reload(conf.serviceRouterConfig(metadataRouter))
+		selectors[i].Reload(conf.selectorConfig(metadataRouter, nil))
+	}
+	c := *conf
+	applied.m[name] = &c
+	log.Infof("[NAMING-POLARISMESH] selector %s is reloaded", name)
+	return nil
+}
+
+// WatchConfig reloads the selector named name by the config at path of the trpc-go config data provider,
+// such as the file provider. The data is the yaml config of the selector, the same as plugins.selector.polarismesh.
+// It reads and reloads the config once, and then reloads on every change.
+// The invalid or rejected changes are logged and ignored.
+func WatchConfig(name string, provider trpcconfig.DataProvider, path string) error {
+	data, err := provider.Read(path)
+	if err != nil {
+		return fmt.Errorf("read config %s err: %w", path, err)
+	}
+	if err := reloadYAML(name, data); err != nil {
+		return err
+	}
+	provider.Watch(func(p string, data []byte) {
+		if p != path {
+			return
+		}
+		if err := reloadYAML(name, data); err != nil {
+			log.Errorf("[NAMING-POLARISMESH] reload selector %s by config %s err: %v", name, path, err)
+		}
+	})
+	return nil
+}
+
+func reloadYAML(name string, data []byte) error {
+	conf := &Config{}
+	if err := yaml.Unmarshal(data, conf); err != nil {
+		return fmt.Errorf("unmarshal config err: %w", err)
+	}
+	conf.Name = name
+	return Reload(conf)
+}
+
+func isReloadable(field string) bool {
+	for path := field; path != ""; {
+		if reloadableFields[path] {
+			return true
+		}
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			return false
+		}
+		path = path[:i]
+	}
+	return false
+}
+
+// changedFields returns the yaml paths of the changed fields of the config structs a and b.
+// The nested structs are compared field by field, and the others are compared by their yaml encodings.
+func changedFields(a, b reflect.Value, prefix string) []string {
+	var fields []string
+	for i := 0; i < a.NumField(); i++ {
+		name := strings.Split(a.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		path := prefix + name
+		fa, fb := a.Field(i), b.Field(i)
+		if fa.Kind() == reflect.Struct {
+			fields = append(fields, changedFields(fa, fb, path+".")...)
+			continue
+		}
+		if !equalYAML(fa.Interface(), fb.Interface()) {
+			fields = append(fields, path)
+		}
+	}
+	return fields
+}
+
+// equalYAML compares a and b by their decoded yaml encodings, so that the yaml nodes in different styles
+// or positions are equal.
+func equalYAML(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	decode := func(v interface{}) (interface{}, error) {
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var decoded interface{}
+		err = yaml.Unmarshal(data, &decoded)
+		return decoded, err
+	}
+	da, errA := decode(a)
+	db, errB := decode(b)
+	return errA == nil && errB == nil && reflect.DeepEqual(da, db)
+}
@@ -0,0 +1,143 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package naming
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	trpcconfig "trpc.group/trpc-go/trpc-go/config"
+	tcb "trpc.group/trpc-go/trpc-go/naming/circuitbreaker"
+	tselector "trpc.group/trpc-go/trpc-go/naming/selector"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/metrics"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/selector"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const reloadBaseConfig = `
+address_list: 127.0.0.1:0
+default: false
+persistDir: /tmp/polarismesh/backup
+log_dir: /tmp/polarismesh/log
+loadbalance:
+  name:
+    - polaris_wr
+`
+
+type fakeProvider struct {
+	data     map[string][]byte
+	callback trpcconfig.ProviderCallback
+}
+
+func (p *fakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *fakeProvider) Read(path string) ([]byte, error) {
+	data, ok := p.data[path]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return data, nil
+}
+
+func (p *fakeProvider) Watch(cb trpcconfig.ProviderCallback) {
+	p.callback = cb
+}
+
+func TestReload(t *testing.T) {
+	const name = "polarismesh-reload"
+	var node yaml.Node
+	require.Nil(t, yaml.Unmarshal([]byte(reloadBaseConfig), &node))
+	require.Nil(t, (&SelectorFactory{}).Setup(name, &node))
+	s := tselector.Get(name).(*selector.Selector)
+	assert.False(t, s.GetCfg().EnableCanary)
+
+	assert.NotNil(t, Reload(&Config{Name: "not.setup"}))
+	err := reloadYAML(name, []byte(reloadBaseConfig+`
+service_router:
+  nearby_matchlevel: region
+`))
+	assert.ErrorContains(t, err, "service_router.nearby_matchlevel", "bound to the sdk context")
+	err = reloadYAML(name, []byte(reloadBaseConfig+`
+circuitbreaker:
+  levels:
+    "*": unknown
+`))
+	assert.NotNil(t, err)
+	assert.NotNil(t, reloadYAML(name, []byte(reloadBaseConfig+`
+metrics:
+  unknown: false
+`)))
+	assert.True(t, metrics.Enabled(metrics.FamilyLoadBalance))
+
+	provider := &fakeProvider{data: map[string][]byte{"selector.yaml": []byte(reloadBaseConfig + `
+enable_canary: true
+enable_trans_meta: true
+metrics:
+  loadbalance: false
+circuitbreaker:
+  levels:
+    "*": method
+`)}}
+	assert.NotNil(t, WatchConfig(name, provider, "not.found.yaml"))
+	require.Nil(t, WatchConfig(name, provider, "selector.yaml"))
+	assert.True(t, s.GetCfg().EnableCanary)
+	assert.True(t, s.GetCfg().EnableTransMeta)
+	assert.Equal(t, name, s.GetCfg().Name)
+	assert.False(t, metrics.Enabled(metrics.FamilyLoadBalance))
+	assert.NotNil(t, tcb.Get(name))
+
+	provider.callback("other.yaml", []byte(reloadBaseConfig))
+	assert.True(t, s.GetCfg().EnableCanary, "other paths are ignored")
+	provider.callback("selector.yaml", []byte(`address_list: 127.0.0.2:0`))
+	assert.True(t, s.GetCfg().EnableCanary, "rejected")
+	provider.callback("selector.yaml", []byte(reloadBaseConfig))
+	assert.False(t, s.GetCfg().EnableCanary)
+	assert.True(t, metrics.Enabled(metrics.FamilyLoadBalance), "omitted family is enabled again")
+}
+
+func TestChangedFields(t *testing.T) {
+	var a, b Config
+	require.Nil(t, yaml.Unmarshal([]byte(`
+canary:
+  percent: 10
+loadbalance:
+  details:
+    polaris_ring_hash:
+      vnodeCount: 1024
+`), &a))
+	require.Nil(t, yaml.Unmarshal([]byte(`
+canary:
+  percent: 20
+
+loadbalance:
+  details:
+    polaris_ring_hash: {vnodeCount: 1024}
+service_router:
+  enable_set_fallback: true
+`), &b))
+	fields := changedFields(reflect.ValueOf(a), reflect.ValueOf(b), "")
+	assert.Equal(t, []string{"canary.percent", "service_router.enable_set_fallback"}, fields,
+		"the yaml nodes are compared by their encodings")
+	for _, f := range fields {
+		assert.True(t, isReloadable(f))
+	}
+	assert.False(t, isReloadable("loadbalance.details"))
+	assert.False(t, isReloadable("service_router"))
+}
@@ -16,6 +16,9 @@ package selector
 import (
 	"time"
 
+	"trpc.group/trpc-go/trpc-naming-polarismesh/circuitbreaker"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/servicerouter"
+
 	"github.com/polarismesh/polaris-go/pkg/config"
 )
 
@@ -50,6 +53,16 @@ type Config struct {
 	LocalCachePersistDir string
 	// Set the local IP address.
 	BindIP string
+	// Canary configures percentage based canary routing, it takes effect only when EnableCanary is true.
+	Canary *servicerouter.CanaryConfig
+	// MetadataRouter routes requests by the metadata read from trpc message.
+	MetadataRouter *servicerouter.MetadataRouter
+	// EnableSetFallback falls back to the region level set and then to no set
+	// when the set has no available instances.
+	EnableSetFallback bool
+	// Classifier classifies the results reported by Report, it is shared with the circuit breaker.
+	// If it is nil, the results are classified by default with ReportTimeout.
+	Classifier *circuitbreaker.Classifier
 }
 
 const (
@@ -27,7 +27,10 @@ import (
 	"trpc.group/trpc-go/trpc-go/naming/registry"
 	"trpc.group/trpc-go/trpc-go/naming/selector"
 	"trpc.group/trpc-go/trpc-naming-polarismesh/circuitbreaker"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/metrics"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/loadbalance"
 	"trpc.group/trpc-go/trpc-naming-polarismesh/servicerouter"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/tracing"
 
 	"github.com/polarismesh/polaris-go/api"
 	"github.com/polarismesh/polaris-go/pkg/config"
@@ -128,7 +131,26 @@ func New(cfg *Config) (*Selector, error) {
 // Selector is route selector.
 type Selector struct {
 	consumer api.ConsumerAPI
-	cfg      *Config
+
+	mu  sync.RWMutex
+	cfg *Config
+}
+
+// config returns the current config of the selector.
+func (s *Selector) config() *Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+// Reload replaces the config of the selector atomically, the requests in flight may still use the old one.
+// The name and the classifier are bound at setup, so Name and Classifier of cfg are ignored.
+func (s *Selector) Reload(cfg *Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := *cfg
+	c.Name, c.Classifier = s.cfg.Name, s.cfg.Classifier
+	s.cfg = &c
 }
 
 func getMetadata(opts *selector.Options, enableTransMeta bool) map[string]string {
@@ -185,19 +207,69 @@ func getDestMetadata(opts *selector.Options) map[string]string {
 	return destMeta
 }
 
+// matchMetadata returns the metadata to match with the local metadata routes.
+func matchMetadata(opts *selector.Options, requestMeta map[string]string) map[string]string {
+	metadata := make(map[string]string, len(opts.SourceMetadata)+len(requestMeta))
+	for key, value := range opts.SourceMetadata {
+		metadata[key] = value
+	}
+	for key, value := range requestMeta {
+		metadata[key] = value
+	}
+	return metadata
+}
+
 // Select selects service node.
 func (s *Selector) Select(serviceName string, opt ...selector.Option) (*registry.Node, error) {
+	cfg := s.config()
 	opts := &selector.Options{}
 	for _, o := range opt {
 		o(opts)
 	}
 	log.Tracef("[NAMING-POLARISMESH] select options: %+v", opts)
 
+	ctx, span := tracing.Start(opts.Ctx, tracing.SpanSelect)
+	if opts.Ctx != nil {
+		// The spans started by the routing and the load balancing nest under the select span.
+		opts.Ctx = ctx
+	}
+	span.SetAttribute(tracing.AttrService, serviceName)
+	span.SetAttribute(tracing.AttrNamespace, opts.Namespace)
+	if opts.DestinationEnvName != "" {
+		span.SetAttribute(tracing.AttrEnv, opts.DestinationEnvName)
+	}
+	start := time.Now()
+	node, err := s.selectNode(cfg, serviceName, opts)
+	metrics.ReportSelect(opts.Namespace, serviceName, time.Since(start), err)
+	if err != nil {
+		span.End(err)
+		return nil, err
+	}
+	metrics.ReportLoadBalance(opts.Namespace, serviceName, opts.LoadBalanceType, node.Address)
+	span.SetAttribute(tracing.AttrAddress, node.Address)
+	if node.SetName != "" {
+		span.SetAttribute(tracing.AttrSet, node.SetName)
+	}
+	span.End(nil)
+	return node, nil
+}
+
+// selectNode selects the node of the service by the options with the config read once by Select,
+// so that a concurrent Reload does not mix two configs in one selection.
+func (s *Selector) selectNode(cfg *Config, serviceName string, opts *selector.Options) (*registry.Node, error) {
 	namespace := opts.Namespace
 	var sourceService *model.ServiceInfo
 
-	if s.cfg.Enable {
-		sourceService = extractSourceServiceRequestInfo(opts, s.cfg.EnableTransMeta)
+	requestMeta := cfg.MetadataRouter.Metadata(opts.Ctx)
+	if cfg.Enable {
+		sourceService = extractSourceServiceRequestInfo(opts, cfg.EnableTransMeta)
+		if sourceService != nil {
+			for key, value := range requestMeta {
+				if _, ok := sourceService.Metadata[key]; !ok {
+					sourceService.Metadata[key] = value
+				}
+			}
+		}
 	}
 	if opts.LoadBalanceType == "" {
 		opts.LoadBalanceType = LoadBalanceWR
@@ -208,11 +280,17 @@ func (s *Selector) Select(serviceName string, opt ...selector.Option) (*registry
 		name = opts.LoadBalanceType
 	}
 	destMeta := getDestMetadata(opts)
+	for key, value := range cfg.MetadataRouter.Route(serviceName, matchMetadata(opts, requestMeta)) {
+		destMeta[key] = value
+	}
+	if err := s.setDestinationSet(cfg, serviceName, opts, destMeta); err != nil {
+		return nil, err
+	}
 	var hashKey []byte
 	if opts.Key != "" {
 		hashKey = []byte(opts.Key)
 	}
-	resp, err := s.consumer.GetOneInstance(&api.GetOneInstanceRequest{
+	inst, err := s.getOneInstance(circuitbreaker.CalleeMethod(opts.Ctx), &api.GetOneInstanceRequest{
 		GetOneInstanceRequest: model.GetOneInstanceRequest{
 			Service:        serviceName,
 			Namespace:      namespace,
@@ -220,17 +298,13 @@ func (s *Selector) Select(serviceName string, opt ...selector.Option) (*registry
 			Metadata:       destMeta,
 			LbPolicy:       name,
 			ReplicateCount: opts.Replicas,
-			Canary:         getCanaryValue(opts),
+			Canary:         pickCanary(cfg, opts, serviceName),
 			HashKey:        hashKey,
 		},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("get one instance err: %s", err.Error())
-	}
-	if len(resp.Instances) == 0 {
-		return nil, fmt.Errorf("get one instance return empty")
+		return nil, err
 	}
-	inst := resp.Instances[0]
 	var setName, containerName string
 	if inst.GetMetadata() != nil {
 		containerName = inst.GetMetadata()[containerKey]
@@ -238,7 +312,7 @@ func (s *Selector) Select(serviceName string, opt ...selector.Option) (*registry
 			setName = inst.GetMetadata()[setNameKey]
 		}
 	}
-	return &registry.Node{
+	node := &registry.Node{
 		ContainerName: containerName,
 		SetName:       setName,
 		Address:       net.JoinHostPort(inst.GetHost(), strconv.Itoa(int(inst.GetPort()))),
@@ -249,7 +323,99 @@ func (s *Selector) Select(serviceName string, opt ...selector.Option) (*registry
 			"service":   serviceName,
 			"namespace": namespace,
 		},
-	}, nil
+	}
+	circuitbreaker.WithMethod(opts.Ctx, node)
+	return node, nil
+}
+
+// getOneInstance gets one instance for the method by polaris.
+// If any instance of the service is forced by circuitbreaker.Force, ejected by the outlier detection or held by
+// the prober, or the method is broken by the method level breakers, the load balancer chooses from the routed
+// instances filtered by circuitbreaker.FilterInstances.
+func (s *Selector) getOneInstance(method string, req *api.GetOneInstanceRequest) (model.Instance, error) {
+	if !circuitbreaker.HasExcluded(req.Namespace, req.Service, method) {
+		return s.getBalancedInstance(req)
+	}
+	inst, err := s.getFilteredInstance(method, req)
+	if err != nil {
+		return nil, err
+	}
+	circuitbreaker.Selected(req.Namespace, req.Service, method, inst)
+	return inst, nil
+}
+
+// getFilteredInstance gets one instance from the routed instances filtered by circuitbreaker.FilterInstances.
+func (s *Selector) getFilteredInstance(method string, req *api.GetOneInstanceRequest) (model.Instance, error) {
+	resp, err := s.consumer.GetInstances(&api.GetInstancesRequest{
+		GetInstancesRequest: model.GetInstancesRequest{
+			Service:                      req.Service,
+			Namespace:                    req.Namespace,
+			SourceService:                req.SourceService,
+			Metadata:                     req.Metadata,
+			Canary:                       req.Canary,
+			IncludeCircuitBreakInstances: true,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get instances err: %s", err.Error())
+	}
+	instances, ok := circuitbreaker.FilterInstances(req.Namespace, req.Service, method, resp.Instances)
+	if !ok {
+		inst := circuitbreaker.SelectInstance(req.Namespace, req.Service, instances, req.HashKey)
+		if inst == nil {
+			return nil, fmt.Errorf("get one instance return empty")
+		}
+		return inst, nil
+	}
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("get one instance return empty")
+	}
+	if len(instances) < len(resp.Instances) {
+		return loadbalance.ChooseInstance(s.consumer.SDKContext(), req.LbPolicy, req.Namespace, req.Service,
+			instances, req.HashKey)
+	}
+	return s.getBalancedInstance(req)
+}
+
+// getBalancedInstance gets one instance by the load balancer of polaris.
+func (s *Selector) getBalancedInstance(req *api.GetOneInstanceRequest) (model.Instance, error) {
+	resp, err := s.consumer.GetOneInstance(req)
+	if err != nil {
+		return nil, fmt.Errorf("get one instance err: %s", err.Error())
+	}
+	if len(resp.Instances) == 0 {
+		return nil, fmt.Errorf("get one instance return empty")
+	}
+	return resp.Instances[0], nil
+}
+
+// setDestinationSet routes to the destination set by the destination metadata,
+// the wildcard set names and the set fallback are resolved against the instances of the callee.
+func (s *Selector) setDestinationSet(
+	cfg *Config, serviceName string, opts *selector.Options, destMeta map[string]string) error {
+	destSet := opts.DestinationSetName
+	if servicerouter.NeedResolveSet(opts.SourceSetName, cfg.EnableSetFallback) ||
+		servicerouter.NeedResolveSet(destSet, cfg.EnableSetFallback) {
+		resp, err := s.consumer.GetInstances(&api.GetInstancesRequest{
+			GetInstancesRequest: model.GetInstancesRequest{
+				Service:                      serviceName,
+				Namespace:                    opts.Namespace,
+				IncludeCircuitBreakInstances: true,
+				IncludeUnhealthyInstances:    true,
+				SkipRouteFilter:              true,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("get instances to resolve set err: %s", err.Error())
+		}
+		_, destSet = servicerouter.ResolveSetNamesOf(opts.Namespace, serviceName, resp.Instances,
+			opts.SourceSetName, destSet, cfg.EnableSetFallback)
+	}
+	if destSet != "" {
+		destMeta[setEnableKey] = setEnableValue
+		destMeta[setNameKey] = destSet
+	}
+	return nil
 }
 
 // GetConsumer gets the consumerAPI instance of the selector.
@@ -259,12 +425,27 @@ func (s *Selector) GetConsumer() api.ConsumerAPI {
 
 // GetCfg gets selector Config configuration.
 func (s *Selector) GetCfg() *Config {
-	return s.cfg
+	return s.config()
 }
 
 // Report reports the service status.
+// The result is classified the same as circuitbreaker.CircuitBreaker.Report.
 func (s *Selector) Report(node *registry.Node, cost time.Duration, err error) error {
-	return circuitbreaker.Report(s.consumer, node, s.cfg.ReportTimeout, cost, err)
+	cfg := s.config()
+	if cfg.Classifier == nil {
+		return circuitbreaker.Report(s.consumer, node, cfg.ReportTimeout, cost, err)
+	}
+	return cfg.Classifier.Report(s.consumer, node, cost, err)
+}
+
+// pickCanary returns the canary value of the request.
+// Only the callee service specific and the default percentage are supported on the selector path,
+// since the service metadata in polaris mesh is not available before selecting.
+func pickCanary(cfg *Config, opts *selector.Options, serviceName string) string {
+	if cfg.EnableCanary && cfg.Canary != nil {
+		return cfg.Canary.Pick(opts.Ctx, serviceName, nil)
+	}
+	return getCanaryValue(opts)
 }
 
 func getCanaryValue(opts *selector.Options) string {
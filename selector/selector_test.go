@@ -15,19 +15,35 @@ package selector
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	"trpc.group/trpc-go/trpc-go"
 	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/errs"
 	"trpc.group/trpc-go/trpc-go/naming/registry"
 	"trpc.group/trpc-go/trpc-go/naming/selector"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/circuitbreaker"
 	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_api"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_loadbalancer"
 	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_model"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_plugin"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/servicerouter"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/tracing"
 
 	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/polarismesh/polaris-go/api"
+	"github.com/polarismesh/polaris-go/pkg/config"
 	"github.com/polarismesh/polaris-go/pkg/model"
+	"github.com/polarismesh/polaris-go/pkg/model/local"
+	"github.com/polarismesh/polaris-go/pkg/model/pb"
+	"github.com/polarismesh/polaris-go/pkg/plugin/common"
+	"github.com/polarismesh/polaris-go/pkg/plugin/loadbalancer"
+	apiservice "github.com/polarismesh/specification/source/go/api/v1/service_manage"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSetup(t *testing.T) {
@@ -127,6 +143,38 @@ func TestSelect(t *testing.T) {
 	assert.Equal(t, node.Weight, 100)
 }
 
+func TestSelectTracing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	inst := mock_model.NewMockInstance(ctrl)
+	inst.EXPECT().GetMetadata().Return(map[string]string{}).AnyTimes()
+	inst.EXPECT().GetWeight().Return(100).AnyTimes()
+	inst.EXPECT().GetHost().Return("127.0.0.1").AnyTimes()
+	inst.EXPECT().GetPort().Return(uint32(8080)).AnyTimes()
+	consumer := mock_api.NewMockConsumerAPI(ctrl)
+	consumer.EXPECT().GetOneInstance(gomock.Any()).Return(&model.OneInstanceResponse{
+		InstancesResponse: model.InstancesResponse{Instances: []model.Instance{inst}},
+	}, nil)
+	s := &Selector{consumer: consumer, cfg: &Config{}}
+
+	recorder := tracing.NewRecorder()
+	tracing.SetTracer(recorder)
+	defer tracing.SetTracer(nil)
+	ctx, msg := codec.WithNewMessage(context.Background())
+	msg.WithCalleeMethod("/traced")
+	ctx, parent := tracing.Start(ctx, "parent")
+	node, err := s.Select("traced", selector.WithContext(ctx))
+	parent.End(nil)
+	require.Nil(t, err)
+	assert.Equal(t, "/traced", node.Metadata[circuitbreaker.MethodKey], "the trpc message is kept in the span context")
+	spans := recorder.Spans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, tracing.SpanSelect, spans[0].Name)
+	assert.Equal(t, "parent", spans[0].Parent)
+	assert.Equal(t, "127.0.0.1:8080", spans[0].Attributes[tracing.AttrAddress])
+}
+
 func TestReport(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -149,6 +197,31 @@ func TestReport(t *testing.T) {
 	assert.Nil(t, s.Report(node, time.Second, nil))
 }
 
+func TestReportWithClassifier(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var statuses []model.RetStatus
+	consumer := mock_api.NewMockConsumerAPI(ctrl)
+	consumer.EXPECT().UpdateServiceCallResult(gomock.Any()).DoAndReturn(func(r *api.ServiceCallResult) error {
+		statuses = append(statuses, r.RetStatus)
+		return nil
+	}).AnyTimes()
+	classifier, err := circuitbreaker.NewClassifier(nil, &circuitbreaker.ErrorPolicy{
+		Rules: []*circuitbreaker.ErrorRule{{Codes: []int{1}, Action: circuitbreaker.ActionIgnore}},
+	}, nil)
+	require.Nil(t, err)
+	node := &registry.Node{Metadata: map[string]interface{}{"instance": mock_model.NewMockInstance(ctrl)}}
+
+	s := &Selector{consumer: consumer, cfg: &Config{Classifier: classifier}}
+	require.Nil(t, s.Report(node, time.Second, errs.New(1, "")))
+	require.Nil(t, s.Report(node, time.Second, errs.New(2, "")))
+	require.Nil(t, s.Report(node, time.Second, errs.NewFrameError(errs.RetClientNetErr, "")))
+	s = &Selector{consumer: consumer, cfg: &Config{}}
+	require.Nil(t, s.Report(node, time.Second, errs.New(1, "")))
+	assert.Equal(t, []model.RetStatus{model.RetSuccess, model.RetFail, model.RetSuccess}, statuses)
+}
+
 func TestSetTransSelectorMeta(t *testing.T) {
 	ctx := context.Background()
 	msg := trpc.Message(ctx)
@@ -168,3 +241,139 @@ func TestSetTransSelectorMeta(t *testing.T) {
 	setTransSelectorMeta(opts, selectorMeta)
 	assert.EqualValues(t, 0, len(selectorMeta))
 }
+
+func TestSelectWithMetadataRouter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	inst := mock_model.NewMockInstance(ctrl)
+	inst.EXPECT().GetMetadata().Return(map[string]string{}).AnyTimes()
+	inst.EXPECT().GetWeight().Return(100).AnyTimes()
+	inst.EXPECT().GetHost().Return("host").AnyTimes()
+	inst.EXPECT().GetPort().Return(uint32(1003)).AnyTimes()
+
+	consumer := mock_api.NewMockConsumerAPI(ctrl)
+	consumer.EXPECT().GetOneInstance(gomock.Any()).DoAndReturn(
+		func(req *api.GetOneInstanceRequest) (*model.OneInstanceResponse, error) {
+			assert.Equal(t, "gray", req.Metadata["env"])
+			assert.Equal(t, "sz.1", req.SourceService.Metadata["region"])
+			return &model.OneInstanceResponse{
+				InstancesResponse: model.InstancesResponse{Instances: []model.Instance{inst}},
+			}, nil
+		})
+	r, err := servicerouter.NewMetadataRouter([]string{"region"}, []*servicerouter.MetadataRoute{{
+		Match:       []*servicerouter.MetadataMatch{{Key: "region", Type: servicerouter.MatchPrefix, Value: "sz"}},
+		Destination: map[string]string{"env": "gray"},
+	}})
+	assert.Nil(t, err)
+	s := &Selector{
+		consumer: consumer,
+		cfg:      &Config{Enable: true, MetadataRouter: r},
+	}
+
+	ctx, msg := codec.WithNewMessage(context.Background())
+	msg.WithClientMetaData(codec.MetaData{"region": []byte("sz.1")})
+	_, err = s.Select("service name", selector.WithContext(ctx), selector.WithSourceServiceName("caller"))
+	assert.Nil(t, err)
+}
+
+func TestSelectWithSetFallback(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	newInst := func(set string) model.Instance {
+		inst := mock_model.NewMockInstance(ctrl)
+		inst.EXPECT().GetMetadata().Return(map[string]string{
+			setEnableKey: setEnableValue,
+			setNameKey:   set,
+		}).AnyTimes()
+		inst.EXPECT().IsHealthy().Return(true).AnyTimes()
+		inst.EXPECT().IsIsolated().Return(false).AnyTimes()
+		inst.EXPECT().GetCircuitBreakerStatus().Return(nil).AnyTimes()
+		inst.EXPECT().GetWeight().Return(100).AnyTimes()
+		inst.EXPECT().GetHost().Return("host").AnyTimes()
+		inst.EXPECT().GetPort().Return(uint32(1003)).AnyTimes()
+		return inst
+	}
+	inst := newInst("app.sz.2")
+
+	consumer := mock_api.NewMockConsumerAPI(ctrl)
+	consumer.EXPECT().GetInstances(gomock.Any()).Return(&model.InstancesResponse{
+		Instances: []model.Instance{inst},
+	}, nil)
+	consumer.EXPECT().GetOneInstance(gomock.Any()).DoAndReturn(
+		func(req *api.GetOneInstanceRequest) (*model.OneInstanceResponse, error) {
+			assert.Equal(t, setEnableValue, req.Metadata[setEnableKey])
+			assert.Equal(t, "app.sz.2", req.Metadata[setNameKey])
+			return &model.OneInstanceResponse{
+				InstancesResponse: model.InstancesResponse{Instances: []model.Instance{inst}},
+			}, nil
+		})
+	s := &Selector{
+		consumer: consumer,
+		cfg:      &Config{Enable: true, EnableSetFallback: true},
+	}
+	n, err := s.Select("service name", selector.WithDestinationSetName("app.sz.1"))
+	assert.Nil(t, err)
+	assert.Equal(t, "host:1003", n.Address)
+}
+
+func TestSelectForced(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	resp := &apiservice.DiscoverResponse{
+		Type: apiservice.DiscoverResponse_INSTANCE,
+		Service: &apiservice.Service{
+			Name:      &wrappers.StringValue{Value: "forced"},
+			Namespace: &wrappers.StringValue{Value: "Production"},
+		},
+	}
+	for i := 1; i <= 2; i++ {
+		resp.Instances = append(resp.Instances, &apiservice.Instance{
+			Id:      &wrappers.StringValue{Value: fmt.Sprintf("id-%d", i)},
+			Host:    &wrappers.StringValue{Value: "127.0.0.1"},
+			Port:    &wrappers.UInt32Value{Value: uint32(i)},
+			Weight:  &wrappers.UInt32Value{Value: 100},
+			Healthy: &wrappers.BoolValue{Value: true},
+		})
+	}
+	serviceInstances := pb.NewServiceInstancesInProto(resp, func(string) local.InstanceLocalValue {
+		return local.NewInstanceLocalValue()
+	}, &pb.SvcPluginValues{}, local.NewServiceLocalValue())
+	consumer := mock_api.NewMockConsumerAPI(ctrl)
+	consumer.EXPECT().GetInstances(gomock.Any()).DoAndReturn(
+		func(req *api.GetInstancesRequest) (*model.InstancesResponse, error) {
+			assert.Equal(t, "forced", req.Service)
+			assert.Equal(t, "Production", req.Namespace)
+			assert.True(t, req.IncludeCircuitBreakInstances)
+			return &model.InstancesResponse{Instances: serviceInstances.GetInstances()}, nil
+		}).AnyTimes()
+	plugin := mock_loadbalancer.NewMockLoadBalancer(ctrl)
+	plugin.EXPECT().ChooseInstance(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(criteria *loadbalancer.Criteria, instances model.ServiceInstances) (model.Instance, error) {
+			assert.Equal(t, []byte("key"), criteria.HashKey)
+			return instances.GetInstances()[0], nil
+		}).AnyTimes()
+	plugins := mock_plugin.NewMockManager(ctrl)
+	plugins.EXPECT().GetPlugin(common.TypeLoadBalancer, config.DefaultLoadBalancerRingHash).Return(plugin, nil).AnyTimes()
+	sdkCtx := mock_api.NewMockSDKContext(ctrl)
+	sdkCtx.EXPECT().GetPlugins().Return(plugins).AnyTimes()
+	sdkCtx.EXPECT().GetValueContext().Return(mock_model.NewMockValueContext(ctrl)).AnyTimes()
+	consumer.EXPECT().SDKContext().Return(sdkCtx).AnyTimes()
+	s := &Selector{consumer: consumer, cfg: &Config{}}
+
+	require.Nil(t, circuitbreaker.Force("Production", "forced", "127.0.0.1:1", circuitbreaker.ForceIsolate, time.Minute))
+	defer circuitbreaker.Unforce("Production", "forced", "127.0.0.1:1")
+	for i := 0; i < 10; i++ {
+		node, err := s.Select("forced", selector.WithNamespace("Production"),
+			selector.WithLoadBalanceType(LoadBalancerRingHash), selector.WithKey("key"))
+		require.Nil(t, err)
+		assert.Equal(t, "127.0.0.1:2", node.Address, "the configured load balancer chooses from the filtered")
+	}
+
+	require.Nil(t, circuitbreaker.Force("Production", "forced", "127.0.0.1:2", circuitbreaker.ForceIsolate, time.Minute))
+	defer circuitbreaker.Unforce("Production", "forced", "127.0.0.1:2")
+	_, err := s.Select("forced", selector.WithNamespace("Production"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,222 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package servicerouter
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	tsr "trpc.group/trpc-go/trpc-go/naming/servicerouter"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/tracing"
+
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"github.com/polarismesh/specification/source/go/api/v1/traffic_manage"
+)
+
+const (
+	defaultRouteCacheTTL        = time.Second
+	defaultRouteCacheMaxEntries = 10000
+)
+
+// RouteCacheConfig is the configuration of the routing result cache.
+// The cached results are invalidated when the revision of the callee instances,
+// the revision of the caller outbound or the callee inbound routing rules changes, or the config is reloaded.
+type RouteCacheConfig struct {
+	// Enable configures whether to cache the routing results.
+	Enable bool `yaml:"enable"`
+	// TTL is the max lifetime of a cached result, default as 1s.
+	// It bounds the staleness of the local circuit breaker status which does not change the revisions.
+	TTL time.Duration `yaml:"ttl"`
+	// MaxEntries is the max number of cached results, default as 10000.
+	// The cache is cleared when it is full.
+	MaxEntries int `yaml:"max_entries"`
+}
+
+// routeCache caches the routing results, it is safe for concurrent use.
+// A nil *routeCache caches nothing.
+type routeCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.RWMutex
+	entries map[string]*routeCacheEntry
+}
+
+type routeCacheEntry struct {
+	result            *routeResult
+	instancesRevision string
+	rulesRevision     string
+	// cfg is the config of the router when the result is cached, which is replaced by Reload.
+	cfg      *Config
+	expireAt time.Time
+}
+
+func newRouteCache(cfg *Config) *routeCache {
+	if cfg == nil || cfg.RouteCache == nil || !cfg.RouteCache.Enable {
+		return nil
+	}
+	c := &routeCache{
+		ttl:        cfg.RouteCache.TTL,
+		maxEntries: cfg.RouteCache.MaxEntries,
+		entries:    make(map[string]*routeCacheEntry),
+	}
+	if c.ttl <= 0 {
+		c.ttl = defaultRouteCacheTTL
+	}
+	if c.maxEntries <= 0 {
+		c.maxEntries = defaultRouteCacheMaxEntries
+	}
+	return c
+}
+
+// get returns the cached result of the key if the revisions and the config are not changed
+// and it is not expired.
+func (c *routeCache) get(key, instancesRevision, rulesRevision string, cfg *Config) (*routeResult, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.RLock()
+	e, ok := c.entries[key]
+	c.mu.RUnlock()
+	if !ok ||
+		e.instancesRevision != instancesRevision ||
+		e.rulesRevision != rulesRevision ||
+		e.cfg != cfg ||
+		time.Now().After(e.expireAt) {
+		return nil, false
+	}
+	return e.result, true
+}
+
+// set caches the result of the key with the revisions and the config, replacing the stale one.
+func (c *routeCache) set(key, instancesRevision, rulesRevision string, cfg *Config, r *routeResult) {
+	if c == nil {
+		return
+	}
+	e := &routeCacheEntry{
+		result:            r,
+		instancesRevision: instancesRevision,
+		rulesRevision:     rulesRevision,
+		cfg:               cfg,
+		expireAt:          time.Now().Add(c.ttl),
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		c.entries = make(map[string]*routeCacheEntry)
+	}
+	c.entries[key] = e
+}
+
+// routeCacheKey builds the cache key of a routing request.
+// kind distinguishes the routing chains, and canary is the canary value picked for the request.
+func (s *ServiceRouter) routeCacheKey(
+	kind string,
+	sourceService, destService *model.ServiceInfo,
+	opts *tsr.Options,
+	canary string,
+) string {
+	var b strings.Builder
+	for _, v := range []string{
+		kind,
+		sourceService.Namespace, sourceService.Service,
+		destService.Namespace, destService.Service,
+		opts.SourceEnvName, opts.DestinationEnvName, opts.EnvKey, opts.EnvTransfer,
+		opts.SourceSetName, opts.DestinationSetName,
+		canary,
+	} {
+		b.WriteString(v)
+		b.WriteByte('|')
+	}
+	writeMetadata(&b, opts.SourceMetadata)
+	writeMetadata(&b, s.config().MetadataRouter.Metadata(opts.Ctx))
+	return b.String()
+}
+
+// writeMetadata writes the metadata sorted by key.
+func writeMetadata(b *strings.Builder, metadata map[string]string) {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(metadata[k])
+		b.WriteByte(',')
+	}
+	b.WriteByte('|')
+}
+
+// cacheableRoutes reports whether the routing result by the routes is stable.
+// Destinations of the same priority are picked randomly by weight, so their results can not be cached.
+func cacheableRoutes(routes []*traffic_manage.Route) bool {
+	for _, r := range routes {
+		priorities := make(map[uint32]struct{}, len(r.GetDestinations()))
+		for _, dest := range r.GetDestinations() {
+			priority := dest.GetPriority().GetValue()
+			if _, ok := priorities[priority]; ok {
+				return false
+			}
+			priorities[priority] = struct{}{}
+		}
+	}
+	return true
+}
+
+// rulesRevision returns the revisions of the route rules joined, empty for the nil rules.
+func rulesRevision(rules ...*model.ServiceRuleResponse) string {
+	revisions := make([]string, len(rules))
+	for i, r := range rules {
+		if r != nil {
+			revisions[i] = r.GetRevision()
+		}
+	}
+	return strings.Join(revisions, "|")
+}
+
+// cachedRoute returns the cached routing result, or routes and caches the result.
+// kind distinguishes the routing chains, and errors are never cached.
+func (s *ServiceRouter) cachedRoute(
+	kind string,
+	rulesRevision string,
+	serviceInstances model.ServiceInstances,
+	sourceService, destService *model.ServiceInfo,
+	opts *tsr.Options,
+	canaryValue string,
+	route func() (*routeResult, error),
+) (*routeResult, error) {
+	if s.cache == nil {
+		return route()
+	}
+	// The result routed by the config reloaded in flight is cached with the old config, so it is never hit.
+	cfg := s.config()
+	key := s.routeCacheKey(kind, sourceService, destService, opts, canaryValue)
+	instancesRevision := serviceInstances.GetRevision()
+	if r, ok := s.cache.get(key, instancesRevision, rulesRevision, cfg); ok {
+		c := r.clone()
+		c.cache = tracing.CacheHit
+		return c, nil
+	}
+	r, err := route()
+	if err != nil {
+		return nil, err
+	}
+	s.cache.set(key, instancesRevision, rulesRevision, cfg, r.clone())
+	r.cache = tracing.CacheMiss
+	return r, nil
+}
@@ -0,0 +1,279 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package servicerouter
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/naming/registry"
+	tsr "trpc.group/trpc-go/trpc-go/naming/servicerouter"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/polarismesh/polaris-go/api"
+	"github.com/polarismesh/polaris-go/pkg/config"
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"github.com/polarismesh/polaris-go/pkg/model/local"
+	"github.com/polarismesh/polaris-go/pkg/model/pb"
+	"github.com/polarismesh/polaris-go/pkg/plugin"
+	"github.com/polarismesh/polaris-go/pkg/plugin/servicerouter"
+	"github.com/polarismesh/polaris-go/plugin/servicerouter/dstmeta"
+	"github.com/polarismesh/polaris-go/plugin/servicerouter/filteronly"
+	"github.com/polarismesh/polaris-go/plugin/servicerouter/nearbybase"
+	apiservice "github.com/polarismesh/specification/source/go/api/v1/service_manage"
+	"github.com/polarismesh/specification/source/go/api/v1/traffic_manage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_api"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_model"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_plugin"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/tracing"
+)
+
+func TestRouteCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var calls int32
+	inst := mock_model.NewMockInstance(ctrl)
+	oldServicerouterGetFilterInstances := servicerouterGetFilterInstances
+	defer func() {
+		servicerouterGetFilterInstances = oldServicerouterGetFilterInstances
+	}()
+	servicerouterGetFilterInstances = func(model.ValueContext, []servicerouter.ServiceRouter,
+		*servicerouter.RouteInfo, model.ServiceInstances) ([]model.Instance,
+		*model.Cluster, *model.ServiceInfo, error) {
+		atomic.AddInt32(&calls, 1)
+		return []model.Instance{inst}, nil, nil, nil
+	}
+
+	var instancesRevision, rulesRevision, inboundRevision atomic.Value
+	instancesRevision.Store("1")
+	rulesRevision.Store("1")
+	inboundRevision.Store("1")
+	serviceInstances := mock_model.NewMockServiceInstances(ctrl)
+	serviceInstances.EXPECT().GetRevision().DoAndReturn(func() string {
+		return instancesRevision.Load().(string)
+	}).AnyTimes()
+	routes := []*traffic_manage.Route{{}}
+	oldGetOutboundsRoute := getOutboundsRoute
+	defer func() {
+		getOutboundsRoute = oldGetOutboundsRoute
+	}()
+	getOutboundsRoute = func(*model.ServiceRuleResponse) []*traffic_manage.Route {
+		return routes
+	}
+	consumer := mock_api.NewMockConsumerAPI(ctrl)
+	consumer.EXPECT().GetRouteRule(gomock.Any()).DoAndReturn(
+		func(req *api.GetServiceRuleRequest) (*model.ServiceRuleResponse, error) {
+			if req.Service == "svc" {
+				return &model.ServiceRuleResponse{Revision: inboundRevision.Load().(string)}, nil
+			}
+			return &model.ServiceRuleResponse{Revision: rulesRevision.Load().(string)}, nil
+		}).AnyTimes()
+	sdkCtx := mock_api.NewMockSDKContext(ctrl)
+	sdkCtx.EXPECT().GetValueContext().Return(model.NewValueContext()).AnyTimes()
+
+	cfg := &Config{
+		Enable:     true,
+		RouteCache: &RouteCacheConfig{Enable: true, TTL: time.Hour, MaxEntries: 2},
+	}
+	s := &ServiceRouter{sdkCtx: sdkCtx, consumer: consumer, cfg: cfg, cache: newRouteCache(cfg)}
+	n := &registry.Node{Metadata: map[string]interface{}{"service_instances": serviceInstances}}
+	filter := func(opt ...tsr.Option) {
+		opt = append(opt, tsr.WithSourceServiceName("caller"), tsr.WithSourceNamespace("ns"))
+		nodes, err := s.Filter("svc", []*registry.Node{n}, opt...)
+		require.Nil(t, err)
+		require.Len(t, nodes, 1)
+	}
+
+	recorder := tracing.NewRecorder()
+	tracing.SetTracer(recorder)
+	filter()
+	filter()
+	tracing.SetTracer(nil)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	spans := recorder.Spans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, tracing.SpanServiceRouterFilter, spans[0].Name)
+	assert.Equal(t, "svc", spans[0].Attributes[tracing.AttrService])
+	assert.Equal(t, tracing.CacheMiss, spans[0].Attributes[tracing.AttrCache])
+	assert.Equal(t, tracing.CacheHit, spans[1].Attributes[tracing.AttrCache])
+
+	filter(tsr.WithSourceMetadata("k", "v"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	filter(tsr.WithEnvTransfer("test"))
+	filter(tsr.WithEnvTransfer("test"))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Len(t, s.cache.entries, 1, "cache is cleared when it is full")
+
+	instancesRevision.Store("2")
+	filter(tsr.WithEnvTransfer("test"))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+
+	filter()
+	rulesRevision.Store("2")
+	filter()
+	filter()
+	assert.Equal(t, int32(6), atomic.LoadInt32(&calls))
+	inboundRevision.Store("2")
+	filter()
+	filter()
+	assert.Equal(t, int32(7), atomic.LoadInt32(&calls), "the inbound rules of the callee are changed")
+	s.Reload(&Config{Enable: true})
+	filter()
+	filter()
+	assert.Equal(t, int32(8), atomic.LoadInt32(&calls), "the config is reloaded")
+
+	routes = []*traffic_manage.Route{{
+		Destinations: []*traffic_manage.Destination{{}, {}},
+	}}
+	filter()
+	filter()
+	assert.Equal(t, int32(10), atomic.LoadInt32(&calls), "weighted destinations are not cached")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.Filter("svc", []*registry.Node{n}, tsr.WithDestinationEnvName(fmt.Sprint(i%3)))
+			assert.Nil(t, err)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestRouteCacheExpire(t *testing.T) {
+	cfg := &Config{RouteCache: &RouteCacheConfig{Enable: true, TTL: time.Millisecond}}
+	c := newRouteCache(cfg)
+	c.set("key", "1", "1", cfg, &routeResult{env: "test"})
+	r, ok := c.get("key", "1", "1", cfg)
+	require.True(t, ok)
+	assert.Equal(t, "test", r.env)
+	_, ok = c.get("key", "2", "1", cfg)
+	assert.False(t, ok)
+	_, ok = c.get("key", "1", "1", &Config{})
+	assert.False(t, ok, "the config is reloaded")
+	time.Sleep(2 * time.Millisecond)
+	_, ok = c.get("key", "1", "1", cfg)
+	assert.False(t, ok)
+
+	assert.Nil(t, newRouteCache(&Config{}))
+	assert.Nil(t, newRouteCache(nil))
+}
+
+func TestCacheableRoutes(t *testing.T) {
+	assert.True(t, cacheableRoutes(nil))
+	assert.True(t, cacheableRoutes([]*traffic_manage.Route{{
+		Destinations: []*traffic_manage.Destination{
+			{Priority: &wrappers.UInt32Value{Value: 0}},
+			{Priority: &wrappers.UInt32Value{Value: 1}},
+		},
+	}}))
+	assert.False(t, cacheableRoutes([]*traffic_manage.Route{{
+		Destinations: []*traffic_manage.Destination{
+			{Priority: &wrappers.UInt32Value{Value: 1}},
+			{Priority: &wrappers.UInt32Value{Value: 1}},
+		},
+	}}))
+}
+
+func BenchmarkFilter(b *testing.B) {
+	for _, c := range []struct {
+		name  string
+		cache *RouteCacheConfig
+	}{
+		{"no cache", nil},
+		{"cache", &RouteCacheConfig{Enable: true}},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			s, n := newBenchmarkRouter(b, c.cache)
+			opts := []tsr.Option{tsr.WithNamespace("Production"), tsr.WithDestinationEnvName("env1")}
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := s.Filter("svc", []*registry.Node{n}, opts...); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+// newBenchmarkRouter creates a ServiceRouter with the polaris destination metadata, nearby and filter only routers,
+// and a node of 1000 instances in 10 environments.
+func newBenchmarkRouter(b *testing.B, cache *RouteCacheConfig) (*ServiceRouter, *registry.Node) {
+	ctrl := gomock.NewController(b)
+	b.Cleanup(ctrl.Finish)
+
+	plugins := mock_plugin.NewMockManager(ctrl)
+	plugins.EXPECT().RegisterEventSubscriber(gomock.Any(), gomock.Any()).AnyTimes()
+	plugins.EXPECT().GetEventSubscribers(gomock.Any()).Return(nil).AnyTimes()
+	valueCtx := model.NewValueContext()
+	valueCtx.SetValue(model.ContextKeyPlugins, plugins)
+	initCtx := &plugin.InitContext{
+		Config:   config.NewDefaultConfiguration(nil),
+		Plugins:  plugins,
+		ValueCtx: valueCtx,
+	}
+	dstMeta := &dstmeta.InstancesFilter{}
+	nearbyBased := &nearbybase.NearbyBasedInstancesFilter{}
+	filterOnly := &filteronly.InstancesFilter{}
+	require.Nil(b, dstMeta.Init(initCtx))
+	require.Nil(b, nearbyBased.Init(initCtx))
+	require.Nil(b, filterOnly.Init(initCtx))
+
+	resp := &apiservice.DiscoverResponse{
+		Type: apiservice.DiscoverResponse_INSTANCE,
+		Service: &apiservice.Service{
+			Name:      &wrappers.StringValue{Value: "svc"},
+			Namespace: &wrappers.StringValue{Value: "Production"},
+			Revision:  &wrappers.StringValue{Value: "1"},
+		},
+	}
+	for i := 0; i < 1000; i++ {
+		resp.Instances = append(resp.Instances, &apiservice.Instance{
+			Id:       &wrappers.StringValue{Value: fmt.Sprintf("id-%d", i)},
+			Host:     &wrappers.StringValue{Value: "127.0.0.1"},
+			Port:     &wrappers.UInt32Value{Value: uint32(i)},
+			Weight:   &wrappers.UInt32Value{Value: 100},
+			Healthy:  &wrappers.BoolValue{Value: true},
+			Metadata: map[string]string{"env": fmt.Sprintf("env%d", i%10)},
+		})
+	}
+	serviceInstances := pb.NewServiceInstancesInProto(resp, func(string) local.InstanceLocalValue {
+		return local.NewInstanceLocalValue()
+	}, &pb.SvcPluginValues{}, local.NewServiceLocalValue())
+
+	sdkCtx := mock_api.NewMockSDKContext(ctrl)
+	sdkCtx.EXPECT().GetValueContext().Return(valueCtx).AnyTimes()
+	cfg := &Config{RouteCache: cache}
+	s := &ServiceRouter{
+		sdkCtx:      sdkCtx,
+		cfg:         cfg,
+		cache:       newRouteCache(cfg),
+		DstMeta:     dstMeta,
+		NearbyBased: nearbyBased,
+		FilterOnly:  filterOnly,
+	}
+	return s, &registry.Node{Metadata: map[string]interface{}{"service_instances": serviceInstances}}
+}
@@ -0,0 +1,102 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package servicerouter
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+
+	"trpc.group/trpc-go/trpc-go/codec"
+)
+
+const (
+	// CanaryPercentKey is the metadata key of callee service in polaris mesh,
+	// whose value is the percentage of untagged traffic to be sent to canary instances.
+	CanaryPercentKey = "trpc-canary-percent"
+	// DefaultCanaryValue is the default canary label value of the canary instances.
+	DefaultCanaryValue = "1"
+
+	canaryBuckets = 10000
+)
+
+// CanaryConfig is the configuration of percentage based canary routing.
+type CanaryConfig struct {
+	// Value is the canary label value of canary instances, default as DefaultCanaryValue.
+	Value string
+	// HashKey is the client metadata key used to stick a request to a canary bucket, such as user id.
+	// Requests without the key are put into a random bucket.
+	HashKey string
+	// Percent is the default percentage of untagged traffic sent to canary instances, in range [0, 100].
+	Percent float64
+	// Services overrides Percent by callee service name.
+	Services map[string]float64
+}
+
+// percent returns the canary percentage of the callee service.
+// The priority is: service specific config > service metadata in polaris mesh > default percent.
+func (c *CanaryConfig) percent(service string, serviceMeta map[string]string) float64 {
+	if p, ok := c.Services[service]; ok {
+		return p
+	}
+	if v, ok := serviceMeta[CanaryPercentKey]; ok {
+		if p, err := strconv.ParseFloat(v, 64); err == nil {
+			return p
+		}
+	}
+	return c.Percent
+}
+
+// Pick returns the canary value of the request.
+// The canary value carried by client metadata always takes precedence.
+// Otherwise, the request is put into a bucket, and if the bucket falls into the canary percentage,
+// the configured canary value is returned.
+func (c *CanaryConfig) Pick(ctx context.Context, service string, serviceMeta map[string]string) string {
+	if v := clientCanaryValue(ctx); v != "" {
+		return v
+	}
+	percent := c.percent(service, serviceMeta)
+	if percent <= 0 {
+		return ""
+	}
+	var hashKey []byte
+	if ctx != nil && c.HashKey != "" {
+		hashKey = codec.Message(ctx).ClientMetaData()[c.HashKey]
+	}
+	if canaryBucket(hashKey) >= uint32(percent*canaryBuckets/100) {
+		return ""
+	}
+	if c.Value == "" {
+		return DefaultCanaryValue
+	}
+	return c.Value
+}
+
+// clientCanaryValue returns the canary value carried by client metadata.
+func clientCanaryValue(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	return string(codec.Message(ctx).ClientMetaData()[CanaryKey])
+}
+
+func canaryBucket(key []byte) uint32 {
+	if len(key) == 0 {
+		return uint32(rand.Intn(canaryBuckets))
+	}
+	h := fnv.New32a()
+	_, _ = h.Write(key)
+	return h.Sum32() % canaryBuckets
+}
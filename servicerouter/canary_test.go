@@ -0,0 +1,95 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package servicerouter
+
+import (
+	"context"
+	"testing"
+
+	"trpc.group/trpc-go/trpc-go/codec"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCanaryCtx(md codec.MetaData) context.Context {
+	ctx, msg := codec.WithNewMessage(context.Background())
+	msg.WithClientMetaData(md)
+	return ctx
+}
+
+func TestCanaryConfig_Pick(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		cfg         *CanaryConfig
+		ctx         context.Context
+		serviceMeta map[string]string
+		want        string
+	}{
+		{
+			name: "explicit canary value takes precedence",
+			cfg:  &CanaryConfig{Percent: 0},
+			ctx:  newCanaryCtx(codec.MetaData{CanaryKey: []byte("2")}),
+			want: "2",
+		},
+		{
+			name: "zero percent",
+			cfg:  &CanaryConfig{},
+			ctx:  newCanaryCtx(nil),
+			want: "",
+		},
+		{
+			name: "hundred percent with default value",
+			cfg:  &CanaryConfig{Percent: 100},
+			want: DefaultCanaryValue,
+		},
+		{
+			name: "service config overrides default percent",
+			cfg:  &CanaryConfig{Percent: 100, Value: "gray", Services: map[string]float64{"svc": 0}},
+			want: "",
+		},
+		{
+			name:        "service metadata in polaris mesh",
+			cfg:         &CanaryConfig{Value: "gray"},
+			serviceMeta: map[string]string{CanaryPercentKey: "100"},
+			want:        "gray",
+		},
+		{
+			name:        "invalid service metadata falls back to default percent",
+			cfg:         &CanaryConfig{Value: "gray"},
+			serviceMeta: map[string]string{CanaryPercentKey: "x"},
+			want:        "",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.want, c.cfg.Pick(c.ctx, "svc", c.serviceMeta))
+		})
+	}
+}
+
+func TestCanaryConfig_PickSticky(t *testing.T) {
+	cfg := &CanaryConfig{HashKey: "uid", Percent: 50}
+	ctx := newCanaryCtx(codec.MetaData{"uid": []byte("10086")})
+	want := cfg.Pick(ctx, "svc", nil)
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, want, cfg.Pick(ctx, "svc", nil))
+	}
+
+	var canary int
+	for i := 0; i < canaryBuckets; i++ {
+		if cfg.Pick(nil, "svc", nil) != "" {
+			canary++
+		}
+	}
+	assert.InDelta(t, canaryBuckets/2, canary, canaryBuckets/10)
+}
@@ -23,6 +23,17 @@ type Config struct {
 	EnableCanary bool
 	// NeedReturnAllNodes expands all nodes into registry.Node and return.
 	NeedReturnAllNodes bool
+	// Canary configures percentage based canary routing, it takes effect only when EnableCanary is true.
+	Canary *CanaryConfig
+	// MetadataRouter routes requests by the metadata read from trpc message.
+	MetadataRouter *MetadataRouter
+	// Failover configures the failover by callee service name, FailoverAnyService applies to all services.
+	Failover map[string]*Failover
+	// EnableSetFallback falls back to the region level set and then to no set
+	// when the set has no available instances.
+	EnableSetFallback bool
+	// RouteCache configures the cache of routing results.
+	RouteCache *RouteCacheConfig
 }
 
 const (
@@ -0,0 +1,156 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package servicerouter
+
+import (
+	"strings"
+
+	"trpc.group/trpc-go/trpc-go/log"
+	tsr "trpc.group/trpc-go/trpc-go/naming/servicerouter"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/metrics"
+
+	"github.com/polarismesh/polaris-go/pkg/model"
+)
+
+// FailoverAnyService is the key of Config.Failover which applies to all callee services without specific config.
+const FailoverAnyService = "*"
+
+// Failover is the failover configuration of a callee service.
+// When the routing result has fewer healthy instances than MinHealthyInstances,
+// Envs are tried in order, and then Sets are tried in order.
+type Failover struct {
+	// Envs are the fallback environments in order.
+	Envs []string `yaml:"envs"`
+	// Sets are the fallback set names in order.
+	Sets []string `yaml:"sets"`
+	// MinHealthyInstances is the minimum number of healthy instances, default as 1.
+	MinHealthyInstances int `yaml:"min_healthy_instances"`
+}
+
+func (f *Failover) minHealthyInstances() int {
+	if f.MinHealthyInstances <= 0 {
+		return 1
+	}
+	return f.MinHealthyInstances
+}
+
+// need reports whether the instances need to fail over.
+func (f *Failover) need(instances []model.Instance) bool {
+	return healthyCount(instances) < f.minHealthyInstances()
+}
+
+// healthyCount counts the available instances.
+func healthyCount(instances []model.Instance) int {
+	var count int
+	for _, inst := range instances {
+		if available(inst) {
+			count++
+		}
+	}
+	return count
+}
+
+// available reports whether the instance is healthy, not isolated and not circuit broken.
+func available(inst model.Instance) bool {
+	if !inst.IsHealthy() || inst.IsIsolated() {
+		return false
+	}
+	status := inst.GetCircuitBreakerStatus()
+	return status == nil || status.IsAvailable()
+}
+
+func (c *Config) failover(serviceName string) *Failover {
+	if f, ok := c.Failover[serviceName]; ok {
+		return f
+	}
+	return c.Failover[FailoverAnyService]
+}
+
+// failover tries the fallback environments and sets in order,
+// and returns the first result with enough healthy instances.
+// If none of them has enough healthy instances, the first non empty result is returned
+// when the primary result is empty, otherwise, the primary result is returned.
+func (s *ServiceRouter) failover(
+	serviceInstances model.ServiceInstances,
+	destService *model.ServiceInfo,
+	opts *tsr.Options,
+	canaryValue string,
+	f *Failover,
+	primary *routeResult,
+) *routeResult {
+	type target struct {
+		kind string
+		env  string
+		set  string
+	}
+	var targets []target
+	for _, env := range f.Envs {
+		targets = append(targets, target{kind: metrics.FailoverEnv, env: env})
+	}
+	for _, set := range f.Sets {
+		targets = append(targets, target{kind: metrics.FailoverSet, set: set})
+	}
+
+	var (
+		candidate     *routeResult
+		candidateDest string
+		candidateKind string
+	)
+	for _, t := range targets {
+		o := *opts
+		o.DestinationEnvName = t.env
+		o.DestinationSetName = t.set
+		o.SourceSetName = ""
+		if strings.Contains(t.set, SetWildcard) {
+			_, o.DestinationSetName = ResolveSetNames(serviceInstances.GetInstances(), "", t.set, false)
+		}
+		r, err := s.filterWithoutServiceRouter(
+			serviceInstances,
+			&model.ServiceInfo{Service: opts.SourceServiceName, Namespace: opts.SourceNamespace},
+			&model.ServiceInfo{Service: destService.Service, Namespace: destService.Namespace},
+			&o,
+			canaryValue,
+		)
+		if err != nil {
+			log.Tracef("[NAMING-POLARISMESH] failover of %s to %s%s err: %v", destService.Service, t.env, t.set, err)
+			continue
+		}
+		r.set = o.DestinationSetName
+		if !f.need(r.instances) {
+			s.reportFailover(destService, opts, t.kind, t.env+t.set)
+			return r
+		}
+		if candidate == nil && len(r.instances) > 0 {
+			candidate, candidateKind, candidateDest = r, t.kind, t.env+t.set
+		}
+	}
+	if len(primary.instances) == 0 && candidate != nil {
+		s.reportFailover(destService, opts, candidateKind, candidateDest)
+		return candidate
+	}
+	return primary
+}
+
+func (s *ServiceRouter) reportFailover(destService *model.ServiceInfo, opts *tsr.Options, kind, to string) {
+	from := opts.DestinationEnvName
+	if from == "" {
+		from = opts.SourceEnvName
+	}
+	if opts.DestinationSetName != "" {
+		from = opts.DestinationSetName
+	}
+	log.Warnf("[NAMING-POLARISMESH] service %s of namespace %s fails over from %s %q to %s %q",
+		destService.Service, destService.Namespace, kind, from, kind, to)
+	metrics.ReportFailover(destService.Namespace, destService.Service, kind, from, to)
+}
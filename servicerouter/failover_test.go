@@ -0,0 +1,113 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package servicerouter
+
+import (
+	"testing"
+
+	"trpc.group/trpc-go/trpc-go/naming/registry"
+	tsr "trpc.group/trpc-go/trpc-go/naming/servicerouter"
+
+	"github.com/golang/mock/gomock"
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"github.com/polarismesh/polaris-go/pkg/plugin/servicerouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_api"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_model"
+)
+
+func TestFailover(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	newInst := func(healthy bool) model.Instance {
+		inst := mock_model.NewMockInstance(ctrl)
+		inst.EXPECT().IsHealthy().Return(healthy).AnyTimes()
+		inst.EXPECT().IsIsolated().Return(false).AnyTimes()
+		inst.EXPECT().GetCircuitBreakerStatus().Return(nil).AnyTimes()
+		return inst
+	}
+	// instances by destination env or set.
+	instances := map[string][]model.Instance{
+		"":       nil,
+		"test":   {newInst(false)},
+		"pre":    {newInst(true), newInst(true)},
+		"sz.a.1": {newInst(true)},
+	}
+
+	oldServicerouterGetFilterInstances := servicerouterGetFilterInstances
+	defer func() {
+		servicerouterGetFilterInstances = oldServicerouterGetFilterInstances
+	}()
+	servicerouterGetFilterInstances = func(_ model.ValueContext, _ []servicerouter.ServiceRouter,
+		routeInfo *servicerouter.RouteInfo, _ model.ServiceInstances) ([]model.Instance,
+		*model.Cluster, *model.ServiceInfo, error) {
+		meta := routeInfo.DestService.GetMetadata()
+		if meta[setEnableKey] == setEnableValue {
+			return instances[meta[setNameKey]], nil, nil, nil
+		}
+		return instances[meta["env"]], nil, nil, nil
+	}
+
+	sdkCtx := mock_api.NewMockSDKContext(ctrl)
+	sdkCtx.EXPECT().GetValueContext().Return(model.NewValueContext()).AnyTimes()
+	n := &registry.Node{
+		Metadata: map[string]interface{}{
+			"service_instances": mock_model.NewMockServiceInstances(ctrl),
+		},
+	}
+	newRouter := func(failover map[string]*Failover) *ServiceRouter {
+		return &ServiceRouter{
+			sdkCtx: sdkCtx,
+			cfg:    &Config{Failover: failover},
+		}
+	}
+
+	t.Run("no failover config", func(t *testing.T) {
+		_, err := newRouter(nil).Filter("svc", []*registry.Node{n})
+		assert.NotNil(t, err)
+	})
+	t.Run("fail over to env", func(t *testing.T) {
+		nodes, err := newRouter(map[string]*Failover{
+			"svc": {Envs: []string{"test", "pre"}},
+		}).Filter("svc", []*registry.Node{n})
+		require.Nil(t, err)
+		require.Len(t, nodes, 1)
+	})
+	t.Run("fail over to set", func(t *testing.T) {
+		r := newRouter(map[string]*Failover{
+			FailoverAnyService: {Envs: []string{"test"}, Sets: []string{"sz.a.1"}},
+		})
+		res, err := r.route(nil, &model.ServiceInfo{}, &model.ServiceInfo{Service: "svc"}, &tsr.Options{}, "")
+		require.Nil(t, err)
+		f := r.cfg.failover("svc")
+		require.True(t, f.need(res.instances))
+		res = r.failover(nil, &model.ServiceInfo{Service: "svc"}, &tsr.Options{}, "", f, res)
+		assert.Equal(t, instances["sz.a.1"], res.instances)
+	})
+	t.Run("threshold not reached falls back to non empty result", func(t *testing.T) {
+		r := newRouter(map[string]*Failover{
+			"svc": {Envs: []string{"test", "pre"}, MinHealthyInstances: 3},
+		})
+		f := r.cfg.failover("svc")
+		res := r.failover(nil, &model.ServiceInfo{Service: "svc"}, &tsr.Options{}, "", f, &routeResult{})
+		assert.Equal(t, instances["test"], res.instances)
+
+		primary := &routeResult{instances: instances["pre"]}
+		res = r.failover(nil, &model.ServiceInfo{Service: "svc"}, &tsr.Options{}, "", f, primary)
+		assert.Equal(t, primary, res)
+	})
+}
@@ -0,0 +1,200 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package servicerouter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"trpc.group/trpc-go/trpc-go/codec"
+)
+
+// Match types supported by MetadataMatch.
+const (
+	// MatchExact matches if the value equals to the metadata value.
+	MatchExact = "exact"
+	// MatchRegex matches if the regular expression matches the metadata value.
+	MatchRegex = "regex"
+	// MatchPrefix matches if the metadata value starts with the value.
+	MatchPrefix = "prefix"
+	// MatchIn matches if the metadata value is one of the comma separated values.
+	MatchIn = "in"
+	// MatchRange matches if the metadata value is a number in the range "min~max".
+	// Both bounds are inclusive and either of them can be omitted.
+	MatchRange = "range"
+)
+
+// MetadataMatch is a match condition on request metadata.
+type MetadataMatch struct {
+	// Key is the metadata key.
+	Key string `yaml:"key"`
+	// Type is the match type, default as MatchExact.
+	Type string `yaml:"type"`
+	// Value is the value to match with, its format depends on Type.
+	Value string `yaml:"value"`
+
+	regex    *regexp.Regexp
+	set      map[string]struct{}
+	min, max *float64
+}
+
+func (m *MetadataMatch) compile() error {
+	switch m.Type {
+	case "", MatchExact, MatchPrefix:
+	case MatchRegex:
+		regex, err := regexp.Compile(m.Value)
+		if err != nil {
+			return fmt.Errorf("invalid regex %s of key %s: %w", m.Value, m.Key, err)
+		}
+		m.regex = regex
+	case MatchIn:
+		m.set = make(map[string]struct{})
+		for _, v := range strings.Split(m.Value, ",") {
+			m.set[strings.TrimSpace(v)] = struct{}{}
+		}
+	case MatchRange:
+		bounds := strings.Split(m.Value, "~")
+		if len(bounds) != 2 {
+			return fmt.Errorf("invalid range %s of key %s, should be min~max", m.Value, m.Key)
+		}
+		var err error
+		if m.min, err = parseBound(bounds[0]); err != nil {
+			return fmt.Errorf("invalid range %s of key %s: %w", m.Value, m.Key, err)
+		}
+		if m.max, err = parseBound(bounds[1]); err != nil {
+			return fmt.Errorf("invalid range %s of key %s: %w", m.Value, m.Key, err)
+		}
+	default:
+		return fmt.Errorf("unknown match type %s of key %s", m.Type, m.Key)
+	}
+	return nil
+}
+
+func parseBound(s string) (*float64, error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Match reports whether the metadata matches the condition.
+// A missing key never matches.
+func (m *MetadataMatch) Match(metadata map[string]string) bool {
+	value, ok := metadata[m.Key]
+	if !ok {
+		return false
+	}
+	switch m.Type {
+	case MatchRegex:
+		return m.regex.MatchString(value)
+	case MatchPrefix:
+		return strings.HasPrefix(value, m.Value)
+	case MatchIn:
+		_, ok := m.set[value]
+		return ok
+	case MatchRange:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return false
+		}
+		return (m.min == nil || v >= *m.min) && (m.max == nil || v <= *m.max)
+	default:
+		return value == m.Value
+	}
+}
+
+// MetadataRoute routes requests whose metadata matches all conditions
+// to instances with the destination metadata.
+type MetadataRoute struct {
+	// Service is the callee service name this route applies to, empty or "*" for all services.
+	Service string `yaml:"service"`
+	// Match is the list of conditions, all of them must match.
+	Match []*MetadataMatch `yaml:"match"`
+	// Destination is the instance metadata of the callee to route to.
+	Destination map[string]string `yaml:"destination"`
+}
+
+func (r *MetadataRoute) match(service string, metadata map[string]string) bool {
+	if r.Service != "" && r.Service != "*" && r.Service != service {
+		return false
+	}
+	for _, m := range r.Match {
+		if !m.Match(metadata) {
+			return false
+		}
+	}
+	return true
+}
+
+// MetadataRouter matches request metadata against locally configured routes.
+type MetadataRouter struct {
+	keys   []string
+	routes []*MetadataRoute
+}
+
+// NewMetadataRouter creates a MetadataRouter.
+// keys are the metadata keys read from the trpc message of the request.
+// routes are matched in order and the first matched one wins.
+func NewMetadataRouter(keys []string, routes []*MetadataRoute) (*MetadataRouter, error) {
+	for _, r := range routes {
+		if len(r.Destination) == 0 {
+			return nil, fmt.Errorf("metadata route of service %s has no destination", r.Service)
+		}
+		for _, m := range r.Match {
+			if err := m.compile(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &MetadataRouter{keys: keys, routes: routes}, nil
+}
+
+// Metadata reads the configured keys from the trpc message.
+// Client metadata is preferred over the transparently transmitted server metadata.
+func (r *MetadataRouter) Metadata(ctx context.Context) map[string]string {
+	if r == nil || ctx == nil || len(r.keys) == 0 {
+		return nil
+	}
+	msg := codec.Message(ctx)
+	metadata := make(map[string]string, len(r.keys))
+	for _, key := range r.keys {
+		if v, ok := msg.ClientMetaData()[key]; ok {
+			metadata[key] = string(v)
+		} else if v, ok := msg.ServerMetaData()[key]; ok {
+			metadata[key] = string(v)
+		}
+	}
+	return metadata
+}
+
+// Route returns the destination metadata of the first route matched by the callee service and the metadata.
+// It returns nil if no route matches.
+func (r *MetadataRouter) Route(service string, metadata map[string]string) map[string]string {
+	if r == nil {
+		return nil
+	}
+	for _, route := range r.routes {
+		if route.match(service, metadata) {
+			return route.Destination
+		}
+	}
+	return nil
+}
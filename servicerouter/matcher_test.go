@@ -0,0 +1,146 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package servicerouter
+
+import (
+	"context"
+	"testing"
+
+	"trpc.group/trpc-go/trpc-go/codec"
+	tsr "trpc.group/trpc-go/trpc-go/naming/servicerouter"
+
+	"github.com/golang/mock/gomock"
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"github.com/polarismesh/polaris-go/pkg/plugin/servicerouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_servicerouter"
+)
+
+func TestMetadataMatch(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		match MetadataMatch
+		value string
+		want  bool
+	}{
+		{"exact", MetadataMatch{Value: "a"}, "a", true},
+		{"exact not match", MetadataMatch{Type: MatchExact, Value: "a"}, "b", false},
+		{"regex", MetadataMatch{Type: MatchRegex, Value: "^user-[0-9]+$"}, "user-10", true},
+		{"regex not match", MetadataMatch{Type: MatchRegex, Value: "^user-[0-9]+$"}, "user-a", false},
+		{"prefix", MetadataMatch{Type: MatchPrefix, Value: "sz."}, "sz.1", true},
+		{"prefix not match", MetadataMatch{Type: MatchPrefix, Value: "sz."}, "sh.1", false},
+		{"in", MetadataMatch{Type: MatchIn, Value: "a, b,c"}, "b", true},
+		{"in not match", MetadataMatch{Type: MatchIn, Value: "a,b,c"}, "d", false},
+		{"range", MetadataMatch{Type: MatchRange, Value: "10~20"}, "20", true},
+		{"range below", MetadataMatch{Type: MatchRange, Value: "10~20"}, "9.9", false},
+		{"range open max", MetadataMatch{Type: MatchRange, Value: "10~"}, "10000", true},
+		{"range open min", MetadataMatch{Type: MatchRange, Value: "~10"}, "-1", true},
+		{"range not a number", MetadataMatch{Type: MatchRange, Value: "10~20"}, "x", false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			c.match.Key = "k"
+			require.Nil(t, c.match.compile())
+			assert.Equal(t, c.want, c.match.Match(map[string]string{"k": c.value}))
+			assert.False(t, c.match.Match(map[string]string{"other": c.value}))
+		})
+	}
+}
+
+func TestNewMetadataRouter(t *testing.T) {
+	for _, match := range []*MetadataMatch{
+		{Key: "k", Type: MatchRegex, Value: "("},
+		{Key: "k", Type: MatchRange, Value: "1"},
+		{Key: "k", Type: MatchRange, Value: "a~1"},
+		{Key: "k", Type: "unknown"},
+	} {
+		_, err := NewMetadataRouter(nil, []*MetadataRoute{{
+			Match:       []*MetadataMatch{match},
+			Destination: map[string]string{"env": "gray"},
+		}})
+		assert.NotNil(t, err)
+	}
+	_, err := NewMetadataRouter(nil, []*MetadataRoute{{}})
+	assert.NotNil(t, err)
+}
+
+func TestMetadataRouter(t *testing.T) {
+	r, err := NewMetadataRouter([]string{"uid", "region"}, []*MetadataRoute{
+		{
+			Service:     "other",
+			Match:       []*MetadataMatch{{Key: "region", Type: MatchPrefix, Value: "sz"}},
+			Destination: map[string]string{"env": "other"},
+		},
+		{
+			Service: "svc",
+			Match: []*MetadataMatch{
+				{Key: "uid", Type: MatchRange, Value: "0~999"},
+				{Key: "region", Type: MatchPrefix, Value: "sz"},
+			},
+			Destination: map[string]string{"env": "gray"},
+		},
+		{
+			Match:       []*MetadataMatch{{Key: "region", Type: MatchPrefix, Value: "sz"}},
+			Destination: map[string]string{"env": "sz"},
+		},
+	})
+	require.Nil(t, err)
+
+	ctx, msg := codec.WithNewMessage(context.Background())
+	msg.WithClientMetaData(codec.MetaData{"uid": []byte("100")})
+	msg.WithServerMetaData(codec.MetaData{"region": []byte("sz.1"), "uid": []byte("2000")})
+	metadata := r.Metadata(ctx)
+	assert.Equal(t, map[string]string{"uid": "100", "region": "sz.1"}, metadata)
+
+	assert.Equal(t, map[string]string{"env": "gray"}, r.Route("svc", metadata))
+	assert.Equal(t, map[string]string{"env": "sz"}, r.Route("svc", map[string]string{"region": "sz.1"}))
+	assert.Equal(t, map[string]string{"env": "other"}, r.Route("other", metadata))
+	assert.Nil(t, r.Route("svc", map[string]string{"region": "sh.1"}))
+
+	var nilRouter *MetadataRouter
+	assert.Nil(t, nilRouter.Metadata(ctx))
+	assert.Nil(t, nilRouter.Route("svc", metadata))
+}
+
+func TestRouteByMetadata(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	r, err := NewMetadataRouter([]string{"uid"}, []*MetadataRoute{{
+		Match:       []*MetadataMatch{{Key: "uid", Type: MatchIn, Value: "1,2,3"}},
+		Destination: map[string]string{"env": "gray"},
+	}})
+	require.Nil(t, err)
+	s := &ServiceRouter{
+		DstMeta: mock_servicerouter.NewMockServiceRouter(ctrl),
+		cfg:     &Config{MetadataRouter: r},
+	}
+
+	ctx, msg := codec.WithNewMessage(context.Background())
+	msg.WithClientMetaData(codec.MetaData{"uid": []byte("2")})
+	src := &model.ServiceInfo{}
+	dst := &model.ServiceInfo{Service: "svc"}
+	chain := s.routeByMetadata(src, dst, &tsr.Options{Ctx: ctx}, []servicerouter.ServiceRouter{})
+	assert.Equal(t, []servicerouter.ServiceRouter{s.DstMeta}, chain)
+	assert.Equal(t, "2", src.Metadata["uid"])
+	assert.Equal(t, "gray", dst.Metadata["env"])
+
+	chain = s.routeByMetadata(src, dst, &tsr.Options{Ctx: ctx}, chain)
+	assert.Len(t, chain, 1)
+
+	chain = s.routeByMetadata(&model.ServiceInfo{}, &model.ServiceInfo{Service: "svc"},
+		&tsr.Options{SourceMetadata: map[string]string{"uid": "4"}}, []servicerouter.ServiceRouter{})
+	assert.Empty(t, chain)
+}
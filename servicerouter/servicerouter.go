@@ -18,12 +18,14 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
 	"trpc.group/trpc-go/trpc-go/client"
-	"trpc.group/trpc-go/trpc-go/codec"
 	"trpc.group/trpc-go/trpc-go/log"
 	"trpc.group/trpc-go/trpc-go/naming/registry"
 	tsr "trpc.group/trpc-go/trpc-go/naming/servicerouter"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/metrics"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/tracing"
 
 	"github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/polarismesh/polaris-go/api"
@@ -49,6 +51,7 @@ func Setup(sdkCtx api.SDKContext, cfg *Config, setDefault bool) error {
 		consumer: api.NewConsumerAPIByContext(sdkCtx),
 		cfg:      cfg,
 		sdkCtx:   sdkCtx,
+		cache:    newRouteCache(cfg),
 	}
 
 	// Initialize rule routing.
@@ -126,7 +129,27 @@ type ServiceRouter struct {
 	DstMeta     servicerouter.ServiceRouter
 	SetDivision servicerouter.ServiceRouter
 	Canary      servicerouter.ServiceRouter
-	cfg         *Config
+	cache       *routeCache
+
+	mu  sync.RWMutex
+	cfg *Config
+}
+
+// config returns the current config of the router.
+func (s *ServiceRouter) config() *Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+// Reload replaces the config of the router atomically, the requests in flight may still use the old one.
+// The name and the route cache are bound at setup, so Name and RouteCache of cfg are ignored.
+func (s *ServiceRouter) Reload(cfg *Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := *cfg
+	c.Name, c.RouteCache = s.cfg.Name, s.cfg.RouteCache
+	s.cfg = &c
 }
 
 func hasEnv(r *traffic_manage.Route, env string) bool {
@@ -226,9 +249,51 @@ func (s *ServiceRouter) setEnable(
 	return chain
 }
 
+// routeByMetadata merges the request metadata read from trpc message into the source service for rule routing,
+// and filters instances by the destination metadata of the matched local metadata route.
+func (s *ServiceRouter) routeByMetadata(
+	srcServiceInfo *model.ServiceInfo,
+	dstServiceInfo *model.ServiceInfo,
+	opts *tsr.Options,
+	chain []servicerouter.ServiceRouter,
+) []servicerouter.ServiceRouter {
+	metadata := s.config().MetadataRouter.Metadata(opts.Ctx)
+	if len(metadata) > 0 && srcServiceInfo.Metadata == nil {
+		srcServiceInfo.Metadata = make(map[string]string, len(metadata))
+	}
+	for key, value := range metadata {
+		if _, ok := srcServiceInfo.Metadata[key]; !ok {
+			srcServiceInfo.Metadata[key] = value
+		}
+	}
+	matchMetadata := make(map[string]string, len(opts.SourceMetadata)+len(metadata))
+	for key, value := range opts.SourceMetadata {
+		matchMetadata[key] = value
+	}
+	for key, value := range metadata {
+		matchMetadata[key] = value
+	}
+	dest := s.config().MetadataRouter.Route(dstServiceInfo.Service, matchMetadata)
+	if len(dest) == 0 {
+		return chain
+	}
+	if dstServiceInfo.Metadata == nil {
+		dstServiceInfo.Metadata = make(map[string]string, len(dest))
+	}
+	for key, value := range dest {
+		dstServiceInfo.Metadata[key] = value
+	}
+	for _, r := range chain {
+		if r == s.DstMeta {
+			return chain
+		}
+	}
+	return append(chain, s.DstMeta)
+}
+
 func (s *ServiceRouter) filterWithEnv(
 	serviceInstances model.ServiceInstances,
-	sourceService, destService *model.ServiceInfo, opts *tsr.Options) ([]*registry.Node, error) {
+	sourceService, destService *model.ServiceInfo, opts *tsr.Options, canaryValue string) (*routeResult, error) {
 	envList := []string{}
 	if len(opts.EnvTransfer) > 0 {
 		envList = strings.Split(opts.EnvTransfer, ",")
@@ -237,7 +302,6 @@ func (s *ServiceRouter) filterWithEnv(
 	sourceService.Metadata = map[string]string{
 		"env": opts.SourceEnvName,
 	}
-	canaryValue := getCanaryValue(opts)
 	routeRules := buildRouteRules(opts.SourceNamespace,
 		opts.SourceServiceName, opts.SourceEnvName, opts.Namespace, envList)
 	routeInfo := &servicerouter.RouteInfo{
@@ -250,9 +314,10 @@ func (s *ServiceRouter) filterWithEnv(
 
 	// Consider the set grouping situation.
 	chain := []servicerouter.ServiceRouter{s.RuleBased}
+	chain = s.routeByMetadata(sourceService, destService, opts, chain)
 	chain = s.setEnable(sourceService, destService, opts, chain)
 	chain = append(chain, s.NearbyBased)
-	if s.cfg.EnableCanary {
+	if s.config().EnableCanary {
 		chain = append(chain, s.Canary)
 	}
 	instances, cluster, _, err := servicerouterGetFilterInstances(s.sdkCtx.GetValueContext(),
@@ -261,25 +326,28 @@ func (s *ServiceRouter) filterWithEnv(
 		return nil, fmt.Errorf("filter instance with env err: %s", err.Error())
 	}
 
-	return s.instanceToNode(instances, opts.EnvTransfer, cluster, serviceInstances), nil
+	return &routeResult{instances: instances, cluster: cluster, env: opts.EnvTransfer}, nil
 }
 
-func (s *ServiceRouter) filter(
-	serviceInstances model.ServiceInstances,
-	sourceService, destService *model.ServiceInfo, opts *tsr.Options) ([]*registry.Node, error) {
-
-	sourceRouteRules, err := s.consumer.GetRouteRule(&api.GetServiceRuleRequest{
+// getRouteRule gets the route rule of the service.
+func (s *ServiceRouter) getRouteRule(service *model.ServiceInfo) (*model.ServiceRuleResponse, error) {
+	routeRules, err := s.consumer.GetRouteRule(&api.GetServiceRuleRequest{
 		GetServiceRuleRequest: model.GetServiceRuleRequest{
-			Namespace: sourceService.Namespace,
-			Service:   sourceService.Service,
+			Namespace: service.Namespace,
+			Service:   service.Service,
 		},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("get source service ns: %s, service: %s route rule err: %s",
-			sourceService.Namespace, sourceService.Service, err.Error())
+		return nil, fmt.Errorf("get service ns: %s, service: %s route rule err: %s",
+			service.Namespace, service.Service, err.Error())
 	}
-	canaryValue := getCanaryValue(opts)
+	return routeRules, nil
+}
 
+func (s *ServiceRouter) filter(
+	serviceInstances model.ServiceInstances,
+	sourceRouteRules *model.ServiceRuleResponse,
+	sourceService, destService *model.ServiceInfo, opts *tsr.Options, canaryValue string) (*routeResult, error) {
 	// First consider if there is a rule.
 	// If there is no outgoing rule, skip the service route directly, and only filter unhealthy nodes.
 	// Otherwise, use the env and key of this node to filter out its own rules.
@@ -287,9 +355,10 @@ func (s *ServiceRouter) filter(
 	var newEnvStr string
 	outbounds := getOutboundsRoute(sourceRouteRules)
 	if len(outbounds) == 0 {
+		chain = s.routeByMetadata(sourceService, destService, opts, chain)
 		chain = s.setEnable(sourceService, destService, opts, chain)
 		chain = append(chain, s.NearbyBased)
-		if s.cfg.EnableCanary {
+		if s.config().EnableCanary {
 			chain = append(chain, s.Canary)
 		}
 	} else {
@@ -310,9 +379,10 @@ func (s *ServiceRouter) filter(
 		newEnvStr = getEnvPriority(outbounds, opts.SourceEnvName)
 
 		chain = append(chain, s.RuleBased)
+		chain = s.routeByMetadata(sourceService, destService, opts, chain)
 		chain = s.setEnable(sourceService, destService, opts, chain)
 		chain = append(chain, s.NearbyBased)
-		if s.cfg.EnableCanary {
+		if s.config().EnableCanary {
 			chain = append(chain, s.Canary)
 		}
 	}
@@ -333,17 +403,17 @@ func (s *ServiceRouter) filter(
 	if err != nil {
 		return nil, fmt.Errorf("filter instances without transfer env err: %s", err.Error())
 	}
-	if len(instances) == 0 {
-		return nil, fmt.Errorf("env %s do not have instances, key: %s",
-			opts.SourceEnvName, opts.EnvKey)
-	}
-
-	return s.instanceToNode(instances, newEnvStr, cluster, serviceInstances), nil
+	return &routeResult{
+		instances: instances,
+		cluster:   cluster,
+		env:       newEnvStr,
+		errEmpty:  fmt.Errorf("env %s do not have instances, key: %s", opts.SourceEnvName, opts.EnvKey),
+	}, nil
 }
 
 func (s *ServiceRouter) filterWithoutServiceRouter(
 	serviceInstances model.ServiceInstances,
-	sourceService, destService *model.ServiceInfo, opts *tsr.Options) ([]*registry.Node, error) {
+	sourceService, destService *model.ServiceInfo, opts *tsr.Options, canaryValue string) (*routeResult, error) {
 	chain := []servicerouter.ServiceRouter{}
 	if len(opts.DestinationEnvName) > 0 {
 		chain = append(chain, s.DstMeta)
@@ -351,10 +421,10 @@ func (s *ServiceRouter) filterWithoutServiceRouter(
 			"env": opts.DestinationEnvName,
 		}
 	}
-	canaryValue := getCanaryValue(opts)
+	chain = s.routeByMetadata(sourceService, destService, opts, chain)
 	chain = s.setEnable(sourceService, destService, opts, chain)
 	chain = append(chain, s.NearbyBased)
-	if s.cfg.EnableCanary {
+	if s.config().EnableCanary {
 		chain = append(chain, s.Canary)
 	}
 	routeInfo := &servicerouter.RouteInfo{
@@ -368,15 +438,31 @@ func (s *ServiceRouter) filterWithoutServiceRouter(
 	if err != nil {
 		return nil, fmt.Errorf("filter instances err: %s", err.Error())
 	}
-	if len(instances) == 0 {
-		return nil, errors.New("filter instances no instances available")
-	}
-	return s.instanceToNode(instances, "", cluster, serviceInstances), nil
+	return &routeResult{
+		instances: instances,
+		cluster:   cluster,
+		errEmpty:  errors.New("filter instances no instances available"),
+	}, nil
 }
 
 // Filter filters instances based on routing rules.
 func (s *ServiceRouter) Filter(serviceName string,
 	nodes []*registry.Node, opt ...tsr.Option) ([]*registry.Node, error) {
+	opts := &tsr.Options{}
+	for _, o := range opt {
+		o(opts)
+	}
+	_, span := tracing.Start(opts.Ctx, tracing.SpanServiceRouterFilter)
+	span.SetAttribute(tracing.AttrService, serviceName)
+	span.SetAttribute(tracing.AttrNamespace, opts.Namespace)
+	list, err := s.filterNodes(serviceName, nodes, opts, span)
+	span.End(err)
+	return list, err
+}
+
+// filterNodes filters the nodes by the options, and sets the routing results to the span.
+func (s *ServiceRouter) filterNodes(serviceName string,
+	nodes []*registry.Node, opts *tsr.Options, span tracing.Span) ([]*registry.Node, error) {
 	if len(nodes) == 0 {
 		return nil, errors.New("servicerouter: no node available")
 	}
@@ -384,11 +470,6 @@ func (s *ServiceRouter) Filter(serviceName string,
 	if !ok {
 		return nil, errors.New("service instances invalid")
 	}
-
-	opts := &tsr.Options{}
-	for _, o := range opt {
-		o(opts)
-	}
 	log.Tracef("[NAMING-POLARISMESH] servicerouter options: %+v", opts)
 	sourceService := &model.ServiceInfo{
 		Service:   opts.SourceServiceName,
@@ -399,19 +480,106 @@ func (s *ServiceRouter) Filter(serviceName string,
 		Namespace: opts.Namespace,
 	}
 
+	s.resolveSetNames(serviceName, serviceInstances, opts)
+	canaryValue := s.getCanaryValue(opts, serviceName, serviceInstances)
+	r, err := s.route(serviceInstances, sourceService, destService, opts, canaryValue)
+	if err != nil {
+		return nil, err
+	}
+	r.set = opts.DestinationSetName
+	if r.cache != "" {
+		span.SetAttribute(tracing.AttrCache, r.cache)
+	}
+	if f := s.config().failover(serviceName); f != nil && f.need(r.instances) {
+		r = s.failover(serviceInstances, destService, opts, canaryValue, f, r)
+	}
+	if r.env != "" {
+		span.SetAttribute(tracing.AttrEnv, r.env)
+	}
+	if r.set != "" {
+		span.SetAttribute(tracing.AttrSet, r.set)
+	}
+	if len(r.instances) == 0 {
+		metrics.ReportRouteEmpty(destService.Namespace, serviceName, r.env, r.set)
+		if r.errEmpty != nil {
+			return nil, r.errEmpty
+		}
+	}
+	list := s.instanceToNode(r.instances, r.env, r.cluster, serviceInstances)
+	if len(list) > 0 && list[0].SetName == "" {
+		list[0].SetName = r.set
+	}
+	return list, nil
+}
+
+// resolveSetNames resolves the wildcard set names and the set fallback.
+func (s *ServiceRouter) resolveSetNames(
+	serviceName string, serviceInstances model.ServiceInstances, opts *tsr.Options) {
+	if !NeedResolveSet(opts.SourceSetName, s.config().EnableSetFallback) &&
+		!NeedResolveSet(opts.DestinationSetName, s.config().EnableSetFallback) {
+		return
+	}
+	opts.SourceSetName, opts.DestinationSetName = ResolveSetNamesOf(opts.Namespace, serviceName,
+		serviceInstances.GetInstances(), opts.SourceSetName, opts.DestinationSetName, s.config().EnableSetFallback)
+}
+
+// route routes by the chain chosen by the options, the results are cached if route cache is enabled.
+func (s *ServiceRouter) route(
+	serviceInstances model.ServiceInstances,
+	sourceService, destService *model.ServiceInfo, opts *tsr.Options, canaryValue string) (*routeResult, error) {
 	// If the main calling service information does not exist, the service route will not be taken.
 	if len(sourceService.Service) == 0 ||
 		len(sourceService.Namespace) == 0 ||
 		opts.DisableServiceRouter ||
-		!s.cfg.Enable {
-		return s.filterWithoutServiceRouter(serviceInstances, sourceService, destService, opts)
+		!s.config().Enable {
+		return s.cachedRoute("none", "", serviceInstances, sourceService, destService, opts, canaryValue,
+			func() (*routeResult, error) {
+				return s.filterWithoutServiceRouter(serviceInstances, sourceService, destService, opts, canaryValue)
+			})
 	}
 
 	// If there is no transparent transmission of environmental information.
 	if len(opts.EnvTransfer) == 0 {
-		return s.filter(serviceInstances, sourceService, destService, opts)
+		sourceRouteRules, err := s.getRouteRule(sourceService)
+		if err != nil {
+			return nil, err
+		}
+		route := func() (*routeResult, error) {
+			return s.filter(serviceInstances, sourceRouteRules, sourceService, destService, opts, canaryValue)
+		}
+		if s.cache == nil || !cacheableRoutes(getOutboundsRoute(sourceRouteRules)) {
+			return route()
+		}
+		// The inbound rules of the callee are routed by polaris as well.
+		destRouteRules, err := s.getRouteRule(destService)
+		if err != nil {
+			return nil, err
+		}
+		return s.cachedRoute("rule", rulesRevision(sourceRouteRules, destRouteRules),
+			serviceInstances, sourceService, destService, opts, canaryValue, route)
 	}
-	return s.filterWithEnv(serviceInstances, sourceService, destService, opts)
+	return s.cachedRoute("env", "", serviceInstances, sourceService, destService, opts, canaryValue,
+		func() (*routeResult, error) {
+			return s.filterWithEnv(serviceInstances, sourceService, destService, opts, canaryValue)
+		})
+}
+
+// routeResult is the result of a routing chain.
+type routeResult struct {
+	instances []model.Instance
+	cluster   *model.Cluster
+	env       string
+	// set is the destination set name routed to.
+	set string
+	// errEmpty is returned if there are no instances in the result.
+	errEmpty error
+	// cache is the result of the route cache lookup, empty if the route cache is disabled.
+	cache string
+}
+
+func (r *routeResult) clone() *routeResult {
+	c := *r
+	return &c
 }
 
 // buildRouteRules builds query rules based on the transparent environment priority list.
@@ -495,13 +663,14 @@ func (s *ServiceRouter) instanceToNode(instances []model.Instance,
 		return nil
 	}
 	list := make([]*registry.Node, 0, len(instances))
-	if s.cfg.NeedReturnAllNodes {
+	if s.config().NeedReturnAllNodes {
 		for _, ins := range instances {
 			list = append(list, &registry.Node{
 				ServiceName: ins.GetService(),
 				Address:     fmt.Sprintf("%s:%d", ins.GetHost(), ins.GetPort()),
 				Protocol:    ins.GetProtocol(),
 				Weight:      ins.GetWeight(),
+				SetName:     InstanceSetName(ins),
 			})
 		}
 	} else {
@@ -515,17 +684,14 @@ func (s *ServiceRouter) instanceToNode(instances []model.Instance,
 	return list
 }
 
-func getCanaryValue(opts *tsr.Options) string {
-	if opts.Ctx == nil {
-		return ""
-	}
-	ctx := opts.Ctx
-	msg := codec.Message(ctx)
-	metaData := msg.ClientMetaData()
-	if metaData == nil {
-		return ""
+// getCanaryValue returns the canary value of the request.
+// Percentage based canary only takes effect when canary routing is enabled.
+func (s *ServiceRouter) getCanaryValue(opts *tsr.Options,
+	serviceName string, serviceInstances model.ServiceInstances) string {
+	if !s.config().EnableCanary || s.config().Canary == nil {
+		return clientCanaryValue(opts.Ctx)
 	}
-	return string(metaData[CanaryKey])
+	return s.config().Canary.Pick(opts.Ctx, serviceName, serviceInstances.GetMetadata())
 }
 
 // WithCanary sets canary metadata.
@@ -74,6 +74,10 @@ func TestInstanceToNode_ReturnAll(t *testing.T) {
 		inst.EXPECT().GetPort().Return(uint32(i))
 		inst.EXPECT().GetProtocol().Return("protocol")
 		inst.EXPECT().GetWeight().Return(i)
+		inst.EXPECT().GetMetadata().Return(map[string]string{
+			setEnableKey: setEnableValue,
+			setNameKey:   "app.sz.1",
+		})
 		instances = append(instances, inst)
 	}
 	clustersMock := mock_model.NewMockServiceClusters(ctrl)
@@ -82,6 +86,7 @@ func TestInstanceToNode_ReturnAll(t *testing.T) {
 	nodes := sr.instanceToNode(instances, "env", clusters, serviceInstances)
 	assert.Len(t, nodes, 10)
 	node := nodes[0]
+	assert.Equal(t, "app.sz.1", node.SetName)
 	assert.Equal(t, node.EnvKey, "env")
 	assert.Equal(t, node.Metadata["serviceInstances"], serviceInstances)
 	assert.Equal(t, node.Metadata["cluster"], clusters)
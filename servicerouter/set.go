@@ -0,0 +1,180 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package servicerouter
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+
+	"trpc.group/trpc-go/trpc-go/log"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/metrics"
+
+	"github.com/polarismesh/polaris-go/pkg/model"
+)
+
+// SetWildcard matches any segment of a set name, such as app.sz.* .
+const SetWildcard = "*"
+
+// ResolveSetNames resolves the source and destination set names against the instances of the callee.
+//
+// A set name with wildcard segments is resolved to one of the matched sets which have available instances,
+// picked randomly by the weight of their available instances.
+// If fallback is true and the set has no available instances,
+// it falls back to the region level set, such as app.sz.* of app.sz.1, and then to no set.
+// If the destination set is empty, the source set is resolved as the destination set
+// when the callee enables set of the same app.
+//
+// It returns the source and destination set names to route by.
+// Both of them are empty if it falls back to no set.
+func ResolveSetNames(
+	instances []model.Instance,
+	sourceSet, destSet string,
+	fallback bool,
+) (string, string) {
+	if destSet == "" && NeedResolveSet(sourceSet, fallback) && calleeEnableSet(instances, sourceSet) {
+		sourceSet, destSet = "", sourceSet
+	}
+	if !NeedResolveSet(destSet, fallback) {
+		return sourceSet, destSet
+	}
+	if destSet = resolveSet(instances, destSet, fallback); destSet == "" {
+		return "", ""
+	}
+	return sourceSet, destSet
+}
+
+// ResolveSetNamesOf is ResolveSetNames of the callee service,
+// it also logs and reports the set fallback by metrics if the requested set falls back to another set or no set.
+func ResolveSetNamesOf(
+	namespace, service string,
+	instances []model.Instance,
+	sourceSet, destSet string,
+	fallback bool,
+) (string, string) {
+	requested := destSet
+	if requested == "" && NeedResolveSet(sourceSet, fallback) && calleeEnableSet(instances, sourceSet) {
+		requested = sourceSet
+	}
+	sourceSet, destSet = ResolveSetNames(instances, sourceSet, destSet, fallback)
+	if fallback && requested != "" && requested != destSet && !strings.Contains(requested, SetWildcard) {
+		log.Warnf("[NAMING-POLARISMESH] service %s of namespace %s falls back from set %q to set %q",
+			service, namespace, requested, destSet)
+		metrics.ReportFailover(namespace, service, metrics.FailoverSet, requested, destSet)
+	}
+	return sourceSet, destSet
+}
+
+// NeedResolveSet reports whether the set name needs to be resolved by ResolveSetNames.
+func NeedResolveSet(name string, fallback bool) bool {
+	return name != "" && (fallback || strings.Contains(name, SetWildcard))
+}
+
+// calleeEnableSet reports whether the callee has set enabled instances of the same app as the set name.
+func calleeEnableSet(instances []model.Instance, name string) bool {
+	app := strings.SplitN(name, ".", 2)[0]
+	for _, inst := range instances {
+		if set := InstanceSetName(inst); set != "" && strings.SplitN(set, ".", 2)[0] == app {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSet returns the concrete set name to route to.
+func resolveSet(instances []model.Instance, name string, fallback bool) string {
+	patterns := []string{name}
+	if fallback {
+		if region := regionSet(name); region != "" && region != name {
+			patterns = append(patterns, region)
+		}
+	}
+	for _, pattern := range patterns {
+		if set := pickSet(instances, pattern); set != "" {
+			return set
+		}
+	}
+	if fallback {
+		return ""
+	}
+	return name
+}
+
+// regionSet returns the region level set name, which is app.region.* for app.region.group.
+func regionSet(name string) string {
+	segments := strings.Split(name, ".")
+	if len(segments) != 3 {
+		return ""
+	}
+	return segments[0] + "." + segments[1] + "." + SetWildcard
+}
+
+// setMatch reports whether the set name matches the pattern segment by segment.
+func setMatch(pattern, name string) bool {
+	if pattern == name {
+		return true
+	}
+	patternSegments := strings.Split(pattern, ".")
+	nameSegments := strings.Split(name, ".")
+	if len(patternSegments) != len(nameSegments) {
+		return false
+	}
+	for i, segment := range patternSegments {
+		if segment != SetWildcard && segment != nameSegments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// pickSet picks a set matched by the pattern randomly by the weight of its available instances.
+func pickSet(instances []model.Instance, pattern string) string {
+	weights := make(map[string]int)
+	for _, inst := range instances {
+		set := InstanceSetName(inst)
+		if set == "" || !available(inst) || !setMatch(pattern, set) {
+			continue
+		}
+		weights[set] += inst.GetWeight()
+	}
+	if len(weights) == 0 {
+		return ""
+	}
+	sets := make([]string, 0, len(weights))
+	var total int
+	for set, weight := range weights {
+		sets = append(sets, set)
+		total += weight
+	}
+	sort.Strings(sets)
+	if total <= 0 {
+		return sets[0]
+	}
+	n := rand.Intn(total)
+	for _, set := range sets {
+		if n -= weights[set]; n < 0 {
+			return set
+		}
+	}
+	return sets[len(sets)-1]
+}
+
+// InstanceSetName returns the set name of the instance, or empty if set is not enabled.
+func InstanceSetName(inst model.Instance) string {
+	metadata := inst.GetMetadata()
+	if metadata[setEnableKey] != setEnableValue {
+		return ""
+	}
+	return metadata[setNameKey]
+}
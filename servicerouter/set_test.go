@@ -0,0 +1,105 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package servicerouter
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/polarismesh/polaris-go/pkg/model"
+	"github.com/stretchr/testify/assert"
+
+	"trpc.group/trpc-go/trpc-naming-polarismesh/mock/mock_model"
+)
+
+func TestSetMatch(t *testing.T) {
+	assert.True(t, setMatch("app.sz.1", "app.sz.1"))
+	assert.True(t, setMatch("app.sz.*", "app.sz.1"))
+	assert.True(t, setMatch("app.*.*", "app.sh.2"))
+	assert.False(t, setMatch("app.sz.*", "app.sh.1"))
+	assert.False(t, setMatch("app.*", "app.sz.1"))
+
+	assert.Equal(t, "app.sz.*", regionSet("app.sz.1"))
+	assert.Equal(t, "", regionSet("app.sz"))
+}
+
+func TestResolveSetNames(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	newInst := func(set string, healthy bool) model.Instance {
+		inst := mock_model.NewMockInstance(ctrl)
+		inst.EXPECT().GetMetadata().Return(map[string]string{
+			setEnableKey: setEnableValue,
+			setNameKey:   set,
+		}).AnyTimes()
+		inst.EXPECT().IsHealthy().Return(healthy).AnyTimes()
+		inst.EXPECT().IsIsolated().Return(false).AnyTimes()
+		inst.EXPECT().GetCircuitBreakerStatus().Return(nil).AnyTimes()
+		inst.EXPECT().GetWeight().Return(100).AnyTimes()
+		return inst
+	}
+	instances := []model.Instance{
+		newInst("app.sz.1", false),
+		newInst("app.sz.2", true),
+		newInst("app.sh.1", true),
+	}
+
+	for _, c := range []struct {
+		name               string
+		sourceSet, destSet string
+		fallback           bool
+		wantSource         string
+		wantDest           string
+	}{
+		{"exact without fallback", "", "app.sz.1", false, "", "app.sz.1"},
+		{"wildcard", "", "app.sz.*", false, "", "app.sz.2"},
+		{"wildcard without available sets", "", "app.gz.*", false, "", "app.gz.*"},
+		{"fall back to region", "", "app.sz.1", true, "", "app.sz.2"},
+		{"fall back to no set", "", "app.gz.1", true, "", ""},
+		{"source set as destination", "app.sz.1", "", true, "", "app.sz.2"},
+		{"source set of other app", "other.sz.1", "", true, "other.sz.1", ""},
+		{"source set without fallback", "app.sz.1", "", false, "app.sz.1", ""},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			source, dest := ResolveSetNames(instances, c.sourceSet, c.destSet, c.fallback)
+			assert.Equal(t, c.wantSource, source)
+			assert.Equal(t, c.wantDest, dest)
+		})
+	}
+}
+
+func TestPickSetByWeight(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	newInst := func(set string, weight int) model.Instance {
+		inst := mock_model.NewMockInstance(ctrl)
+		inst.EXPECT().GetMetadata().Return(map[string]string{
+			setEnableKey: setEnableValue,
+			setNameKey:   set,
+		}).AnyTimes()
+		inst.EXPECT().IsHealthy().Return(true).AnyTimes()
+		inst.EXPECT().IsIsolated().Return(false).AnyTimes()
+		inst.EXPECT().GetCircuitBreakerStatus().Return(nil).AnyTimes()
+		inst.EXPECT().GetWeight().Return(weight).AnyTimes()
+		return inst
+	}
+	instances := []model.Instance{newInst("app.sz.1", 0), newInst("app.sz.2", 100)}
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, "app.sz.2", pickSet(instances, "app.sz.*"))
+	}
+	assert.Equal(t, "app.sz.1", pickSet(instances[:1], "app.sz.*"))
+	assert.Equal(t, "", pickSet(instances, "app.sh.*"))
+}
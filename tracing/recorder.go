@@ -0,0 +1,104 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RecordedSpan is a span ended and recorded by the Recorder.
+type RecordedSpan struct {
+	Name string
+	// Parent is the name of the parent span, empty if it is a root span.
+	Parent     string
+	Attributes map[string]string
+	Err        error
+	Start      time.Time
+	End        time.Time
+}
+
+// Recorder is an in-memory Tracer, which records the ended spans.
+// It is useful for tests.
+type Recorder struct {
+	mu    sync.Mutex
+	spans []RecordedSpan
+}
+
+// NewRecorder creates a Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+type recorderSpanKey struct{}
+
+// Start implements Tracer.
+func (r *Recorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	s := &recorderSpan{
+		recorder: r,
+		span: RecordedSpan{
+			Name:       name,
+			Attributes: make(map[string]string),
+			Start:      time.Now(),
+		},
+	}
+	if parent, ok := ctx.Value(recorderSpanKey{}).(*recorderSpan); ok {
+		s.span.Parent = parent.span.Name
+	}
+	return context.WithValue(ctx, recorderSpanKey{}, s), s
+}
+
+// Spans returns the ended spans in the order they end.
+func (r *Recorder) Spans() []RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecordedSpan(nil), r.spans...)
+}
+
+// Reset drops the recorded spans.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = nil
+}
+
+type recorderSpan struct {
+	recorder *Recorder
+	mu       sync.Mutex
+	span     RecordedSpan
+}
+
+// SetAttribute implements Span.
+func (s *recorderSpan) SetAttribute(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Attributes[key] = value
+}
+
+// End implements Span.
+func (s *recorderSpan) End(err error) {
+	s.mu.Lock()
+	s.span.Err = err
+	s.span.End = time.Now()
+	span := s.span
+	span.Attributes = make(map[string]string, len(s.span.Attributes))
+	for k, v := range s.span.Attributes {
+		span.Attributes[k] = v
+	}
+	s.mu.Unlock()
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	s.recorder.spans = append(s.recorder.spans, span)
+}
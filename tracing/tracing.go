@@ -0,0 +1,110 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+// Package tracing provides the pluggable tracing spans of the naming operations.
+// No span is recorded until a Tracer is set by SetTracer.
+package tracing
+
+import (
+	"context"
+	"sync"
+)
+
+// Names of the spans.
+const (
+	// SpanSelect is the span of Selector.Select.
+	SpanSelect = "polaris.Selector.Select"
+	// SpanDiscoveryList is the span of Discovery.List.
+	SpanDiscoveryList = "polaris.Discovery.List"
+	// SpanServiceRouterFilter is the span of ServiceRouter.Filter.
+	SpanServiceRouterFilter = "polaris.ServiceRouter.Filter"
+	// SpanLoadBalanceSelect is the span of WRLoadBalancer.Select.
+	SpanLoadBalanceSelect = "polaris.LoadBalancer.Select"
+	// SpanHeartbeat is the span of a heartbeat of the registry.
+	SpanHeartbeat = "polaris.Registry.Heartbeat"
+)
+
+// Keys of the span attributes.
+const (
+	// AttrService is the callee service, or the registered service for the heartbeats.
+	AttrService = "polaris.service"
+	// AttrNamespace is the namespace of the service.
+	AttrNamespace = "polaris.namespace"
+	// AttrAddress is the address of the chosen or registered instance.
+	AttrAddress = "polaris.address"
+	// AttrSet is the set routed to.
+	AttrSet = "polaris.set"
+	// AttrEnv is the env routed to.
+	AttrEnv = "polaris.env"
+	// AttrCache is the result of the route cache lookup, one of hit and miss.
+	// It is absent if the route cache is disabled.
+	AttrCache = "polaris.cache"
+)
+
+// Values of AttrCache.
+const (
+	// CacheHit means the result comes from the route cache.
+	CacheHit = "hit"
+	// CacheMiss means the result is routed and then cached.
+	CacheMiss = "miss"
+)
+
+// Tracer creates the spans, it must be safe for concurrent use.
+type Tracer interface {
+	// Start starts a span as a child of the span in ctx if any,
+	// and returns the context carrying the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is an operation being traced.
+type Span interface {
+	// SetAttribute sets an attribute of the span.
+	SetAttribute(key, value string)
+	// End ends the span, err is the error of the operation, nil on success.
+	End(err error)
+}
+
+var tracer = struct {
+	mu sync.RWMutex
+	t  Tracer
+}{}
+
+// SetTracer sets the global tracer, a nil tracer disables tracing.
+func SetTracer(t Tracer) {
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	tracer.t = t
+}
+
+// Start starts a span by the global tracer from the request context, which may be nil.
+// It returns a noop span if no tracer is set.
+func Start(ctx context.Context, name string) (context.Context, Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	tracer.mu.RLock()
+	t := tracer.t
+	tracer.mu.RUnlock()
+	if t == nil {
+		return ctx, noopSpan{}
+	}
+	return t.Start(ctx, name)
+}
+
+type noopSpan struct{}
+
+// SetAttribute implements Span.
+func (noopSpan) SetAttribute(string, string) {}
+
+// End implements Span.
+func (noopSpan) End(error) {}
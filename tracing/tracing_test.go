@@ -0,0 +1,59 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartWithoutTracer(t *testing.T) {
+	ctx, span := Start(nil, SpanSelect)
+	assert.NotNil(t, ctx)
+	span.SetAttribute(AttrService, "no.tracer")
+	span.End(nil)
+}
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder()
+	SetTracer(r)
+	defer SetTracer(nil)
+
+	ctx, parent := Start(context.Background(), SpanSelect)
+	_, child := Start(ctx, SpanLoadBalanceSelect)
+	child.SetAttribute(AttrAddress, "127.0.0.1:8080")
+	child.End(nil)
+	parent.SetAttribute(AttrService, "recorder")
+	parent.End(errors.New("select fail"))
+	parent.SetAttribute(AttrService, "changed after end")
+
+	spans := r.Spans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, SpanLoadBalanceSelect, spans[0].Name)
+	assert.Equal(t, SpanSelect, spans[0].Parent)
+	assert.Equal(t, map[string]string{AttrAddress: "127.0.0.1:8080"}, spans[0].Attributes)
+	assert.Nil(t, spans[0].Err)
+	assert.Equal(t, SpanSelect, spans[1].Name)
+	assert.Equal(t, "", spans[1].Parent)
+	assert.Equal(t, "recorder", spans[1].Attributes[AttrService])
+	assert.NotNil(t, spans[1].Err)
+	assert.False(t, spans[1].End.Before(spans[1].Start))
+
+	r.Reset()
+	assert.Empty(t, r.Spans())
+}
@@ -0,0 +1,165 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package naming
+
+import (
+	"fmt"
+	"sort"
+
+	"trpc.group/trpc-go/trpc-naming-polarismesh/circuitbreaker"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/cluster"
+	locationprovider "trpc.group/trpc-go/trpc-naming-polarismesh/internal/location"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/metrics"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/validate"
+
+	"github.com/polarismesh/polaris-go/pkg/config"
+)
+
+var logLevels = []string{"", "default", "debug", "info", "warn", "error", "fatal", "none"}
+
+// Validate checks the config, and returns all problems found at once.
+// The unknown keys are reported as problems if the config is decoded from yaml with strict enabled.
+func (c *Config) Validate() error {
+	var p validate.Problems
+	for _, key := range c.unknownKeys {
+		p.Addf("unknown key %s", key)
+	}
+	p.OneOf("protocol", c.Protocol, "", config.DefaultServerConnector)
+	p.AddressList("address_list", c.AddressList)
+	p.NonNegative("timeout", c.Timeout)
+	p.NonNegative("connect_timeout", c.ConnectTimeout)
+	p.NonNegativeDuration("message_timeout", c.MessageTimeout)
+	p.NonNegativeDuration("report_timeout", c.ReportTimeout)
+	p.NonNegativeDuration("service_expire_time", c.ServiceExpireTime)
+	p.NonNegative("discovery.refresh_interval", c.Discovery.RefreshInterval)
+	p.Range("canary.percent", c.Canary.Percent, 0, 100)
+	for service, percent := range c.Canary.Services {
+		p.Range(fmt.Sprintf("canary.services.%s", service), percent, 0, 100)
+	}
+	if c.Logs != nil {
+		p.OneOf("logs.bridge", c.Logs.Bridge, "", LogBridgeTRPC)
+		p.OneOf("logs.level", c.Logs.Level, logLevels...)
+		for category, level := range c.Logs.Levels {
+			p.OneOf("logs.levels."+category, level, logLevels...)
+		}
+	}
+	p.Add("metrics", metrics.Validate(c.Metrics))
+	if c.TLS != nil {
+		p.Add("tls", c.TLS.Validate())
+	}
+	if (c.TLS != nil || c.AuthToken != "") && c.ClusterService != (ClusterService{}) {
+		p.Addf("cluster_service must not be used with tls or auth_token, " +
+			"as polaris-go discovers the system services by the grpc protocol only")
+	}
+	locationprovider.Validate(&p, "location_providers", c.LocationProviders)
+	c.ServiceRouter.validate(&p)
+	c.CircuitBreaker.validate(&p)
+	c.validateClusters(&p)
+	return p.Err()
+}
+
+func (c *Config) validateClusters(p *validate.Problems) {
+	if len(c.Clusters) == 0 && len(c.ClusterRoutes) > 0 {
+		p.Addf("cluster_routes must be used with clusters")
+	}
+	clusters := map[string]bool{cluster.Default: true}
+	for i, cl := range c.Clusters {
+		field := fmt.Sprintf("clusters.%d", i)
+		switch {
+		case cl.Name == "":
+			p.Addf("%s.name must not be empty", field)
+		case clusters[cl.Name]:
+			p.Addf("%s.name %s is duplicated or reserved", field, cl.Name)
+		}
+		clusters[cl.Name] = true
+		if cl.AddressList == "" {
+			p.Addf("%s.address_list must not be empty", field)
+		}
+		p.AddressList(field+".address_list", cl.AddressList)
+		p.OneOf(field+".protocol", cl.Protocol, "", config.DefaultServerConnector)
+		p.NonNegative(field+".connect_timeout", cl.ConnectTimeout)
+		p.NonNegativeDuration(field+".message_timeout", cl.MessageTimeout)
+	}
+	for i, route := range c.ClusterRoutes {
+		field := fmt.Sprintf("cluster_routes.%d.clusters", i)
+		if len(route.Clusters) == 0 {
+			p.Addf("%s must not be empty", field)
+		}
+		for _, name := range route.Clusters {
+			if !clusters[name] {
+				p.Addf("%s has an unknown cluster %s", field, name)
+			}
+		}
+	}
+}
+
+func (c *ServiceRouterConfig) validate(p *validate.Problems) {
+	p.OneOf("service_router.nearby_matchlevel", c.NearbyMatchLevel,
+		config.AllLevel, config.RegionLevel, config.ZoneLevel, config.CampusLevel)
+	p.Range("service_router.percent_of_min_instances", c.PercentOfMinInstances, 0, 1)
+}
+
+func (c *CircuitBreakerConfig) validate(p *validate.Problems) {
+	for i, plugin := range c.Chain {
+		p.OneOf(fmt.Sprintf("circuitbreaker.chain.%d", i), plugin,
+			config.DefaultCircuitBreakerErrCount, config.DefaultCircuitBreakerErrRate)
+	}
+	p.NonNegativeDuration("circuitbreaker.checkPeriod", c.CheckPeriod)
+	p.NonNegativeDuration("circuitbreaker.sleepWindow", c.SleepWindow)
+	validateErrorRate(p, "circuitbreaker.errorRate", c.ErrorRate)
+	services := make([]string, 0, len(c.Services))
+	for service := range c.Services {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		if sc := c.Services[service]; sc != nil {
+			sc.validate(p, "circuitbreaker.services."+service)
+		}
+	}
+	for service, level := range c.Levels {
+		p.OneOf("circuitbreaker.levels."+service, level,
+			circuitbreaker.LevelInstance, circuitbreaker.LevelMethod, circuitbreaker.LevelInstanceMethod)
+	}
+	if c.OutlierDetection != nil && c.OutlierDetection.Enable {
+		p.Add("circuitbreaker.outlier_detection", c.OutlierDetection.Validate())
+	}
+	if c.Probe != nil && c.Probe.Enable {
+		p.Add("circuitbreaker.probe", c.Probe.Validate())
+	}
+}
+
+// validate rejects the thresholds which polaris-go v1.5.5 does not honor per service.
+func (c *ServiceCircuitBreakerConfig) validate(p *validate.Problems, field string) {
+	for _, unsupported := range []struct {
+		name string
+		set  bool
+	}{
+		{"sleepWindow", c.SleepWindow != nil},
+		{"requestCountAfterHalfOpen", c.RequestCountAfterHalfOpen != nil},
+		{"successCountAfterHalfOpen", c.SuccessCountAfterHalfOpen != nil},
+		{"errorRate", c.ErrorRate != nil},
+	} {
+		if unsupported.set {
+			p.Addf("%s.%s is not supported per service by polaris-go, only errorCount is, "+
+				"set circuitbreaker.%s instead", field, unsupported.name, unsupported.name)
+		}
+	}
+}
+
+func validateErrorRate(p *validate.Problems, field string, c *ErrorRateConfig) {
+	if c != nil && c.ErrorRateThreshold != nil {
+		p.Range(field+".errorRateThreshold", *c.ErrorRateThreshold, 0, 1)
+	}
+}
@@ -0,0 +1,109 @@
+//
+//
+// Tencent is pleased to support the open source community by making tRPC available.
+//
+// Copyright (C) 2023 THL A29 Limited, a Tencent company.
+// All rights reserved.
+//
+// If you have downloaded a copy of the tRPC source code from Tencent,
+// please note that tRPC source code is licensed under the Apache 2.0 License,
+// A copy of the Apache 2.0 License is included in this file.
+//
+//
+
+package naming
+
+import (
+	"testing"
+
+	"trpc.group/trpc-go/trpc-naming-polarismesh/cluster"
+	"trpc.group/trpc-go/trpc-naming-polarismesh/internal/validate"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	require.Nil(t, (&Config{AddressList: "127.0.0.1:0"}).Validate())
+
+	cfg := &Config{}
+	require.Nil(t, yaml.Unmarshal([]byte(`
+strict: true
+protocol: http
+address_list: 127.0.0.1
+timeout: -1
+canary:
+  percent: 120
+logs:
+  bridge: zap
+  level: verbose
+metrics:
+  unknown: true
+tls:
+  cert_file: client.pem
+cluster_service:
+  discover: polaris.discover
+service_router:
+  nearby_matchlevel: city
+  percent_of_min_instances: 2
+  nearby: true
+circuitbreaker:
+  chain: [errorCount, slowRate]
+  errorRate:
+    errorRateThreshold: 1.5
+  levels:
+    foo: service
+  services:
+    trpc.app.flaky.service:
+      sleepWindow: 5s
+      errorRate:
+        errorRateThreshold: 0.8
+      errorCount:
+        continuousErrorThreshold: 50
+`), cfg))
+	err := cfg.Validate()
+	require.NotNil(t, err)
+	e, ok := err.(*validate.Error)
+	require.True(t, ok)
+	require.Equal(t, []string{
+		"unknown key service_router.nearby (line 20)",
+		`protocol must be one of "", "grpc", got "http"`,
+		`address_list must be host:port separated by commas, got "127.0.0.1"`,
+		"timeout must not be negative, got -1",
+		"canary.percent must be in [0, 100], got 120",
+		`logs.bridge must be one of "", "trpc", got "zap"`,
+		`logs.level must be one of "", "default", "debug", "info", "warn", "error", "fatal", "none", got "verbose"`,
+		"metrics: unknown metrics family unknown",
+		"tls: cert_file and key_file must be set together",
+		"cluster_service must not be used with tls or auth_token, " +
+			"as polaris-go discovers the system services by the grpc protocol only",
+		`service_router.nearby_matchlevel must be one of "", "region", "zone", "campus", got "city"`,
+		"service_router.percent_of_min_instances must be in [0, 1], got 2",
+		`circuitbreaker.chain.1 must be one of "errorCount", "errorRate", got "slowRate"`,
+		"circuitbreaker.errorRate.errorRateThreshold must be in [0, 1], got 1.5",
+		"circuitbreaker.services.trpc.app.flaky.service.sleepWindow is not supported per service by polaris-go, " +
+			"only errorCount is, set circuitbreaker.sleepWindow instead",
+		"circuitbreaker.services.trpc.app.flaky.service.errorRate is not supported per service by polaris-go, " +
+			"only errorCount is, set circuitbreaker.errorRate instead",
+		`circuitbreaker.levels.foo must be one of "instance", "method", "instance_method", got "service"`,
+	}, e.Problems)
+
+	err = (&Config{
+		Clusters: []ClusterConfig{
+			{Name: "default", AddressList: "127.0.0.1:0"},
+			{Name: "new"},
+		},
+		ClusterRoutes: []cluster.Route{{Clusters: []string{"new", "old"}}, {}},
+	}).Validate()
+	require.NotNil(t, err)
+	require.Equal(t, []string{
+		"clusters.0.name default is duplicated or reserved",
+		"clusters.1.address_list must not be empty",
+		"cluster_routes.0.clusters has an unknown cluster old",
+		"cluster_routes.1.clusters must not be empty",
+	}, err.(*validate.Error).Problems)
+	require.NotNil(t, (&Config{ClusterRoutes: []cluster.Route{{Clusters: []string{"default"}}}}).Validate())
+
+	_, err = setupWithConfig(cfg)
+	require.Contains(t, err.Error(), "invalid selector config: 17 config problem(s)")
+}